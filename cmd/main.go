@@ -1,20 +1,36 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"payment-gateway/db"
 	"payment-gateway/internal/api"
+	"payment-gateway/internal/auth"
+	"payment-gateway/internal/beneficiary"
+	"payment-gateway/internal/bus"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/health"
 	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/logging"
 	"payment-gateway/internal/psp"
 	"payment-gateway/internal/psp/defaultgateway"
 	"payment-gateway/internal/psp/razorpay"
 	"payment-gateway/internal/psp/stripe"
+	"payment-gateway/internal/ratelimit"
+	"payment-gateway/internal/risk"
+	"payment-gateway/internal/services"
+	"payment-gateway/internal/tracing"
+	"payment-gateway/internal/vault"
+	"syscall"
+	"time"
 
 	_ "payment-gateway/docs" // Import generated docs
-
-	"github.com/joho/godotenv"
 )
 
 // @title Deposit API
@@ -22,40 +38,187 @@ import (
 // @description This is a deposit processing API
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT bearer token, "Bearer <token>"
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key ID, sent with X-Timestamp and the HMAC X-Signature
 func main() {
-	// Load environment variables from .env file
-	err := godotenv.Load("config")
+	// "simulator" runs the default gateway simulator instead of the service
+	if len(os.Args) > 1 && os.Args[1] == "simulator" {
+		os.Exit(runSimulator(os.Args[2:]))
+	}
+
+	// The config file is optional, the environment overrides it
+	cfg, err := config.Load("config")
 	if err != nil {
-		log.Println("Error loading .env file, using defaults or system environment variables")
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
 	}
+	logging.Setup(cfg.Log)
 
-	broker := os.Getenv("KAFKA_BROKER")
-	if broker == "" {
-		broker = "localhost:9093" // Default to localhost:9093 for local debugging
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+
+	// The webhooks travel on the bus picked by BUS_BACKEND
+	b, err := newBus(cfg)
+	if err != nil {
+		slog.Error("failed to initialize the message bus", "backend", cfg.Bus.Backend, "error", err)
+		os.Exit(1)
+	}
+	encoder, err := events.NewEncoder(cfg.Bus.Encoding)
+	if err != nil {
+		slog.Error("failed to initialize the event encoder", "error", err)
+		os.Exit(1)
 	}
-	brokers := []string{broker}
+	publisher := events.NewPublisher(b, encoder)
 
-	// Initialize Kafka
-	k, err := kafka.Init(brokers)
+	db, err := db.NewDB(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize Kafka: %v", err)
+		slog.Error("failed to connect to the database", "error", err)
+		os.Exit(1)
+	}
+
+	authenticator, err := auth.NewFromConfig(cfg.Auth, db.Redis)
+	if err != nil {
+		slog.Error("failed to initialize authentication", "error", err)
+		os.Exit(1)
+	}
+
+	limiter := ratelimit.NewFromConfig(db.Redis, cfg.RateLimit)
+
+	encryptor, err := services.NewEncryptor(cfg.Encryption.Keys, cfg.Encryption.ActiveKey)
+	if err != nil {
+		slog.Error("failed to initialize data encryption", "error", err)
+		os.Exit(1)
+	}
+	tokens := vault.New(db.Vault, encryptor)
+	beneficiaries := beneficiary.New(db.Beneficiaries, tokens)
+	reviews := risk.NewQueue(db.Risk, encryptor)
+
+	// Readiness fails while a critical dependency is down or a webhook consumer is not subscribed
+	checker := health.New(2 * time.Second)
+	checker.Add(
+		health.Check{Name: "postgres", Critical: true, Run: db.DB.Ping},
+		health.Check{Name: "redis", Critical: true, Run: db.Redis.Ping},
+		health.Check{Name: cfg.Bus.Backend, Critical: true, Run: b.Ping},
+	)
+
+	// Gateways without credentials are disabled instead of failing the startup
+	var gateways []psp.IPSP
+	if cfg.Razorpay.Enabled {
+		gateways = append(gateways, razorpay.Init(cfg.Razorpay))
+	}
+	if cfg.Stripe.Enabled {
+		stripeClient := stripe.Init(cfg.Stripe, publisher)
+		gateways = append(gateways, stripeClient)
+		checker.Add(health.Breaker("stripe", stripeClient.BreakerState))
+	}
+	if cfg.DefaultGateway.Enabled {
+		gateways = append(gateways, defaultgateway.Init(cfg.DefaultGateway, publisher))
+	}
+	psp := psp.Init(nil)
+	for _, gateway := range gateways {
+		if err := psp.Register(gateway); err != nil {
+			slog.Error("failed to register gateway", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("gateway registered", "gateway", gateway.GetName())
 	}
-	defer k.Close()
 
-	db, err := db.NewDB()
+	// Gateways disabled by ops stay paused across restarts and instances
+	states, err := db.Admin.GetGatewayStates(context.Background())
 	if err != nil {
-		log.Fatalf("error loading DB : %v", err.Error())
+		slog.Error("failed to load gateway states", "error", err)
+		os.Exit(1)
 	}
+	psp.Load(states)
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go psp.Watch(watchCtx, cfg.Registry.RefreshInterval, db.Admin.GetGatewayStates)
 
-	psp := psp.Init([]psp.IPSP{razorpay.Init(), stripe.Init(k, db), defaultgateway.Init(k, db)})
+	// One consumer handles the payment events of every gateway
+	go func() {
+		if err := events.Consume(b, db); err != nil {
+			slog.Error("payment event consumer stopped", "topic", events.Topic, "error", err)
+		}
+	}()
+	checker.Add(health.Check{Name: cfg.Bus.Backend + "_consumers", Critical: true, Run: func(ctx context.Context) error {
+		return b.CheckConsumers(events.Topic)
+	}})
 
 	// // Set up the HTTP server and routes
-	router := api.SetupRouter(psp, db)
+	router := api.SetupRouter(psp, db, authenticator, limiter, tokens, beneficiaries, reviews, checker, cfg)
 
 	// // Start the server on port 8080
-	log.Println("Starting server on port 8080...")
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		log.Fatalf("Could not start server: %s\n", err)
+	server := &http.Server{Addr: cfg.Server.Addr, Handler: router, ReadHeaderTimeout: 10 * time.Second}
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serverErr:
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// The whole shutdown gets SHUTDOWN_TIMEOUT, a second signal kills the process
+	stop()
+	slog.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	stopWatch() // Before the DB closes
+	if err := shutdown(ctx, server, b, db, shutdownTracing); err != nil {
+		slog.Error("shutdown incomplete", "error", err)
+		os.Exit(1)
 	}
+	slog.Info("shutdown complete")
+}
 
+// shutdown stops taking requests and drains the in-flight ones, stops the webhook
+// consumers after their current message, flushes the producer and the traces and
+// closes Postgres and Redis. Later steps still run when an earlier one fails, so
+// connections are released even past the deadline.
+func shutdown(ctx context.Context, server *http.Server, b bus.Bus, db *db.DB, shutdownTracing func(context.Context) error) error {
+	var errs []error
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to drain http requests: %v", err))
+	}
+	if err := b.StopConsumers(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop bus consumers: %v", err))
+	}
+	if err := b.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close bus: %v", err))
+	}
+	if err := db.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to flush traces: %v", err))
+	}
+	return errors.Join(errs...)
+}
+
+// newBus connects to the message bus backend of the configuration
+func newBus(cfg *config.Config) (bus.Bus, error) {
+	switch cfg.Bus.Backend {
+	case bus.BackendRedis:
+		return bus.NewRedisStreams(cfg.Redis)
+	case bus.BackendMemory:
+		slog.Warn("webhooks are kept in memory, they are lost on restart and not shared between instances")
+		return bus.NewMemory(), nil
+	default:
+		return kafka.Init(cfg.Kafka)
+	}
 }
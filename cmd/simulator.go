@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/logging"
+	"payment-gateway/internal/psp/defaultgateway/simulator"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runSimulator runs the default gateway simulator until SIGINT or SIGTERM and
+// returns the exit code
+func runSimulator(args []string) int {
+	flags := flag.NewFlagSet("simulator", flag.ContinueOnError)
+	addr := flags.String("addr", ":8090", "address the gateway API listens on")
+	var cfg simulator.Config
+	flags.StringVar(&cfg.WebhookURL, "webhook-url", "http://localhost:8080/webhook/default-gateway", "where webhooks are delivered")
+	flags.StringVar(&cfg.WebhookSecret, "webhook-secret", os.Getenv("GATEWAY_WEBHOOK_SECRET"), "signs the webhooks, defaults to GATEWAY_WEBHOOK_SECRET")
+	scenario := flags.String("scenario", string(simulator.Success), "scenario of the amounts that do not pick one: "+strings.Join(simulator.Scenarios(), ", "))
+	flags.DurationVar(&cfg.Delay, "delay", time.Second, "pause before each webhook")
+	flags.DurationVar(&cfg.LongDelay, "long-delay", 30*time.Second, "pause before the final webhook of the delayed scenario")
+	flags.IntVar(&cfg.Retries, "retries", 3, "extra delivery attempts of a webhook answered with an error")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	cfg.Scenario = simulator.Scenario(*scenario)
+
+	logging.Setup(config.Log{Level: "info", Format: "text"})
+	sim, err := simulator.New(cfg)
+	if err != nil {
+		slog.Error("failed to start the simulator", "error", err)
+		return 1
+	}
+
+	server := &http.Server{Addr: *addr, Handler: sim.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting default gateway simulator", "addr", server.Addr, "webhook_url", cfg.WebhookURL, "scenario", cfg.Scenario)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serverErr:
+		slog.Error("simulator stopped", "error", err)
+		return 1
+	case <-ctx.Done():
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("failed to drain simulator requests", "error", err)
+	}
+	sim.Close()
+	return 0
+}
@@ -1,28 +1,49 @@
 package db
 
 import (
+	"fmt"
 	"payment-gateway/db/db"
 	"payment-gateway/db/redis"
+	"payment-gateway/internal/config"
 )
 
 type DB struct {
-	DB    db.IDB
-	Redis redis.IRedis
+	DB            db.IDB
+	Admin         db.IAdminDB
+	Vault         db.IVaultDB
+	Beneficiaries db.IBeneficiaryDB
+	Risk          db.IRiskDB
+	Redis         redis.IRedis
 }
 
 // NewDB creates a new DB instance
-func NewDB() (*DB, error) {
-	redisClient, err := redis.Init()
+func NewDB(cfg *config.Config) (*DB, error) {
+	redisClient, err := redis.Init(cfg.Redis, cfg.GatewayScore)
 	if err != nil {
 		return nil, err
 	}
 	// Initialize the database connection
-	db, err := db.InitializeDB()
+	db, err := db.InitializeDB(cfg.Postgres)
 	if err != nil {
 		return nil, err
 	}
 	return &DB{
-		DB:    db,
-		Redis: redisClient,
+		DB:            db,
+		Admin:         db,
+		Vault:         db,
+		Beneficiaries: db,
+		Risk:          db,
+		Redis:         redisClient,
 	}, nil
 }
+
+// Close closes Postgres, then Redis
+func (d *DB) Close() error {
+	if err := d.DB.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %v", err)
+	}
+	if err := d.Redis.Close(); err != nil {
+		return fmt.Errorf("failed to close redis: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"payment-gateway/internal/models"
+	"time"
+)
+
+// ErrNotFound is returned when an admin update or delete matches no rows
+var ErrNotFound = fmt.Errorf("not found")
+
+// expectRow returns ErrNotFound when a statement affected no rows
+func expectRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateGateway updates the name and data format of a gateway
+func (d *DB) UpdateGateway(ctx context.Context, gateway Gateway) error {
+	err := expectRow(d.db.ExecContext(ctx, `UPDATE gateways SET name = $1, data_format_supported = $2, updated_at = $3 WHERE id = $4`,
+		gateway.Name, gateway.DataFormatSupported, time.Now(), gateway.ID))
+	if err != nil {
+		return fmt.Errorf("failed to update gateway %d: %w", gateway.ID, err)
+	}
+	return nil
+}
+
+// DeleteGateway deletes a gateway and its country mappings
+func (d *DB) DeleteGateway(ctx context.Context, gatewayID int) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM gateway_countries WHERE gateway_id = $1`, gatewayID); err != nil {
+		return fmt.Errorf("failed to delete mappings of gateway %d: %v", gatewayID, err)
+	}
+	if err := expectRow(tx.ExecContext(ctx, `DELETE FROM gateways WHERE id = $1`, gatewayID)); err != nil {
+		return fmt.Errorf("failed to delete gateway %d: %w", gatewayID, err)
+	}
+	return tx.Commit()
+}
+
+// SetGatewayEnabled enables or disables a gateway in every country
+func (d *DB) SetGatewayEnabled(ctx context.Context, gatewayID int, enabled bool) error {
+	err := expectRow(d.db.ExecContext(ctx, `UPDATE gateways SET enabled = $1, status_reason = '', status_updated_by = '', updated_at = $2 WHERE id = $3`,
+		enabled, time.Now(), gatewayID))
+	if err != nil {
+		return fmt.Errorf("failed to update gateway %d: %w", gatewayID, err)
+	}
+	return nil
+}
+
+// UpdateCountry updates the name, code and currency of a country
+func (d *DB) UpdateCountry(ctx context.Context, country Country) error {
+	err := expectRow(d.db.ExecContext(ctx, `UPDATE countries SET name = $1, code = $2, currency = $3, updated_at = $4 WHERE id = $5`,
+		country.Name, country.Code, country.Currency, time.Now(), country.ID))
+	if err != nil {
+		return fmt.Errorf("failed to update country %d: %w", country.ID, err)
+	}
+	return nil
+}
+
+// DeleteCountry deletes a country and its gateway mappings
+func (d *DB) DeleteCountry(ctx context.Context, countryID int) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM gateway_countries WHERE country_id = $1`, countryID); err != nil {
+		return fmt.Errorf("failed to delete mappings of country %d: %v", countryID, err)
+	}
+	if err := expectRow(tx.ExecContext(ctx, `DELETE FROM countries WHERE id = $1`, countryID)); err != nil {
+		return fmt.Errorf("failed to delete country %d: %w", countryID, err)
+	}
+	return tx.Commit()
+}
+
+// GetGatewayCountries fetches every gateway mapped to a country, including disabled ones
+func (d *DB) GetGatewayCountries(ctx context.Context, countryID int) ([]GatewayCountry, error) {
+	query := `
+		SELECT gc.gateway_id, g.name, gc.country_id, gc.enabled
+		FROM gateway_countries gc
+		JOIN gateways g ON g.id = gc.gateway_id
+		WHERE gc.country_id = $1
+		ORDER BY g.name
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, countryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gateway mappings for country %d: %v", countryID, err)
+	}
+	defer rows.Close()
+
+	var mappings []GatewayCountry
+	for rows.Next() {
+		var mapping GatewayCountry
+		if err := rows.Scan(&mapping.GatewayID, &mapping.GatewayName, &mapping.CountryID, &mapping.Enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan gateway mapping: %v", err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %v", err)
+	}
+	return mappings, nil
+}
+
+// AddGatewayCountry maps a gateway to a country, re-enabling an existing mapping
+func (d *DB) AddGatewayCountry(ctx context.Context, gatewayID, countryID int) error {
+	query := `
+		INSERT INTO gateway_countries (gateway_id, country_id, enabled)
+		VALUES ($1, $2, TRUE)
+		ON CONFLICT (gateway_id, country_id) DO UPDATE SET enabled = TRUE`
+
+	if _, err := d.db.ExecContext(ctx, query, gatewayID, countryID); err != nil {
+		return fmt.Errorf("failed to map gateway %d to country %d: %v", gatewayID, countryID, err)
+	}
+	return nil
+}
+
+// RemoveGatewayCountry deletes a gateway to country mapping
+func (d *DB) RemoveGatewayCountry(ctx context.Context, gatewayID, countryID int) error {
+	err := expectRow(d.db.ExecContext(ctx, `DELETE FROM gateway_countries WHERE gateway_id = $1 AND country_id = $2`,
+		gatewayID, countryID))
+	if err != nil {
+		return fmt.Errorf("failed to unmap gateway %d from country %d: %w", gatewayID, countryID, err)
+	}
+	return nil
+}
+
+// SetGatewayCountryEnabled enables or disables a gateway in a single country
+func (d *DB) SetGatewayCountryEnabled(ctx context.Context, gatewayID, countryID int, enabled bool) error {
+	err := expectRow(d.db.ExecContext(ctx, `UPDATE gateway_countries SET enabled = $1 WHERE gateway_id = $2 AND country_id = $3`,
+		enabled, gatewayID, countryID))
+	if err != nil {
+		return fmt.Errorf("failed to update gateway %d in country %d: %w", gatewayID, countryID, err)
+	}
+	return nil
+}
+
+// GetGatewayStates fetches the registry state of every gateway, a disabled gateway is paused
+func (d *DB) GetGatewayStates(ctx context.Context) ([]models.GatewayState, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT name, enabled, status_reason, status_updated_by, updated_at FROM gateways ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gateway states: %v", err)
+	}
+	defer rows.Close()
+
+	var states []models.GatewayState
+	for rows.Next() {
+		var state models.GatewayState
+		var enabled bool
+		if err := rows.Scan(&state.Name, &enabled, &state.Reason, &state.UpdatedBy, &state.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan gateway state: %v", err)
+		}
+		state.Status = models.GatewayEnabled
+		if !enabled {
+			state.Status = models.GatewayPaused
+		}
+		states = append(states, state)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %v", err)
+	}
+	return states, nil
+}
+
+// SetGatewayState enables or pauses a gateway by name through its enabled flag, it
+// returns the gateway ID or ErrNotFound for an unknown name
+func (d *DB) SetGatewayState(ctx context.Context, state models.GatewayState) (int, error) {
+	query := `
+		UPDATE gateways SET enabled = $1, status_reason = $2, status_updated_by = $3, updated_at = $4
+		WHERE name = $5
+		RETURNING id`
+
+	var gatewayID int
+	err := d.db.QueryRowContext(ctx, query, state.Status == models.GatewayEnabled, state.Reason, state.UpdatedBy, state.UpdatedAt, state.Name).Scan(&gatewayID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to update gateway %s: %w", state.Name, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to update gateway %s: %v", state.Name, err)
+	}
+	return gatewayID, nil
+}
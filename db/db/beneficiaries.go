@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"payment-gateway/internal/beneficiary"
+	"payment-gateway/internal/models"
+	"strconv"
+	"time"
+)
+
+const beneficiaryColumns = `id, user_id, nickname, country, currency, account_holder_type, last4,
+	bank_details_token, status, status_reason, verified_at, created_at`
+
+// scanBeneficiary scans a row selected with beneficiaryColumns
+func scanBeneficiary(row interface{ Scan(...interface{}) error }) (models.Beneficiary, error) {
+	var beneficiary models.Beneficiary
+	var userID int
+	var reason sql.NullString
+	var verifiedAt sql.NullTime
+	err := row.Scan(&beneficiary.ID, &userID, &beneficiary.Nickname, &beneficiary.Country, &beneficiary.Currency,
+		&beneficiary.AccountHolderType, &beneficiary.Last4, &beneficiary.BankDetailsToken, &beneficiary.Status,
+		&reason, &verifiedAt, &beneficiary.CreatedAt)
+	if err != nil {
+		return models.Beneficiary{}, err
+	}
+	beneficiary.UserID = strconv.Itoa(userID)
+	beneficiary.StatusReason = reason.String
+	if verifiedAt.Valid {
+		beneficiary.VerifiedAt = &verifiedAt.Time
+	}
+	return beneficiary, nil
+}
+
+// CreateBeneficiary inserts a beneficiary and returns it with its ID
+func (db *DB) CreateBeneficiary(ctx context.Context, b models.Beneficiary) (models.Beneficiary, error) {
+	userID, err := strconv.Atoi(b.UserID)
+	if err != nil {
+		return models.Beneficiary{}, fmt.Errorf("invalid user_id format: %v", err)
+	}
+
+	query := `
+		INSERT INTO beneficiaries (user_id, nickname, country, currency, account_holder_type, last4, bank_details_token, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + beneficiaryColumns
+
+	created, err := scanBeneficiary(db.db.QueryRowContext(ctx, query, userID, b.Nickname, b.Country, b.Currency,
+		b.AccountHolderType, b.Last4, b.BankDetailsToken, b.Status))
+	if err != nil {
+		return models.Beneficiary{}, fmt.Errorf("failed to create beneficiary: %v", err)
+	}
+	return created, nil
+}
+
+// GetBeneficiary fetches a beneficiary that has not been deleted
+func (db *DB) GetBeneficiary(ctx context.Context, id int64) (models.Beneficiary, error) {
+	query := `SELECT ` + beneficiaryColumns + ` FROM beneficiaries WHERE id = $1 AND deleted_at IS NULL`
+
+	b, err := scanBeneficiary(db.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return models.Beneficiary{}, beneficiary.ErrNotFound
+	}
+	if err != nil {
+		return models.Beneficiary{}, fmt.Errorf("failed to fetch beneficiary %d: %v", id, err)
+	}
+	return b, nil
+}
+
+// GetBeneficiariesByUser fetches the beneficiaries of a user, newest first
+func (db *DB) GetBeneficiariesByUser(ctx context.Context, userID string) ([]models.Beneficiary, error) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user_id format: %v", err)
+	}
+
+	query := `SELECT ` + beneficiaryColumns + ` FROM beneficiaries WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at DESC`
+	rows, err := db.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch beneficiaries: %v", err)
+	}
+	defer rows.Close()
+
+	var beneficiaries []models.Beneficiary
+	for rows.Next() {
+		b, err := scanBeneficiary(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan beneficiary: %v", err)
+		}
+		beneficiaries = append(beneficiaries, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %v", err)
+	}
+	return beneficiaries, nil
+}
+
+// DeleteBeneficiary soft deletes a beneficiary so past withdrawals keep their reference
+func (db *DB) DeleteBeneficiary(ctx context.Context, id int64) error {
+	err := expectRow(db.db.ExecContext(ctx, `UPDATE beneficiaries SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, time.Now(), id))
+	if err == ErrNotFound {
+		return beneficiary.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete beneficiary %d: %v", id, err)
+	}
+	return nil
+}
+
+// SetBeneficiaryStatus records the verification outcome of a beneficiary
+func (db *DB) SetBeneficiaryStatus(ctx context.Context, id int64, status, reason string) error {
+	query := `
+		UPDATE beneficiaries
+		SET status = $1, status_reason = NULLIF($2, ''), verified_at = CASE WHEN $1 = 'verified' THEN $3::timestamp END
+		WHERE id = $4 AND deleted_at IS NULL`
+
+	err := expectRow(db.db.ExecContext(ctx, query, status, reason, time.Now(), id))
+	if err == ErrNotFound {
+		return beneficiary.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update beneficiary %d: %v", id, err)
+	}
+	return nil
+}
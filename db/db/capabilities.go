@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"payment-gateway/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// GetGatewayCapabilitiesByCountry fetches the capability catalogue of every gateway in a country
+func (db *DB) GetGatewayCapabilitiesByCountry(ctx context.Context, countryID string) ([]models.GatewayCapability, error) {
+	query := `
+		SELECT gateway_id, country_id, currency, min_amount, max_amount,
+			supports_deposit, supports_withdrawal, payout_methods, data_format
+		FROM gateway_capabilities
+		WHERE country_id = $1
+		ORDER BY gateway_id, currency
+	`
+
+	rows, err := db.db.QueryContext(ctx, query, countryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gateway capabilities for country %s: %v", countryID, err)
+	}
+	defer rows.Close()
+
+	var capabilities []models.GatewayCapability
+	for rows.Next() {
+		var capability models.GatewayCapability
+		var maxAmount sql.NullInt64
+		if err := rows.Scan(&capability.GatewayID, &capability.CountryID, &capability.Currency,
+			&capability.MinAmount, &maxAmount, &capability.SupportsDeposit, &capability.SupportsWithdrawal,
+			pq.Array(&capability.PayoutMethods), &capability.DataFormat); err != nil {
+			return nil, fmt.Errorf("failed to scan gateway capability: %v", err)
+		}
+		capability.MaxAmount = maxAmount.Int64
+		capabilities = append(capabilities, capability)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %v", err)
+	}
+
+	return capabilities, nil
+}
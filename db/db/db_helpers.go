@@ -1,14 +1,17 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"payment-gateway/internal/config"
 	"payment-gateway/internal/services"
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/uptrace/opentelemetry-go-extra/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type DB struct {
@@ -16,20 +19,14 @@ type DB struct {
 }
 
 // InitializeDB initializes the database connection
-func InitializeDB() (*DB, error) {
+func InitializeDB(cfg config.Postgres) (*DB, error) {
 	var db *sql.DB
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-
-	dbURL := "postgres://" + dbUser + ":" + dbPassword + "@" + dbHost + ":" + dbPort + "/" + dbName + "?sslmode=disable"
+	dbURL := cfg.URL()
 
 	var err error
 
 	err = services.RetryOperation(func() error {
-		db, err = sql.Open("postgres", dbURL)
+		db, err = otelsql.Open("postgres", dbURL, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 		if err != nil {
 			return err
 		}
@@ -41,10 +38,20 @@ func InitializeDB() (*DB, error) {
 		return nil, fmt.Errorf("could not connect to the database: %v", err.Error())
 	}
 
-	log.Println("Successfully connected to the database.")
+	slog.Info("connected to the database")
 	return &DB{db}, nil
 }
 
+// Ping checks that the database is reachable
+func (d *DB) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+// Close closes the connection pool, waiting for running queries to finish
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
 func (d *DB) CreateUser(user User) error {
 	query := `INSERT INTO users (username, email, country_id, created_at, updated_at) 
 			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
@@ -77,19 +84,20 @@ func (d *DB) GetUsers() ([]User, error) {
 	return users, nil
 }
 
-func (d *DB) CreateGateway(gateway Gateway) error {
-	query := `INSERT INTO gateways (name, data_format_supported, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4) RETURNING id`
+func (d *DB) CreateGateway(ctx context.Context, gateway Gateway) (Gateway, error) {
+	query := `INSERT INTO gateways (name, data_format_supported, enabled, created_at, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
 
-	err := d.db.QueryRow(query, gateway.Name, gateway.DataFormatSupported, time.Now(), time.Now()).Scan(&gateway.ID)
+	err := d.db.QueryRowContext(ctx, query, gateway.Name, gateway.DataFormatSupported, gateway.Enabled, time.Now(), time.Now()).
+		Scan(&gateway.ID, &gateway.CreatedAt, &gateway.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to insert gateway: %v", err)
+		return gateway, fmt.Errorf("failed to insert gateway: %v", err)
 	}
-	return nil
+	return gateway, nil
 }
 
-func (d *DB) GetGateways() ([]Gateway, error) {
-	rows, err := d.db.Query(`SELECT id, name, data_format_supported, created_at, updated_at FROM gateways`)
+func (d *DB) GetGateways(ctx context.Context) ([]Gateway, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT id, name, data_format_supported, enabled, created_at, updated_at FROM gateways ORDER BY id`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch gateways: %v", err)
 	}
@@ -98,7 +106,7 @@ func (d *DB) GetGateways() ([]Gateway, error) {
 	var gateways []Gateway
 	for rows.Next() {
 		var gateway Gateway
-		if err := rows.Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.CreatedAt, &gateway.UpdatedAt); err != nil {
+		if err := rows.Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.Enabled, &gateway.CreatedAt, &gateway.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan gateway: %v", err)
 		}
 		gateways = append(gateways, gateway)
@@ -109,19 +117,20 @@ func (d *DB) GetGateways() ([]Gateway, error) {
 	return gateways, nil
 }
 
-func (d *DB) CreateCountry(country Country) error {
-	query := `INSERT INTO countries (name, code, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4) RETURNING id`
+func (d *DB) CreateCountry(ctx context.Context, country Country) (Country, error) {
+	query := `INSERT INTO countries (name, code, currency, created_at, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
 
-	err := d.db.QueryRow(query, country.Name, country.Code, time.Now(), time.Now()).Scan(&country.ID)
+	err := d.db.QueryRowContext(ctx, query, country.Name, country.Code, country.Currency, time.Now(), time.Now()).
+		Scan(&country.ID, &country.CreatedAt, &country.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to insert country: %v", err)
+		return country, fmt.Errorf("failed to insert country: %v", err)
 	}
-	return nil
+	return country, nil
 }
 
-func (d *DB) GetCountries() ([]Country, error) {
-	rows, err := d.db.Query(`SELECT id, name, code, created_at, updated_at FROM countries`)
+func (d *DB) GetCountries(ctx context.Context) ([]Country, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT id, name, code, currency, created_at, updated_at FROM countries ORDER BY id`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch countries: %v", err)
 	}
@@ -130,7 +139,7 @@ func (d *DB) GetCountries() ([]Country, error) {
 	var countries []Country
 	for rows.Next() {
 		var country Country
-		if err := rows.Scan(&country.ID, &country.Name, &country.Code, &country.CreatedAt, &country.UpdatedAt); err != nil {
+		if err := rows.Scan(&country.ID, &country.Name, &country.Code, &country.Currency, &country.CreatedAt, &country.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan country: %v", err)
 		}
 		countries = append(countries, country)
@@ -141,9 +150,10 @@ func (d *DB) GetCountries() ([]Country, error) {
 	return countries, nil
 }
 
-func (d *DB) CreateTransaction(transaction Transaction) error {
+// CreateTransaction inserts the transaction and posts it to the ledger in one database transaction
+func (d *DB) CreateTransaction(ctx context.Context, transaction Transaction) error {
 	// Start a transaction
-	tx, err := d.db.Begin()
+	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -162,9 +172,10 @@ func (d *DB) CreateTransaction(transaction Transaction) error {
 			order_id, amount, type, status, gateway_id, 
 			country_id, user_id, created_at, currency
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
+		ON CONFLICT (order_id, type) DO NOTHING
 		RETURNING id`
 
-	err = tx.QueryRow(queryTransaction,
+	err = tx.QueryRowContext(ctx, queryTransaction,
 		transaction.OrderID,
 		transaction.Amount,
 		transaction.Type,
@@ -175,6 +186,10 @@ func (d *DB) CreateTransaction(transaction Transaction) error {
 		time.Now(),
 		transaction.Currency,
 	).Scan(&transaction.ID)
+	if err == sql.ErrNoRows {
+		// Already posted by an earlier delivery of the same event, the deferred rollback ends tx
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to insert transaction: %v", err)
 	}
@@ -191,7 +206,7 @@ func (d *DB) CreateTransaction(transaction Transaction) error {
 		RETURNING id`
 
 	var ledgerID int
-	err = tx.QueryRow(queryLedger,
+	err = tx.QueryRowContext(ctx, queryLedger,
 		transaction.UserID,
 		transaction.Currency,
 		transaction.Amount,
@@ -231,7 +246,7 @@ func (d *DB) GetTransactions() ([]Transaction, error) {
 	return transactions, nil
 }
 
-func (d *DB) GetSupportedCountriesByGateway(gatewayID int) ([]Country, error) {
+func (d *DB) GetSupportedCountriesByGateway(ctx context.Context, gatewayID int) ([]Country, error) {
 	query := `
 		SELECT c.id AS country_id, c.name AS country_name
 		FROM countries c
@@ -240,7 +255,7 @@ func (d *DB) GetSupportedCountriesByGateway(gatewayID int) ([]Country, error) {
 		ORDER BY c.name
 	`
 
-	rows, err := d.db.Query(query, gatewayID)
+	rows, err := d.db.QueryContext(ctx, query, gatewayID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch countries for gateway %d: %v", gatewayID, err)
 	}
@@ -262,16 +277,21 @@ func (d *DB) GetSupportedCountriesByGateway(gatewayID int) ([]Country, error) {
 	return countries, nil
 }
 
-// CheckUserBalance checks if the user has sufficient balance for a withdrawal
-func (d *DB) CheckUserBalance(userID int, currency string, amount float64) (bool, float64, error) {
+// CheckUserBalance checks if the user has sufficient balance for a withdrawal.
+// Funds held for withdrawals pending approval are not available, except those of
+// holdID, the hold of the withdrawal itself once approved.
+func (d *DB) CheckUserBalance(ctx context.Context, userID int, currency string, amount float64, holdID int64) (bool, float64, error) {
 	var currentBalance float64
 
 	query := `
-		SELECT amount 
-		FROM ledger 
-		WHERE user_id = $1 AND currency = $2`
-
-	err := d.db.QueryRow(query, userID, currency).Scan(&currentBalance)
+		SELECT l.amount - COALESCE((
+			SELECT SUM(h.amount) FROM balance_holds h
+			WHERE h.user_id = l.user_id AND h.currency = l.currency AND h.released_at IS NULL AND h.id <> $3
+		), 0)
+		FROM ledger l
+		WHERE l.user_id = $1 AND l.currency = $2`
+
+	err := d.db.QueryRowContext(ctx, query, userID, currency, holdID).Scan(&currentBalance)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// No ledger entry exists for this user/currency
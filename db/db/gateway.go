@@ -1,21 +1,58 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"payment-gateway/internal/models"
 )
 
+// GetGateway fetches a gateway, it returns ErrNotFound for an unknown ID
+func (db *DB) GetGateway(ctx context.Context, gatewayID int) (Gateway, error) {
+	var gateway Gateway
+	err := db.db.QueryRowContext(ctx, `SELECT id, name, data_format_supported, enabled, created_at, updated_at FROM gateways WHERE id = $1`,
+		gatewayID).Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.Enabled, &gateway.CreatedAt, &gateway.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return Gateway{}, ErrNotFound
+	}
+	if err != nil {
+		return Gateway{}, fmt.Errorf("failed to fetch gateway %d: %v", gatewayID, err)
+	}
+	return gateway, nil
+}
+
+// GetGatewayCountry fetches the mapping of a gateway to a country, it returns ErrNotFound
+// when the gateway is not mapped to the country
+func (db *DB) GetGatewayCountry(ctx context.Context, gatewayID, countryID int) (GatewayCountry, error) {
+	query := `
+		SELECT gc.gateway_id, g.name, gc.country_id, gc.enabled
+		FROM gateway_countries gc
+		JOIN gateways g ON g.id = gc.gateway_id
+		WHERE gc.gateway_id = $1 AND gc.country_id = $2
+	`
+
+	var mapping GatewayCountry
+	err := db.db.QueryRowContext(ctx, query, gatewayID, countryID).Scan(&mapping.GatewayID, &mapping.GatewayName, &mapping.CountryID, &mapping.Enabled)
+	if err == sql.ErrNoRows {
+		return GatewayCountry{}, ErrNotFound
+	}
+	if err != nil {
+		return GatewayCountry{}, fmt.Errorf("failed to fetch gateway %d in country %d: %v", gatewayID, countryID, err)
+	}
+	return mapping, nil
+}
+
 // GetSupportedGatewaysByCountries fetches all gateways for a given country
-func (db *DB) GetSupportedGatewaysByCountries(countryID string) ([]models.Gateway, error) {
+func (db *DB) GetSupportedGatewaysByCountries(ctx context.Context, countryID string) ([]models.Gateway, error) {
 	query := `
 		SELECT g.id AS gateway_id, g.name AS gateway_name
 		FROM gateways g
 		JOIN gateway_countries gc ON g.id = gc.gateway_id
-		WHERE gc.country_id = $1
+		WHERE gc.country_id = $1 AND g.enabled AND gc.enabled
 		ORDER BY g.name
 	`
 
-	rows, err := db.db.Query(query, countryID)
+	rows, err := db.db.QueryContext(ctx, query, countryID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch gateways for country %s: %v", countryID, err)
 	}
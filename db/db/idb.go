@@ -1,9 +1,70 @@
 package db
 
-import "payment-gateway/internal/models"
+import (
+	"context"
+	"payment-gateway/internal/models"
+	"time"
+)
 
 type IDB interface {
-	CheckUserBalance(userID int, currency string, amount float64) (bool, float64, error)
-	GetSupportedGatewaysByCountries(countryID string) ([]models.Gateway, error)
-	CreateTransaction(transaction Transaction) error
+	Ping(ctx context.Context) error
+	Close() error
+	CheckUserBalance(ctx context.Context, userID int, currency string, amount float64, holdID int64) (bool, float64, error)
+	GetSupportedGatewaysByCountries(ctx context.Context, countryID string) ([]models.Gateway, error)
+	GetGateway(ctx context.Context, gatewayID int) (Gateway, error)
+	GetGatewayCountry(ctx context.Context, gatewayID, countryID int) (GatewayCountry, error)
+	CreateTransaction(ctx context.Context, transaction Transaction) error
+	GetRoutingRules(ctx context.Context) ([]models.RoutingRule, error)
+	GetUserSegment(ctx context.Context, userID string) (string, error)
+	GetGatewayCapabilitiesByCountry(ctx context.Context, countryID string) ([]models.GatewayCapability, error)
+}
+
+// IAdminDB manages gateways, countries and their mappings
+type IAdminDB interface {
+	CreateGateway(ctx context.Context, gateway Gateway) (Gateway, error)
+	GetGateways(ctx context.Context) ([]Gateway, error)
+	UpdateGateway(ctx context.Context, gateway Gateway) error
+	DeleteGateway(ctx context.Context, gatewayID int) error
+	SetGatewayEnabled(ctx context.Context, gatewayID int, enabled bool) error
+	CreateCountry(ctx context.Context, country Country) (Country, error)
+	GetCountries(ctx context.Context) ([]Country, error)
+	UpdateCountry(ctx context.Context, country Country) error
+	DeleteCountry(ctx context.Context, countryID int) error
+	GetSupportedCountriesByGateway(ctx context.Context, gatewayID int) ([]Country, error)
+	GetGatewayCountries(ctx context.Context, countryID int) ([]GatewayCountry, error)
+	AddGatewayCountry(ctx context.Context, gatewayID, countryID int) error
+	RemoveGatewayCountry(ctx context.Context, gatewayID, countryID int) error
+	SetGatewayCountryEnabled(ctx context.Context, gatewayID, countryID int, enabled bool) error
+	GetGatewayStates(ctx context.Context) ([]models.GatewayState, error)
+	SetGatewayState(ctx context.Context, state models.GatewayState) (int, error)
+}
+
+// IVaultDB persists the encrypted entries of the tokenization vault
+type IVaultDB interface {
+	SaveVaultEntry(ctx context.Context, entry models.VaultEntry) error
+	GetVaultEntry(ctx context.Context, token string) (models.VaultEntry, error)
+	GetVaultEntriesNotUsingKey(ctx context.Context, keyID string, limit int) ([]models.VaultEntry, error)
+	UpdateVaultEntryKey(ctx context.Context, token, ciphertext, keyID string) error
+}
+
+// IBeneficiaryDB persists the saved payout bank accounts of users
+type IBeneficiaryDB interface {
+	CreateBeneficiary(ctx context.Context, beneficiary models.Beneficiary) (models.Beneficiary, error)
+	GetBeneficiary(ctx context.Context, id int64) (models.Beneficiary, error)
+	GetBeneficiariesByUser(ctx context.Context, userID string) ([]models.Beneficiary, error)
+	DeleteBeneficiary(ctx context.Context, id int64) error
+	SetBeneficiaryStatus(ctx context.Context, id int64, status, reason string) error
+}
+
+// IRiskDB serves the history scored by the risk rules and persists the manual approval queue
+type IRiskDB interface {
+	CountTransactions(ctx context.Context, userID, txType string, since time.Time) (int64, error)
+	GetTransactionStats(ctx context.Context, userID, txType, currency string, since time.Time) (models.TransactionStats, error)
+	GetUserCountry(ctx context.Context, userID string) (string, error)
+	CreateRiskReview(ctx context.Context, review models.Review) (models.Review, error)
+	GetRiskReview(ctx context.Context, id int64) (models.Review, error)
+	GetRiskReviews(ctx context.Context, status string, limit int) ([]models.Review, error)
+	UpdateRiskReviewStatus(ctx context.Context, id int64, from, to, decidedBy, reason string, result []byte) error
+	HoldFunds(ctx context.Context, userID, currency string, amount int64) (int64, error)
+	ReleaseHold(ctx context.Context, holdID int64, userID, currency string) error
 }
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/risk"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ledgerType maps a transaction type to the type recorded in the transactions table
+func ledgerType(txType string) string {
+	if txType == "withdrawal" {
+		return "debit"
+	}
+	return "credit"
+}
+
+// CountTransactions counts the transactions of a user since a point in time
+func (db *DB) CountTransactions(ctx context.Context, userID, txType string, since time.Time) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM transactions WHERE user_id = $1 AND type = $2 AND created_at >= $3`
+	if err := db.db.QueryRowContext(ctx, query, userID, ledgerType(txType), since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count transactions for user %s: %v", userID, err)
+	}
+	return count, nil
+}
+
+// GetTransactionStats summarises the successful transactions of a user in a currency
+// since a point in time, in the smallest currency unit
+func (db *DB) GetTransactionStats(ctx context.Context, userID, txType, currency string, since time.Time) (models.TransactionStats, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(AVG(ABS(amount)), 0) * 100, COALESCE(MAX(ABS(amount)), 0) * 100
+		FROM transactions
+		WHERE user_id = $1 AND type = $2 AND status = 'success' AND LOWER(currency) = LOWER($3) AND created_at >= $4`
+
+	var stats models.TransactionStats
+	if err := db.db.QueryRowContext(ctx, query, userID, ledgerType(txType), currency, since).Scan(&stats.Count, &stats.Average, &stats.Max); err != nil {
+		return models.TransactionStats{}, fmt.Errorf("failed to fetch transaction stats for user %s: %v", userID, err)
+	}
+	return stats, nil
+}
+
+// GetUserCountry returns the country ID a user registered with, or an empty string for unknown users
+func (db *DB) GetUserCountry(ctx context.Context, userID string) (string, error) {
+	var countryID int
+	err := db.db.QueryRowContext(ctx, `SELECT country_id FROM users WHERE id = $1`, userID).Scan(&countryID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch country for user %s: %v", userID, err)
+	}
+	return strconv.Itoa(countryID), nil
+}
+
+const riskReviewColumns = `id, type, user_id, country_id, currency, amount, gateway_id, signals, payload,
+	status, requested_by, hold_id, result, decided_by, reason, created_at, decided_at`
+
+// scanRiskReview scans a row selected with riskReviewColumns
+func scanRiskReview(row interface{ Scan(...interface{}) error }) (models.Review, error) {
+	var review models.Review
+	var userID, countryID, gatewayID int
+	var signals []byte
+	var holdID sql.NullInt64
+	var result, decidedBy, reason sql.NullString
+	var decidedAt sql.NullTime
+	err := row.Scan(&review.ID, &review.Type, &userID, &countryID, &review.Currency, &review.Amount, &gatewayID,
+		&signals, &review.Payload, &review.Status, &review.RequestedBy, &holdID, &result, &decidedBy, &reason,
+		&review.CreatedAt, &decidedAt)
+	if err != nil {
+		return models.Review{}, err
+	}
+	if err := json.Unmarshal(signals, &review.Signals); err != nil {
+		return models.Review{}, fmt.Errorf("failed to decode review signals: %v", err)
+	}
+	review.UserID = strconv.Itoa(userID)
+	review.CountryID = strconv.Itoa(countryID)
+	review.GatewayID = strconv.Itoa(gatewayID)
+	review.HoldID = holdID.Int64
+	if result.Valid {
+		review.Result = json.RawMessage(result.String)
+	}
+	review.DecidedBy = decidedBy.String
+	review.Reason = reason.String
+	if decidedAt.Valid {
+		review.DecidedAt = &decidedAt.Time
+	}
+	return review, nil
+}
+
+// CreateRiskReview adds a transaction to the manual approval queue
+func (db *DB) CreateRiskReview(ctx context.Context, review models.Review) (models.Review, error) {
+	signals, err := json.Marshal(review.Signals)
+	if err != nil {
+		return models.Review{}, fmt.Errorf("failed to encode review signals: %v", err)
+	}
+
+	query := `
+		INSERT INTO risk_reviews (type, user_id, country_id, currency, amount, gateway_id, signals, payload, status, requested_by, hold_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, 0))
+		RETURNING ` + riskReviewColumns
+
+	created, err := scanRiskReview(db.db.QueryRowContext(ctx, query, review.Type, review.UserID, review.CountryID, review.Currency,
+		review.Amount, review.GatewayID, signals, review.Payload, review.Status, review.RequestedBy, review.HoldID))
+	if err != nil {
+		return models.Review{}, fmt.Errorf("failed to create risk review: %v", err)
+	}
+	return created, nil
+}
+
+// GetRiskReview fetches a review
+func (db *DB) GetRiskReview(ctx context.Context, id int64) (models.Review, error) {
+	review, err := scanRiskReview(db.db.QueryRowContext(ctx, `SELECT `+riskReviewColumns+` FROM risk_reviews WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return models.Review{}, risk.ErrReviewNotFound
+	}
+	if err != nil {
+		return models.Review{}, fmt.Errorf("failed to fetch risk review %d: %v", id, err)
+	}
+	return review, nil
+}
+
+// GetRiskReviews fetches the reviews in a status, oldest first. An empty status returns every review.
+func (db *DB) GetRiskReviews(ctx context.Context, status string, limit int) ([]models.Review, error) {
+	query := `SELECT ` + riskReviewColumns + ` FROM risk_reviews WHERE ($1 = '' OR status = $1) ORDER BY created_at, id LIMIT $2`
+	rows, err := db.db.QueryContext(ctx, query, strings.ToLower(status), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch risk reviews: %v", err)
+	}
+	defer rows.Close()
+
+	var reviews []models.Review
+	for rows.Next() {
+		review, err := scanRiskReview(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan risk review: %v", err)
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %v", err)
+	}
+	return reviews, nil
+}
+
+// UpdateRiskReviewStatus moves a review between statuses. The expected current status
+// is part of the update so concurrent reviewers cannot both decide the same review.
+func (db *DB) UpdateRiskReviewStatus(ctx context.Context, id int64, from, to, decidedBy, reason string, result []byte) error {
+	query := `
+		UPDATE risk_reviews
+		SET status = $1, decided_by = NULLIF($2, ''), reason = NULLIF($3, ''), result = COALESCE($4::jsonb, result), decided_at = $5
+		WHERE id = $6 AND status = $7`
+
+	var encoded interface{}
+	if result != nil {
+		encoded = string(result)
+	}
+	err := expectRow(db.db.ExecContext(ctx, query, to, decidedBy, reason, encoded, time.Now(), id, from))
+	if err == ErrNotFound {
+		if _, err := db.GetRiskReview(ctx, id); err != nil {
+			return err
+		}
+		return risk.ErrReviewDecided
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update risk review %d: %v", id, err)
+	}
+	return nil
+}
+
+// HoldFunds reserves an amount in the smallest currency unit against the user's ledger
+// balance. The ledger row is locked so concurrent holds cannot overdraw it.
+func (db *DB) HoldFunds(ctx context.Context, userID, currency string, amount int64) (int64, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	currency = strings.ToLower(currency)
+	var available float64
+	query := `
+		SELECT l.amount - COALESCE((
+			SELECT SUM(h.amount) FROM balance_holds h
+			WHERE h.user_id = l.user_id AND h.currency = l.currency AND h.released_at IS NULL
+		), 0)
+		FROM ledger l
+		WHERE l.user_id = $1 AND l.currency = $2
+		FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, userID, currency).Scan(&available)
+	if err == sql.ErrNoRows {
+		return 0, risk.ErrInsufficientFunds
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check balance: %v", err)
+	}
+	held := float64(amount) / 100
+	if available < held {
+		return 0, risk.ErrInsufficientFunds
+	}
+
+	var holdID int64
+	err = tx.QueryRowContext(ctx, `INSERT INTO balance_holds (user_id, currency, amount) VALUES ($1, $2, $3) RETURNING id`,
+		userID, currency, held).Scan(&holdID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to hold funds: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return holdID, nil
+}
+
+// ReleaseHold returns funds held for a user in a currency to the available balance.
+// Releasing a released hold succeeds; it returns risk.ErrHoldNotFound when the hold
+// is not the user's in that currency.
+func (db *DB) ReleaseHold(ctx context.Context, holdID int64, userID, currency string) error {
+	result, err := db.db.ExecContext(ctx, `UPDATE balance_holds SET released_at = COALESCE(released_at, $1)
+		WHERE id = $2 AND user_id = $3 AND currency = $4`, time.Now(), holdID, userID, strings.ToLower(currency))
+	if err != nil {
+		return fmt.Errorf("failed to release hold %d: %v", holdID, err)
+	}
+	if released, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to release hold %d: %v", holdID, err)
+	} else if released == 0 {
+		return risk.ErrHoldNotFound
+	}
+	return nil
+}
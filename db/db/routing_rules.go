@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"payment-gateway/internal/models"
+	"strconv"
+)
+
+// GetRoutingRules fetches all enabled routing rules ordered by priority
+func (db *DB) GetRoutingRules(ctx context.Context) ([]models.RoutingRule, error) {
+	query := `
+		SELECT id, name, priority, action, gateway_id, transaction_type, country_id, currency,
+			min_amount, max_amount, user_segment, method, start_hour, end_hour, enabled
+		FROM routing_rules
+		WHERE enabled
+		ORDER BY priority, id
+	`
+
+	rows, err := db.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch routing rules: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []models.RoutingRule
+	for rows.Next() {
+		var rule models.RoutingRule
+		var transactionType, currency, userSegment, method sql.NullString
+		var countryID, startHour, endHour sql.NullInt32
+		var minAmount, maxAmount sql.NullInt64
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.Priority, &rule.Action, &rule.GatewayID,
+			&transactionType, &countryID, &currency, &minAmount, &maxAmount, &userSegment, &method,
+			&startHour, &endHour, &rule.Enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan routing rule: %v", err)
+		}
+
+		rule.TransactionType = transactionType.String
+		rule.Currency = currency.String
+		rule.UserSegment = userSegment.String
+		rule.Method = method.String
+		if countryID.Valid {
+			rule.CountryID = strconv.Itoa(int(countryID.Int32))
+		}
+		if minAmount.Valid {
+			rule.MinAmount = &minAmount.Int64
+		}
+		if maxAmount.Valid {
+			rule.MaxAmount = &maxAmount.Int64
+		}
+		if startHour.Valid {
+			hour := int(startHour.Int32)
+			rule.StartHour = &hour
+		}
+		if endHour.Valid {
+			hour := int(endHour.Int32)
+			rule.EndHour = &hour
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %v", err)
+	}
+
+	return rules, nil
+}
+
+// GetUserSegment returns the segment of a user, or an empty string when none is assigned
+func (db *DB) GetUserSegment(ctx context.Context, userID string) (string, error) {
+	var segment string
+	err := db.db.QueryRowContext(ctx, `SELECT segment FROM user_segments WHERE user_id = $1`, userID).Scan(&segment)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to fetch segment for user %s: %v", userID, err)
+	}
+	return segment, nil
+}
@@ -12,19 +12,29 @@ type User struct {
 }
 
 type Gateway struct {
-	ID                  int
-	Name                string
-	DataFormatSupported string
-	CreatedAt           time.Time
-	UpdatedAt           time.Time
+	ID                  int       `json:"id"`
+	Name                string    `json:"name"`
+	DataFormatSupported string    `json:"data_format_supported"`
+	Enabled             bool      `json:"enabled"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
 }
 
 type Country struct {
-	ID        int
-	Name      string
-	Code      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Code      string    `json:"code"`
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GatewayCountry is a gateway to country mapping
+type GatewayCountry struct {
+	GatewayID   int    `json:"gateway_id"`
+	GatewayName string `json:"gateway_name"`
+	CountryID   int    `json:"country_id"`
+	Enabled     bool   `json:"enabled"` // Enabled for this country
 }
 
 type Transaction struct {
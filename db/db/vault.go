@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/vault"
+	"strconv"
+)
+
+// SaveVaultEntry stores an encrypted vault entry
+func (db *DB) SaveVaultEntry(ctx context.Context, entry models.VaultEntry) error {
+	userID, err := strconv.Atoi(entry.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid user_id format: %v", err)
+	}
+
+	query := `
+		INSERT INTO vault_tokens (token, user_id, ciphertext, key_id, last4, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	if _, err := db.db.ExecContext(ctx, query, entry.Token, userID, entry.Ciphertext, entry.KeyID, entry.Last4, entry.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save vault entry: %v", err)
+	}
+	return nil
+}
+
+// GetVaultEntry fetches a vault entry by token
+func (db *DB) GetVaultEntry(ctx context.Context, token string) (models.VaultEntry, error) {
+	query := `
+		SELECT token, user_id, ciphertext, key_id, last4, created_at
+		FROM vault_tokens
+		WHERE token = $1`
+
+	var entry models.VaultEntry
+	var userID int
+	err := db.db.QueryRowContext(ctx, query, token).Scan(&entry.Token, &userID, &entry.Ciphertext, &entry.KeyID, &entry.Last4, &entry.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.VaultEntry{}, vault.ErrTokenNotFound
+	}
+	if err != nil {
+		return models.VaultEntry{}, fmt.Errorf("failed to fetch vault entry: %v", err)
+	}
+	entry.UserID = strconv.Itoa(userID)
+	return entry, nil
+}
+
+// GetVaultEntriesNotUsingKey fetches entries wrapped by any key other than keyID
+func (db *DB) GetVaultEntriesNotUsingKey(ctx context.Context, keyID string, limit int) ([]models.VaultEntry, error) {
+	query := `
+		SELECT token, user_id, ciphertext, key_id, last4, created_at
+		FROM vault_tokens
+		WHERE key_id <> $1
+		ORDER BY created_at
+		LIMIT $2`
+
+	rows, err := db.db.QueryContext(ctx, query, keyID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch vault entries: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.VaultEntry
+	for rows.Next() {
+		var entry models.VaultEntry
+		var userID int
+		if err := rows.Scan(&entry.Token, &userID, &entry.Ciphertext, &entry.KeyID, &entry.Last4, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan vault entry: %v", err)
+		}
+		entry.UserID = strconv.Itoa(userID)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %v", err)
+	}
+	return entries, nil
+}
+
+// UpdateVaultEntryKey replaces the ciphertext of an entry after its data key was rewrapped
+func (db *DB) UpdateVaultEntryKey(ctx context.Context, token, ciphertext, keyID string) error {
+	query := `UPDATE vault_tokens SET ciphertext = $1, key_id = $2 WHERE token = $3`
+	if _, err := db.db.ExecContext(ctx, query, ciphertext, keyID, token); err != nil {
+		return fmt.Errorf("failed to update vault entry: %v", err)
+	}
+	return nil
+}
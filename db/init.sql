@@ -5,6 +5,7 @@ BEGIN
             id SERIAL PRIMARY KEY,
             name VARCHAR(255) NOT NULL UNIQUE,
             data_format_supported VARCHAR(50) NOT NULL,  
+            enabled BOOLEAN NOT NULL DEFAULT TRUE,
             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
             updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP  
         );
@@ -31,6 +32,7 @@ BEGIN
         CREATE TABLE gateway_countries (
             gateway_id INT NOT NULL, 
             country_id INT NOT NULL,
+            enabled BOOLEAN NOT NULL DEFAULT TRUE,
             PRIMARY KEY (gateway_id, country_id)
         );
     END IF;
@@ -90,6 +92,14 @@ BEGIN
 END $$;
 
 
+-- Enable flags for databases created before gateways could be disabled
+ALTER TABLE gateways ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE gateway_countries ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Why and by whom a gateway was last paused or enabled through the registry
+ALTER TABLE gateways ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE gateways ADD COLUMN IF NOT EXISTS status_updated_by VARCHAR(255) NOT NULL DEFAULT '';
+
 -- Insert initial data into countries table
 INSERT INTO countries (name, code, currency)
 VALUES
@@ -112,7 +122,10 @@ VALUES
   (2, 1),
   (2, 3),
   (7, 1),
-  (7, 3)
+  (7, 3),
+  (1, 2),
+  (3, 1),
+  (3, 2)
 ON CONFLICT DO NOTHING;
 
 INSERT INTO users (username, email, password, country_id)
@@ -121,3 +134,172 @@ VALUES
     ('john_doe', 'johndoe@example.com', '$2a$10$anotherhashedpasswordhere', 2)
 ON CONFLICT (name) DO NOTHING;
 
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'user_segments') THEN
+        CREATE TABLE user_segments (
+            user_id INT PRIMARY KEY,
+            segment VARCHAR(50) NOT NULL,          -- e.g. 'vip', 'retail'
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'routing_rules') THEN
+        CREATE TABLE routing_rules (
+            id SERIAL PRIMARY KEY,
+            name VARCHAR(255) NOT NULL UNIQUE,
+            priority INT NOT NULL,                 -- Lower values are evaluated first
+            action VARCHAR(20) NOT NULL CHECK (action IN ('route', 'prefer', 'exclude')),
+            gateway_id INT NOT NULL,
+            transaction_type VARCHAR(20),          -- 'deposit', 'withdrawal' or NULL for both
+            country_id INT,
+            currency VARCHAR(10),
+            min_amount BIGINT,                     -- Inclusive, in the smallest currency unit
+            max_amount BIGINT,                     -- Inclusive, in the smallest currency unit
+            user_segment VARCHAR(50),
+            method VARCHAR(20),                    -- 'standard' or 'instant' payouts
+            start_hour INT CHECK (start_hour BETWEEN 0 AND 23), -- UTC, window wraps midnight when start_hour > end_hour
+            end_hour INT CHECK (end_hour BETWEEN 0 AND 23),
+            enabled BOOLEAN NOT NULL DEFAULT TRUE,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+
+        CREATE INDEX idx_routing_rules_priority ON routing_rules(priority) WHERE enabled;
+    END IF;
+END $$;
+
+INSERT INTO routing_rules (name, priority, action, gateway_id, transaction_type, currency, min_amount, method, user_segment)
+VALUES
+  ('large INR deposits via razorpay', 10, 'route', 2, 'deposit', 'INR', 1000001, NULL, NULL),
+  ('AED instant payouts via default gateway', 20, 'route', 3, 'withdrawal', 'AED', NULL, 'instant', NULL),
+  ('VIP users prefer stripe', 100, 'prefer', 1, NULL, NULL, NULL, NULL, 'vip')
+ON CONFLICT (name) DO NOTHING;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'gateway_capabilities') THEN
+        CREATE TABLE gateway_capabilities (
+            gateway_id INT NOT NULL,
+            country_id INT NOT NULL,
+            currency VARCHAR(10) NOT NULL,
+            min_amount BIGINT NOT NULL DEFAULT 0,  -- Inclusive, in the smallest currency unit
+            max_amount BIGINT,                     -- Inclusive, NULL for no upper limit
+            supports_deposit BOOLEAN NOT NULL DEFAULT TRUE,
+            supports_withdrawal BOOLEAN NOT NULL DEFAULT FALSE,
+            payout_methods TEXT[] NOT NULL DEFAULT '{}', -- 'standard' and/or 'instant'
+            data_format VARCHAR(50) NOT NULL DEFAULT 'JSON',
+            PRIMARY KEY (gateway_id, country_id, currency)
+        );
+    END IF;
+END $$;
+
+INSERT INTO gateway_capabilities (gateway_id, country_id, currency, min_amount, max_amount, supports_deposit, supports_withdrawal, payout_methods, data_format)
+VALUES
+  (1, 2, 'AED', 200, 99999999, TRUE, TRUE, '{standard,instant}', 'JSON'),
+  (2, 1, 'INR', 100, 50000000, TRUE, FALSE, '{}', 'JSON'),
+  (3, 1, 'INR', 100, 10000000, TRUE, TRUE, '{standard}', 'JSON'),
+  (3, 2, 'AED', 100, 10000000, TRUE, TRUE, '{standard,instant}', 'JSON')
+ON CONFLICT DO NOTHING;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'vault_tokens') THEN
+        CREATE TABLE vault_tokens (
+            token VARCHAR(64) PRIMARY KEY,         -- Opaque token handed to the client
+            user_id INT NOT NULL,
+            ciphertext TEXT NOT NULL,              -- Envelope encrypted bank details
+            key_id VARCHAR(64) NOT NULL,           -- Key-encryption key that wrapped the data key
+            last4 VARCHAR(4) NOT NULL DEFAULT '',
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users(id)
+        );
+        CREATE INDEX vault_tokens_key_id_idx ON vault_tokens (key_id);
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'beneficiaries') THEN
+        CREATE TABLE beneficiaries (
+            id BIGSERIAL PRIMARY KEY,
+            user_id INT NOT NULL,
+            nickname VARCHAR(64) NOT NULL DEFAULT '',
+            country VARCHAR(2) NOT NULL,
+            currency VARCHAR(10) NOT NULL,
+            account_holder_type VARCHAR(20) NOT NULL,
+            last4 VARCHAR(4) NOT NULL DEFAULT '',
+            bank_details_token VARCHAR(64) NOT NULL REFERENCES vault_tokens(token),
+            status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'verified' or 'rejected'
+            status_reason TEXT,
+            verified_at TIMESTAMP,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            deleted_at TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users(id)
+        );
+        CREATE INDEX beneficiaries_user_id_idx ON beneficiaries (user_id) WHERE deleted_at IS NULL;
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'risk_reviews') THEN
+        CREATE TABLE risk_reviews (
+            id BIGSERIAL PRIMARY KEY,
+            type VARCHAR(20) NOT NULL,             -- 'deposit' or 'withdrawal'
+            user_id INT NOT NULL,
+            country_id INT NOT NULL,
+            currency VARCHAR(10) NOT NULL,
+            amount BIGINT NOT NULL,                -- In the smallest currency unit
+            gateway_id INT NOT NULL,
+            signals JSONB NOT NULL DEFAULT '[]',   -- Risk rules that sent the transaction to review
+            payload TEXT NOT NULL,                 -- Envelope encrypted request, replayed on approval
+            status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'processing', 'approved', 'rejected' or 'failed'
+            requested_by VARCHAR(255) NOT NULL DEFAULT '', -- Caller that made the request, may not approve it
+            hold_id BIGINT,                        -- Funds held while a withdrawal is pending
+            result JSONB,                          -- Gateway response once approved
+            decided_by VARCHAR(255),
+            reason TEXT,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            decided_at TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users(id)
+        );
+        CREATE INDEX risk_reviews_status_idx ON risk_reviews (status, created_at);
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'balance_holds') THEN
+        CREATE TABLE balance_holds (
+            id BIGSERIAL PRIMARY KEY,
+            user_id VARCHAR(255) NOT NULL,         -- Matches ledger.user_id
+            currency VARCHAR(10) NOT NULL,
+            amount DECIMAL(15, 2) NOT NULL,        -- Same unit as ledger.amount
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            released_at TIMESTAMP                  -- NULL while the funds are held
+        );
+        CREATE INDEX balance_holds_active_idx ON balance_holds (user_id, currency) WHERE released_at IS NULL;
+    END IF;
+END $$;
+
+-- Registry states used to live in their own table, they are the gateways' enabled flag now
+DO $$ 
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'gateway_states') THEN
+        UPDATE gateways g
+        SET enabled = (s.status <> 'paused'), status_reason = s.reason, status_updated_by = s.updated_by
+        FROM gateway_states s
+        WHERE g.name = s.name;
+        DROP TABLE gateway_states;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS transactions_user_type_idx ON transactions (user_id, type, created_at);
+
+-- A redelivered webhook must not post the same order twice
+CREATE UNIQUE INDEX IF NOT EXISTS transactions_order_type_idx ON transactions (order_id, type);
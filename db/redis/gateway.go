@@ -5,57 +5,214 @@ import (
 	"fmt"
 	"payment-gateway/internal/models"
 	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
 )
 
-func (s *RedisClient) SaveGatewaysToRedisHashSet(ctx context.Context, countryID string, gateways []models.Gateway) error {
-	pipeline := s.client.Pipeline()
-	for _, gateway := range gateways {
-		key := fmt.Sprintf("gateway-by-country:%s:%s", countryID, gateway.ID)
-		pipeline.HSet(ctx, key, "gateway_name", gateway.Name, "score", 1)
+// rankingKey is the sorted set holding a country's gateway IDs scored by success rate.
+func rankingKey(countryID string) string {
+	return fmt.Sprintf("gateway-ranking:%s", countryID)
+}
+
+// detailsKey is the companion hash holding gateway names, ratios and sample sizes
+// as "<gatewayID>:name", "<gatewayID>:ratio" and "<gatewayID>:samples" fields.
+func detailsKey(countryID string) string {
+	return fmt.Sprintf("gateway-details:%s", countryID)
+}
+
+// SaveGatewaysByCountry replaces the ranking of a country with the given gateways,
+// scored from their current outcome windows.
+func (s *RedisClient) SaveGatewaysByCountry(ctx context.Context, countryID string, gateways []models.Gateway) error {
+	scored, err := s.scoreGateways(ctx, countryID, gateways)
+	if err != nil {
+		return err
 	}
-	_, err := pipeline.Exec(ctx)
+
+	pipeline := s.client.TxPipeline()
+	pipeline.Del(ctx, rankingKey(countryID), detailsKey(countryID))
+	s.queueRanking(ctx, pipeline, countryID, scored)
+	_, err = pipeline.Exec(ctx)
 	return err
 }
 
+// GetGatewaysByCountry returns the ranked gateways of a country in a single round trip.
+// The ranking is rescored when it is older than one bucket so that expired outcomes decay.
 func (s *RedisClient) GetGatewaysByCountry(ctx context.Context, countryID string) ([]models.Gateway, error) {
-	pattern := fmt.Sprintf("gateway-by-country:%s:*", countryID)
-	keys, err := s.client.Keys(ctx, pattern).Result()
-	if err != nil {
+	pipeline := s.client.Pipeline()
+	ranking := pipeline.ZRevRangeWithScores(ctx, rankingKey(countryID), 0, -1)
+	details := pipeline.HGetAll(ctx, detailsKey(countryID))
+	if _, err := pipeline.Exec(ctx); err != nil && err != redis.Nil {
 		return nil, err
 	}
 
 	var gateways []models.Gateway
-	for _, key := range keys {
-		data, err := s.client.HGetAll(ctx, key).Result()
-		if err != nil {
-			return nil, err
-		}
-		if len(data) == 0 {
-			continue
-		}
+	data := details.Val()
+	for _, member := range ranking.Val() {
+		id, _ := member.Member.(string)
+		ratio, _ := strconv.ParseFloat(data[id+":ratio"], 64)
+		samples, _ := strconv.ParseInt(data[id+":samples"], 10, 64)
+		gateways = append(gateways, models.Gateway{
+			ID:           id,
+			Name:         data[id+":name"],
+			Score:        member.Score,
+			SuccessRatio: ratio,
+			SampleSize:   samples,
+		})
+	}
+	if len(gateways) == 0 {
+		return gateways, nil
+	}
 
-		score, _ := s.client.HGet(ctx, key, "score").Int()
-		gateway := models.Gateway{
-			ID:    key[len(pattern)-1:], // Extracting gateway ID from key
-			Name:  data["gateway_name"],
-			Score: score,
+	refreshedAt, _ := strconv.ParseInt(data["refreshed_at"], 10, 64)
+	if s.clock().Unix()-refreshedAt < int64(s.scoreConfig().BucketSize.Seconds()) {
+		return gateways, nil
+	}
+
+	gateways, err := s.scoreGateways(ctx, countryID, gateways)
+	if err != nil {
+		return nil, err
+	}
+	refresh := s.client.TxPipeline()
+	s.queueRanking(ctx, refresh, countryID, gateways)
+	if _, err := refresh.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return gateways, nil
+}
+
+// scoreGateways reads the outcome window of every gateway in one round trip and
+// returns them sorted by score.
+func (s *RedisClient) scoreGateways(ctx context.Context, countryID string, gateways []models.Gateway) ([]models.Gateway, error) {
+	if len(gateways) == 0 {
+		return gateways, nil
+	}
+
+	pipeline := s.client.Pipeline()
+	stats := make([][]*redis.MapStringStringCmd, len(gateways))
+	for i, gateway := range gateways {
+		stats[i] = s.queueGatewayStats(ctx, pipeline, countryID, gateway.ID)
+	}
+	if _, err := pipeline.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	cfg := s.scoreConfig()
+	scored := make([]models.Gateway, len(gateways))
+	for i, gateway := range gateways {
+		buckets := make([]map[string]string, len(stats[i]))
+		for j, cmd := range stats[i] {
+			buckets[j] = cmd.Val()
 		}
-		gateways = append(gateways, gateway)
+		gateway.Score, gateway.SuccessRatio, gateway.SampleSize = scoreFromBuckets(cfg, buckets)
+		scored[i] = gateway
 	}
 
-	sort.Slice(gateways, func(i, j int) bool {
-		return gateways[i].Score > gateways[j].Score
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
 	})
+	return scored, nil
+}
 
-	return gateways, nil
+// queueRanking queues the writes of a country's ranking and companion hash on the pipeline.
+func (s *RedisClient) queueRanking(ctx context.Context, pipeline redis.Pipeliner, countryID string, gateways []models.Gateway) {
+	members := make([]redis.Z, len(gateways))
+	fields := []interface{}{"refreshed_at", s.clock().Unix()}
+	for i, gateway := range gateways {
+		members[i] = redis.Z{Score: gateway.Score, Member: gateway.ID}
+		fields = append(fields,
+			gateway.ID+":name", gateway.Name,
+			gateway.ID+":ratio", gateway.SuccessRatio,
+			gateway.ID+":samples", gateway.SampleSize,
+		)
+	}
+	pipeline.ZAdd(ctx, rankingKey(countryID), members...)
+	pipeline.HSet(ctx, detailsKey(countryID), fields...)
 }
 
-func (s *RedisClient) IncrementGatewayScore(ctx context.Context, countryID string, gatewayID string) error {
-	key := fmt.Sprintf("gateway-by-country:%s:%s", countryID, gatewayID)
-	return s.client.HIncrBy(ctx, key, "score", 1).Err()
+// refreshGatewayScore rescores a single gateway after a new outcome is recorded.
+// Gateways that are not part of the country's ranking are left alone.
+func (s *RedisClient) refreshGatewayScore(ctx context.Context, countryID string, gatewayID string) error {
+	pipeline := s.client.Pipeline()
+	current := pipeline.ZScore(ctx, rankingKey(countryID), gatewayID)
+	stats := s.queueGatewayStats(ctx, pipeline, countryID, gatewayID)
+	if _, err := pipeline.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+	if current.Err() == redis.Nil {
+		return nil
+	}
+
+	buckets := make([]map[string]string, len(stats))
+	for i, cmd := range stats {
+		buckets[i] = cmd.Val()
+	}
+	score, ratio, samples := scoreFromBuckets(s.scoreConfig(), buckets)
+
+	update := s.client.TxPipeline()
+	update.ZAddXX(ctx, rankingKey(countryID), redis.Z{Score: score, Member: gatewayID})
+	update.HSet(ctx, detailsKey(countryID), gatewayID+":ratio", ratio, gatewayID+":samples", samples)
+	_, err := update.Exec(ctx)
+	return err
+}
+
+// MigrateLegacyGatewayKeys moves gateways cached under the old per-gateway
+// "gateway-by-country:<countryID>:<gatewayID>" hashes into the sorted-set ranking
+// and deletes the old keys. It uses SCAN so it never blocks Redis and is safe to rerun.
+func (s *RedisClient) MigrateLegacyGatewayKeys(ctx context.Context) error {
+	byCountry := make(map[string][]models.Gateway)
+	var legacyKeys []string
+
+	iter := s.client.Scan(ctx, 0, "gateway-by-country:*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		parts := strings.Split(key, ":")
+		if len(parts) != 3 {
+			continue
+		}
+		name, err := s.client.HGet(ctx, key, "gateway_name").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		byCountry[parts[1]] = append(byCountry[parts[1]], models.Gateway{ID: parts[2], Name: name})
+		legacyKeys = append(legacyKeys, key)
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(legacyKeys) == 0 {
+		return nil
+	}
+
+	countries := make([]string, 0, len(byCountry))
+	for countryID := range byCountry {
+		countries = append(countries, countryID)
+	}
+	sort.Strings(countries)
+
+	cfg := s.scoreConfig()
+	pipeline := s.client.TxPipeline()
+	for _, countryID := range countries {
+		for _, gateway := range byCountry[countryID] {
+			pipeline.ZAddNX(ctx, rankingKey(countryID), redis.Z{Score: cfg.PriorScore, Member: gateway.ID})
+			pipeline.HSetNX(ctx, detailsKey(countryID), gateway.ID+":name", gateway.Name)
+		}
+	}
+	pipeline.Del(ctx, legacyKeys...)
+	_, err := pipeline.Exec(ctx)
+	return err
 }
 
-func (s *RedisClient) DecrementGatewayScore(ctx context.Context, countryID string, gatewayID string) error {
-	key := fmt.Sprintf("gateway-by-country:%s:%s", countryID, gatewayID)
-	return s.client.HIncrBy(ctx, key, "score", -1).Err()
+// InvalidateGatewaysByCountry drops the cached ranking of the given countries so the
+// next lookup reloads the gateways from Postgres. Outcome windows are kept.
+func (s *RedisClient) InvalidateGatewaysByCountry(ctx context.Context, countryIDs ...string) error {
+	if len(countryIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, 2*len(countryIDs))
+	for _, countryID := range countryIDs {
+		keys = append(keys, rankingKey(countryID), detailsKey(countryID))
+	}
+	return s.client.Del(ctx, keys...).Err()
 }
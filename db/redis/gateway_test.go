@@ -3,17 +3,30 @@ package redis
 import (
 	"context"
 	"fmt"
+	"payment-gateway/internal/config"
 	"payment-gateway/internal/models"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRedisClient_GetGatewaysByCountry(t *testing.T) {
 	// Create mock Redis client
 	db, mock := redismock.NewClientMock()
-	client := &RedisClient{client: db}
+	client := &RedisClient{
+		client: db,
+		scoring: config.GatewayScore{
+			BucketSize: time.Minute,
+			Buckets:    2,
+			Decay:      0.5,
+			MinSamples: 2,
+			PriorScore: 0.5,
+		},
+		now: func() time.Time { return time.Unix(600, 0) },
+	}
 	ctx := context.Background()
 
 	tests := []struct {
@@ -27,31 +40,67 @@ func TestRedisClient_GetGatewaysByCountry(t *testing.T) {
 			name:      "successful retrieval with multiple gateways",
 			countryID: "1",
 			prepareMock: func(countryID string) {
-				pattern := fmt.Sprintf("gateway-by-country:%s:*", countryID)
-				mock.ExpectKeys(pattern).SetVal([]string{
-					fmt.Sprintf("gateway-by-country:%s:100", countryID),
-					fmt.Sprintf("gateway-by-country:%s:101", countryID),
+				mock.ExpectZRevRangeWithScores(fmt.Sprintf("gateway-ranking:%s", countryID), 0, -1).SetVal([]redis.Z{
+					{Score: 0.8, Member: "101"},
+					{Score: 0.75, Member: "100"},
 				})
-
-				// Mock first gateway HGetAll
-				key1 := fmt.Sprintf("gateway-by-country:%s:100", countryID)
-				mock.ExpectHGetAll(key1).SetVal(map[string]string{
-					"gateway_name": "Gateway 1",
-					"score":        "90",
+				mock.ExpectHGetAll(fmt.Sprintf("gateway-details:%s", countryID)).SetVal(map[string]string{
+					"refreshed_at": "590",
+					"100:name":     "Gateway 1",
+					"100:ratio":    "0.75",
+					"100:samples":  "4",
+					"101:name":     "Gateway 2",
+					"101:ratio":    "0.8",
+					"101:samples":  "6",
+				})
+			},
+			expectedResult: []models.Gateway{
+				{ID: "101", Name: "Gateway 2", Score: 0.8, SuccessRatio: 0.8, SampleSize: 6},
+				{ID: "100", Name: "Gateway 1", Score: 0.75, SuccessRatio: 0.75, SampleSize: 4},
+			},
+			expectError: false,
+		},
+		{
+			name:      "stale ranking is rescored from the outcome window",
+			countryID: "5",
+			prepareMock: func(countryID string) {
+				mock.ExpectZRevRangeWithScores(fmt.Sprintf("gateway-ranking:%s", countryID), 0, -1).SetVal([]redis.Z{
+					{Score: 0.9, Member: "100"},
+					{Score: 0.5, Member: "101"},
+				})
+				mock.ExpectHGetAll(fmt.Sprintf("gateway-details:%s", countryID)).SetVal(map[string]string{
+					"refreshed_at": "100",
+					"100:name":     "Gateway 1",
+					"101:name":     "Gateway 2",
 				})
-				mock.ExpectHGet(key1, "score").SetVal("90")
 
-				// Mock second gateway HGetAll
-				key2 := fmt.Sprintf("gateway-by-country:%s:101", countryID)
-				mock.ExpectHGetAll(key2).SetVal(map[string]string{
-					"gateway_name": "Gateway 2",
-					"score":        "85",
+				// Pipelined scoring window reads, current bucket first
+				mock.ExpectHGetAll(fmt.Sprintf("gateway-stats:%s:100:10", countryID)).SetVal(map[string]string{
+					"success": "3", "failure": "1", "weighted_failure": "1",
+				})
+				mock.ExpectHGetAll(fmt.Sprintf("gateway-stats:%s:100:9", countryID)).SetVal(map[string]string{})
+				mock.ExpectHGetAll(fmt.Sprintf("gateway-stats:%s:101:10", countryID)).SetVal(map[string]string{
+					"success": "4",
 				})
-				mock.ExpectHGet(key2, "score").SetVal("85")
+				mock.ExpectHGetAll(fmt.Sprintf("gateway-stats:%s:101:9", countryID)).SetVal(map[string]string{
+					"failure": "2", "weighted_failure": "2",
+				})
+
+				mock.ExpectTxPipeline()
+				mock.ExpectZAdd(fmt.Sprintf("gateway-ranking:%s", countryID),
+					redis.Z{Score: 0.8, Member: "101"},
+					redis.Z{Score: 0.75, Member: "100"},
+				).SetVal(0)
+				mock.ExpectHSet(fmt.Sprintf("gateway-details:%s", countryID),
+					"refreshed_at", int64(600),
+					"101:name", "Gateway 2", "101:ratio", 0.8, "101:samples", int64(6),
+					"100:name", "Gateway 1", "100:ratio", 0.75, "100:samples", int64(4),
+				).SetVal(0)
+				mock.ExpectTxPipelineExec()
 			},
 			expectedResult: []models.Gateway{
-				{ID: "100", Name: "Gateway 1", Score: 90},
-				{ID: "101", Name: "Gateway 2", Score: 85},
+				{ID: "101", Name: "Gateway 2", Score: 0.8, SuccessRatio: 0.8, SampleSize: 6},
+				{ID: "100", Name: "Gateway 1", Score: 0.75, SuccessRatio: 0.75, SampleSize: 4},
 			},
 			expectError: false,
 		},
@@ -59,18 +108,17 @@ func TestRedisClient_GetGatewaysByCountry(t *testing.T) {
 			name:      "empty result",
 			countryID: "2",
 			prepareMock: func(countryID string) {
-				pattern := fmt.Sprintf("gateway-by-country:%s:*", countryID)
-				mock.ExpectKeys(pattern).SetVal([]string{})
+				mock.ExpectZRevRangeWithScores(fmt.Sprintf("gateway-ranking:%s", countryID), 0, -1).SetVal([]redis.Z{})
+				mock.ExpectHGetAll(fmt.Sprintf("gateway-details:%s", countryID)).SetVal(map[string]string{})
 			},
 			expectedResult: nil,
 			expectError:    false,
 		},
 		{
-			name:      "keys error",
+			name:      "ranking error",
 			countryID: "3",
 			prepareMock: func(countryID string) {
-				pattern := fmt.Sprintf("gateway-by-country:%s:*", countryID)
-				mock.ExpectKeys(pattern).SetErr(fmt.Errorf("redis error"))
+				mock.ExpectZRevRangeWithScores(fmt.Sprintf("gateway-ranking:%s", countryID), 0, -1).SetErr(fmt.Errorf("redis error"))
 			},
 			expectedResult: nil,
 			expectError:    true,
@@ -79,10 +127,10 @@ func TestRedisClient_GetGatewaysByCountry(t *testing.T) {
 			name:      "hgetall error",
 			countryID: "4",
 			prepareMock: func(countryID string) {
-				pattern := fmt.Sprintf("gateway-by-country:%s:*", countryID)
-				key := fmt.Sprintf("gateway-by-country:%s:200", countryID)
-				mock.ExpectKeys(pattern).SetVal([]string{key})
-				mock.ExpectHGetAll(key).SetErr(fmt.Errorf("hgetall error"))
+				mock.ExpectZRevRangeWithScores(fmt.Sprintf("gateway-ranking:%s", countryID), 0, -1).SetVal([]redis.Z{
+					{Score: 1, Member: "200"},
+				})
+				mock.ExpectHGetAll(fmt.Sprintf("gateway-details:%s", countryID)).SetErr(fmt.Errorf("hgetall error"))
 			},
 			expectedResult: nil,
 			expectError:    true,
@@ -114,3 +162,39 @@ func TestRedisClient_GetGatewaysByCountry(t *testing.T) {
 		})
 	}
 }
+
+func TestRedisClient_MigrateLegacyGatewayKeys(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	client := &RedisClient{client: db}
+	ctx := context.Background()
+
+	mock.ExpectScan(0, "gateway-by-country:*", 100).SetVal([]string{
+		"gateway-by-country:3:7",
+		"gateway-by-country:1:2",
+	}, 0)
+	mock.ExpectHGet("gateway-by-country:3:7", "gateway_name").SetVal("DEFAULT_GATEWAY")
+	mock.ExpectHGet("gateway-by-country:1:2", "gateway_name").SetVal("RAZORPAY")
+
+	mock.ExpectTxPipeline()
+	mock.ExpectZAddNX("gateway-ranking:1", redis.Z{Score: 0.5, Member: "2"}).SetVal(1)
+	mock.ExpectHSetNX("gateway-details:1", "2:name", "RAZORPAY").SetVal(true)
+	mock.ExpectZAddNX("gateway-ranking:3", redis.Z{Score: 0.5, Member: "7"}).SetVal(1)
+	mock.ExpectHSetNX("gateway-details:3", "7:name", "DEFAULT_GATEWAY").SetVal(true)
+	mock.ExpectDel("gateway-by-country:3:7", "gateway-by-country:1:2").SetVal(2)
+	mock.ExpectTxPipelineExec()
+
+	assert.NoError(t, client.MigrateLegacyGatewayKeys(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisClient_InvalidateGatewaysByCountry(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	client := &RedisClient{client: db}
+	ctx := context.Background()
+
+	mock.ExpectDel("gateway-ranking:1", "gateway-details:1", "gateway-ranking:2", "gateway-details:2").SetVal(4)
+
+	assert.NoError(t, client.InvalidateGatewaysByCountry(ctx, "1", "2"))
+	assert.NoError(t, client.InvalidateGatewaysByCountry(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,12 +3,23 @@ package redis
 import (
 	"context"
 	"payment-gateway/internal/models"
+	"time"
 )
 
 type IRedis interface {
-	SaveGatewaysToRedisHashSet(ctx context.Context, countryID string, gateways []models.Gateway) error
+	Ping(ctx context.Context) error
+	Close() error
+	SaveGatewaysByCountry(ctx context.Context, countryID string, gateways []models.Gateway) error
 	GetGatewaysByCountry(ctx context.Context, countryID string) ([]models.Gateway, error)
-	IncrementGatewayScore(ctx context.Context, countryID string, gatewayID string) error
-	DecrementGatewayScore(ctx context.Context, countryID string, gatewayID string) error
+	RecordGatewayOutcome(ctx context.Context, countryID string, gatewayID string, outcome models.GatewayOutcome) error
+	RecordGatewayLatency(ctx context.Context, countryID string, gatewayID string, latency time.Duration) error
+	InvalidateGatewaysByCountry(ctx context.Context, countryIDs ...string) error
+	TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, time.Duration, error)
+	ReserveSlot(ctx context.Context, key, member string, max int64, window time.Duration) (bool, time.Duration, error)
+	ReleaseSlot(ctx context.Context, key, member string) error
+	UseOnce(ctx context.Context, key string, ttl time.Duration) (bool, error)
 	HSet(ctx context.Context, key string, values map[string]interface{}) error
+	CreateStatus(ctx context.Context, key, status string, values map[string]interface{}) error
+	SetStatus(ctx context.Context, key, event string, rank int, values map[string]interface{}) (bool, error)
+	StatusHandled(ctx context.Context, key, event string) error
 }
@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills a bucket from the time elapsed since the last call
+// and takes one token if available. It returns {allowed, wait in ms}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`)
+
+// slidingWindowScript drops entries older than the window and adds the member
+// unless the window already holds max entries. It returns {allowed, wait in ms}.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local max = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) >= max then
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	return {0, tonumber(oldest[2]) + window - now}
+end
+
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], window)
+return {1, 0}
+`)
+
+// TakeToken takes a token from the bucket at key, refilled at rate tokens per
+// second up to burst. When the bucket is empty it returns how long to wait.
+func (s *RedisClient) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, time.Duration, error) {
+	now := s.clock().UnixMilli()
+	result, err := tokenBucketScript.Run(ctx, s.client, []string{key}, rate, burst, now).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to take token from %s: %w", key, err)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// ReserveSlot records member in the sliding window at key unless it already
+// holds max members. When full it returns how long until the oldest one expires.
+func (s *RedisClient) ReserveSlot(ctx context.Context, key, member string, max int64, window time.Duration) (bool, time.Duration, error) {
+	now := s.clock().UnixMilli()
+	result, err := slidingWindowScript.Run(ctx, s.client, []string{key}, now, window.Milliseconds(), max, member).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to reserve slot in %s: %w", key, err)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// ReleaseSlot removes a member reserved by ReserveSlot
+func (s *RedisClient) ReleaseSlot(ctx context.Context, key, member string) error {
+	return s.client.ZRem(ctx, key, member).Err()
+}
@@ -3,25 +3,33 @@ package redis
 import (
 	"context"
 	"fmt"
-	"os"
+	"log/slog"
+	"payment-gateway/internal/config"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
 // RedisClient struct holds the Redis client and context.
 type RedisClient struct {
-	client *redis.Client
+	client  *redis.Client
+	scoring config.GatewayScore
+	now     func() time.Time
 }
 
-// Init initializes the Redis client.
-func Init() (*RedisClient, error) {
+// Init initializes the Redis client, scoring gateways with the given configuration.
+func Init(cfg config.Redis, scoring config.GatewayScore) (*RedisClient, error) {
 	client := redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_ADDR"),
-		Password: os.Getenv("REDIS_PASSWORD"),
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
 		DB:       0,
 	})
 
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		return nil, fmt.Errorf("failed to instrument Redis tracing: %w", err)
+	}
+
 	ctx := context.Background()
 
 	// Test the connection
@@ -30,7 +38,22 @@ func Init() (*RedisClient, error) {
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisClient{client: client}, nil
+	r := &RedisClient{client: client, scoring: scoring}
+	if err := r.MigrateLegacyGatewayKeys(ctx); err != nil {
+		slog.Error("error migrating legacy gateway keys", "error", err)
+	}
+
+	return r, nil
+}
+
+// Ping checks that Redis is reachable
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis connection pool
+func (r *RedisClient) Close() error {
+	return r.client.Close()
 }
 
 // HSet sets multiple hash fields to multiple values.
@@ -41,12 +64,21 @@ func (r *RedisClient) HSet(ctx context.Context, key string, values map[string]in
 	return r.client.HSet(ctx, key, values).Err()
 }
 
-// GetExpiry set expiry to key
-func (r *RedisClient) SetExpiry(key string, expiry time.Duration) error {
-	err := r.client.Expire(context.Background(), key, expiry).Err()
+// SetExpiry set expiry to key
+func (r *RedisClient) SetExpiry(ctx context.Context, key string, expiry time.Duration) error {
+	err := r.client.Expire(ctx, key, expiry).Err()
 	if err != nil {
 		return fmt.Errorf("failed to set expiry to key:%s , err:%w", key, err)
 	}
 	return nil
 
 }
+
+// UseOnce sets key for ttl unless it is set already and reports whether it set it
+func (r *RedisClient) UseOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	set, err := r.client.SetNX(ctx, key, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return set, nil
+}
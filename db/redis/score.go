@@ -0,0 +1,130 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scoreConfig returns the client's scoring configuration, falling back to the defaults.
+func (s *RedisClient) scoreConfig() config.GatewayScore {
+	if s.scoring.Buckets == 0 || s.scoring.BucketSize == 0 {
+		return config.DefaultGatewayScore()
+	}
+	return s.scoring
+}
+
+// clock returns the current time, overridable in tests.
+func (s *RedisClient) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// statsKeys returns the bucket keys of a gateway's window, newest first.
+func (s *RedisClient) statsKeys(countryID, gatewayID string) []string {
+	cfg := s.scoreConfig()
+	current := s.clock().Unix() / int64(cfg.BucketSize.Seconds())
+	keys := make([]string, cfg.Buckets)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("gateway-stats:%s:%s:%d", countryID, gatewayID, current-int64(i))
+	}
+	return keys
+}
+
+// RecordGatewayOutcome adds a success or failure to the current bucket of a gateway.
+func (s *RedisClient) RecordGatewayOutcome(ctx context.Context, countryID string, gatewayID string, outcome models.GatewayOutcome) error {
+	cfg := s.scoreConfig()
+	key := s.statsKeys(countryID, gatewayID)[0]
+
+	pipeline := s.client.Pipeline()
+	if outcome.Success {
+		pipeline.HIncrBy(ctx, key, "success", 1)
+	} else {
+		weight, ok := cfg.ErrorWeights[outcome.ErrorType]
+		if !ok {
+			weight = 1
+		}
+		pipeline.HIncrBy(ctx, key, "failure", 1)
+		pipeline.HIncrByFloat(ctx, key, "weighted_failure", weight)
+	}
+	pipeline.Expire(ctx, key, cfg.BucketSize*time.Duration(cfg.Buckets+1))
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return err
+	}
+	return s.refreshGatewayScore(ctx, countryID, gatewayID)
+}
+
+// RecordGatewayLatency adds the duration of a gateway API call to the current bucket.
+func (s *RedisClient) RecordGatewayLatency(ctx context.Context, countryID string, gatewayID string, latency time.Duration) error {
+	cfg := s.scoreConfig()
+	key := s.statsKeys(countryID, gatewayID)[0]
+
+	pipeline := s.client.Pipeline()
+	pipeline.HIncrBy(ctx, key, "latency_ms", latency.Milliseconds())
+	pipeline.HIncrBy(ctx, key, "latency_count", 1)
+	pipeline.Expire(ctx, key, cfg.BucketSize*time.Duration(cfg.Buckets+1))
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return err
+	}
+	return s.refreshGatewayScore(ctx, countryID, gatewayID)
+}
+
+// queueGatewayStats queues reads of every bucket in a gateway's window on the pipeline.
+func (s *RedisClient) queueGatewayStats(ctx context.Context, pipeline redis.Pipeliner, countryID, gatewayID string) []*redis.MapStringStringCmd {
+	keys := s.statsKeys(countryID, gatewayID)
+	cmds := make([]*redis.MapStringStringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipeline.HGetAll(ctx, key)
+	}
+	return cmds
+}
+
+// scoreFromBuckets computes the decayed, weighted success ratio of a gateway.
+// Buckets are ordered newest first.
+func scoreFromBuckets(cfg config.GatewayScore, buckets []map[string]string) (score float64, ratio float64, samples int64) {
+	var successes, failures float64
+	var latencyMs, latencyCount int64
+
+	weight := 1.0
+	for _, bucket := range buckets {
+		success, _ := strconv.ParseInt(bucket["success"], 10, 64)
+		failure, _ := strconv.ParseInt(bucket["failure"], 10, 64)
+		weighted, _ := strconv.ParseFloat(bucket["weighted_failure"], 64)
+		ms, _ := strconv.ParseInt(bucket["latency_ms"], 10, 64)
+		count, _ := strconv.ParseInt(bucket["latency_count"], 10, 64)
+
+		successes += weight * float64(success)
+		failures += weight * weighted
+		samples += success + failure
+		latencyMs += ms
+		latencyCount += count
+		weight *= cfg.Decay
+	}
+
+	if successes+failures > 0 {
+		ratio = successes / (successes + failures)
+	}
+	if samples < cfg.MinSamples {
+		return cfg.PriorScore, ratio, samples
+	}
+
+	score = ratio
+	if latencyCount > 0 && cfg.LatencyTarget > 0 {
+		avg := time.Duration(latencyMs/latencyCount) * time.Millisecond
+		if avg > cfg.LatencyTarget {
+			over := float64(avg-cfg.LatencyTarget) / float64(cfg.LatencyTarget)
+			if over > 1 {
+				over = 1
+			}
+			score *= 1 - cfg.LatencyWeight*over
+		}
+	}
+	return score, ratio, samples
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"payment-gateway/internal/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreFromBuckets(t *testing.T) {
+	cfg := config.GatewayScore{
+		BucketSize:    time.Minute,
+		Buckets:       3,
+		Decay:         0.5,
+		MinSamples:    4,
+		PriorScore:    0.5,
+		LatencyTarget: time.Second,
+		LatencyWeight: 0.2,
+	}
+
+	tests := []struct {
+		name            string
+		buckets         []map[string]string
+		expectedScore   float64
+		expectedRatio   float64
+		expectedSamples int64
+	}{
+		{
+			name:            "no samples uses prior",
+			buckets:         []map[string]string{{}, {}, {}},
+			expectedScore:   0.5,
+			expectedRatio:   0,
+			expectedSamples: 0,
+		},
+		{
+			name: "old failures decay",
+			buckets: []map[string]string{
+				{"success": "3"},
+				{},
+				{"failure": "4", "weighted_failure": "4"},
+			},
+			expectedScore:   0.75,
+			expectedRatio:   0.75,
+			expectedSamples: 7,
+		},
+		{
+			name: "weighted declines count less than failures",
+			buckets: []map[string]string{
+				{"success": "2", "failure": "4", "weighted_failure": "0.5"},
+			},
+			expectedScore:   0.8,
+			expectedRatio:   0.8,
+			expectedSamples: 6,
+		},
+		{
+			name: "slow gateway is penalised",
+			buckets: []map[string]string{
+				{"success": "4", "latency_ms": "3000", "latency_count": "2"},
+			},
+			expectedScore:   0.9,
+			expectedRatio:   1,
+			expectedSamples: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, ratio, samples := scoreFromBuckets(cfg, tt.buckets)
+			assert.InDelta(t, tt.expectedScore, score, 1e-9)
+			assert.InDelta(t, tt.expectedRatio, ratio, 1e-9)
+			assert.Equal(t, tt.expectedSamples, samples)
+		})
+	}
+}
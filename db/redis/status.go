@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fields of a payment status hash kept by SetStatus
+const (
+	statusRankField  = "status_rank"
+	statusEventField = "status_event"
+	handledPrefix    = "handled:"
+)
+
+// setStatusScript sets the fields of a payment status hash unless the event was
+// handled already, a later status is stored or another event stored the same one.
+// The event that stored the current status may set it again, so that it can be
+// retried. It returns 1 when the fields were set.
+var setStatusScript = redis.NewScript(`
+local rank = tonumber(ARGV[1])
+if redis.call('HEXISTS', KEYS[1], ARGV[3] .. ARGV[2]) == 1 then
+	return 0
+end
+
+local state = redis.call('HMGET', KEYS[1], ARGV[4], ARGV[5])
+local current = tonumber(state[1]) or 0
+if current > rank or (current == rank and state[2] ~= ARGV[2]) then
+	return 0
+end
+
+redis.call('HSET', KEYS[1], ARGV[4], rank, ARGV[5], ARGV[2], unpack(ARGV, 6))
+return 1
+`)
+
+// SetStatus stores the values of a payment status reached with rank by event, a
+// unique ID of the gateway event. Statuses only move forward: it returns false
+// without storing anything for an event that was handled already, or that would
+// replace a later status or the same status stored by another event.
+func (r *RedisClient) SetStatus(ctx context.Context, key, event string, rank int, values map[string]interface{}) (bool, error) {
+	args := []interface{}{rank, event, handledPrefix, statusRankField, statusEventField}
+	for field, value := range values {
+		args = append(args, field, value)
+	}
+	set, err := setStatusScript.Run(ctx, r.client, []string{key}, args...).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to set status of %s: %w", key, err)
+	}
+	return set == 1, nil
+}
+
+// StatusHandled records that event was handled, SetStatus ignores it from then on
+func (r *RedisClient) StatusHandled(ctx context.Context, key, event string) error {
+	if err := r.client.HSet(ctx, key, handledPrefix+event, 1).Err(); err != nil {
+		return fmt.Errorf("failed to record event %s of %s: %w", event, key, err)
+	}
+	return nil
+}
+
+// CreateStatus stores the values of a payment the API just created with its initial
+// status. The status is only set when none is stored, so a webhook handled before
+// the gateway call returned keeps its later status.
+func (r *RedisClient) CreateStatus(ctx context.Context, key, status string, values map[string]interface{}) error {
+	pipeline := r.client.TxPipeline()
+	if len(values) > 0 {
+		pipeline.HSet(ctx, key, values)
+	}
+	pipeline.HSetNX(ctx, key, "status", status)
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create status of %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisClient_CreateStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		webhook  string // Status stored by a webhook before the API, empty for none
+		expected string
+	}{
+		{name: "first write", expected: "created"},
+		{name: "webhook handled first", webhook: "success", expected: "success"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			client := &RedisClient{client: redis.NewClient(&redis.Options{Addr: server.Addr()})}
+			ctx := context.Background()
+			key := "deposit:userid:1:orderid:pi_1"
+
+			if tt.webhook != "" {
+				set, err := client.SetStatus(ctx, key, "pi_1:deposit.succeeded", 2, map[string]interface{}{"status": tt.webhook})
+				require.NoError(t, err)
+				require.True(t, set)
+			}
+			require.NoError(t, client.CreateStatus(ctx, key, "created", map[string]interface{}{"amount": "5000"}))
+
+			assert.Equal(t, tt.expected, server.HGet(key, "status"))
+			assert.Equal(t, "5000", server.HGet(key, "amount"))
+		})
+	}
+}
@@ -15,9 +15,14 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/deposit": {
-            "post": {
-                "description": "Handles deposit creation with payment gateway integration and stores result in Redis",
+        "/admin/beneficiaries/{beneficiaryID}/verification": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Marks a beneficiary verified, making it usable for withdrawals, or rejected with a reason",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,23 +30,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "deposits"
+                    "admin"
                 ],
-                "summary": "Process a new deposit request",
+                "summary": "Verify or reject a beneficiary",
                 "parameters": [
                     {
-                        "description": "Deposit request payload",
-                        "name": "deposit",
+                        "type": "integer",
+                        "description": "Beneficiary ID",
+                        "name": "beneficiaryID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Verification outcome",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.DepositRequest"
+                            "$ref": "#/definitions/models.BeneficiaryVerificationRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Deposit created successfully",
+                        "description": "Updated status",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -56,8 +68,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "405": {
-                        "description": "Method not allowed",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Beneficiary not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -77,9 +98,54 @@ const docTemplate = `{
                 }
             }
         },
-        "/gateways/{countryID}": {
+        "/admin/countries": {
             "get": {
-                "description": "Fetches a list of supported payment gateway IDs for a specified country from Redis or DB, sorted by score",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List countries",
+                "responses": {
+                    "200": {
+                        "description": "Countries",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "consumes": [
                     "application/json"
                 ],
@@ -87,27 +153,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "gateways"
+                    "admin"
                 ],
-                "summary": "Get payment gateways by country",
+                "summary": "Create a country",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Country ID",
-                        "name": "countryID",
-                        "in": "path",
-                        "required": true
+                        "description": "Country",
+                        "name": "country",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CountryRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "List of gateway IDs for the country",
+                    "201": {
+                        "description": "Created country",
                         "schema": {
-                            "$ref": "#/definitions/api.GatewayResponse"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Bad Request - Missing countryID",
+                        "description": "Invalid request payload",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -115,8 +184,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "404": {
-                        "description": "Not Found - No gateways for the country",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -125,7 +194,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error - Database or Redis failure",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -136,39 +205,69 @@ const docTemplate = `{
                 }
             }
         },
-        "/webhook/default-gateway": {
-            "post": {
-                "description": "Processes incoming webhook events from the default gateway, parses the payload, and delegates to the PSP service layer",
+        "/admin/countries/{countryID}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "webhooks"
+                    "admin"
                 ],
-                "summary": "Handle default gateway webhook events",
+                "summary": "Update a country",
                 "parameters": [
                     {
-                        "description": "Default gateway webhook payload",
-                        "name": "payload",
+                        "type": "integer",
+                        "description": "Country ID",
+                        "name": "countryID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Country",
+                        "name": "country",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.DefaultGatewayEvent"
+                            "$ref": "#/definitions/models.CountryRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Webhook processed successfully",
+                        "description": "Updated country",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Bad Request - Payload too large",
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Country not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -177,7 +276,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error - Parsing or processing error",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -186,48 +285,33 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/webhook/stripe": {
-            "post": {
-                "description": "Processes incoming webhook events from Stripe, verifies the signature, and delegates to the PSP service layer",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "text/plain"
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Deletes a country with its gateway mappings and invalidates its cached ranking",
                 "tags": [
-                    "webhooks"
+                    "admin"
                 ],
-                "summary": "Handle Stripe webhook events",
+                "summary": "Delete a country",
                 "parameters": [
                     {
-                        "description": "Stripe webhook payload (dynamic JSON structure)",
-                        "name": "payload",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object"
-                        }
-                    },
-                    {
-                        "type": "string",
-                        "description": "Stripe signature for verification",
-                        "name": "Stripe-Signature",
-                        "in": "header",
+                        "type": "integer",
+                        "description": "Country ID",
+                        "name": "countryID",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Webhook processed successfully",
-                        "schema": {
-                            "type": "string"
-                        }
+                    "204": {
+                        "description": "Country deleted"
                     },
                     "400": {
-                        "description": "Bad Request - Payload too large",
+                        "description": "Invalid country ID",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -236,7 +320,16 @@ const docTemplate = `{
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - Invalid signature",
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Country not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -245,7 +338,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error - Processing or module error",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -256,40 +349,40 @@ const docTemplate = `{
                 }
             }
         },
-        "/withdrawal": {
-            "post": {
-                "description": "Handles withdrawal creation with payment gateway integration and stores result in Redis",
-                "consumes": [
-                    "application/json"
+        "/admin/countries/{countryID}/gateways": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Returns every gateway mapped to the country with its per-country enabled flag",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "withdrawals"
+                    "admin"
                 ],
-                "summary": "Process a new withdrawal request",
+                "summary": "List the gateway mappings of a country",
                 "parameters": [
                     {
-                        "description": "Withdrawal request payload",
-                        "name": "withdrawal",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.CustomWithdrawalRequest"
-                        }
+                        "type": "integer",
+                        "description": "Country ID",
+                        "name": "countryID",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Withdrawal created successfully",
+                        "description": "Gateway mappings",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Invalid request payload",
+                        "description": "Invalid country ID",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -297,8 +390,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "404": {
-                        "description": "Invalid gateway name",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -306,8 +399,68 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "405": {
-                        "description": "Method not allowed",
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/countries/{countryID}/gateways/{gatewayID}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates or re-enables the mapping and invalidates the country's cached ranking",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Map a gateway to a country",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Country ID",
+                        "name": "countryID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Gateway ID",
+                        "name": "gatewayID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Mapping created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -325,12 +478,1994 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "api.GatewayResponse": {
-            "type": "object",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes the mapping and invalidates the country's cached ranking",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Unmap a gateway from a country",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Country ID",
+                        "name": "countryID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Gateway ID",
+                        "name": "gatewayID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Mapping deleted"
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Mapping not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/countries/{countryID}/gateways/{gatewayID}/status": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Updates the per-country enabled flag and invalidates the country's cached ranking",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Enable or disable a gateway in a country",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Country ID",
+                        "name": "countryID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Gateway ID",
+                        "name": "gatewayID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Enabled flag",
+                        "name": "status",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.StatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated mapping",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Mapping not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/gateways": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns every configured gateway with its global enabled flag",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List gateways",
+                "responses": {
+                    "200": {
+                        "description": "Gateways",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a gateway, enabled unless stated otherwise. It only becomes routable once mapped to a country.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Create a gateway",
+                "parameters": [
+                    {
+                        "description": "Gateway",
+                        "name": "gateway",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GatewayRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/gateways/{gatewayID}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Updates the name, data format and optionally the enabled flag of a gateway and invalidates the cached rankings of its countries",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Update a gateway",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Gateway ID",
+                        "name": "gatewayID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Gateway",
+                        "name": "gateway",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GatewayRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Gateway not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes a gateway with its country mappings and invalidates the cached rankings of those countries",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Delete a gateway",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Gateway ID",
+                        "name": "gatewayID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Gateway deleted"
+                    },
+                    "400": {
+                        "description": "Invalid gateway ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Gateway not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/gateways/{gatewayID}/status": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Disabled gateways are excluded from every country's ranking. The cached rankings of its countries are invalidated.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Enable or disable a gateway globally",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Gateway ID",
+                        "name": "gatewayID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Enabled flag",
+                        "name": "status",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.StatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated status",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Gateway not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/registry": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns every gateway registered with this instance and whether it is enabled or paused",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List registered gateways",
+                "responses": {
+                    "200": {
+                        "description": "Gateway states",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/registry/{name}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Sets the gateway's enabled flag, the one of /admin/gateways/{gatewayID}/status. A paused gateway is dropped from /gateways/{countryID} and refuses new deposits and withdrawals, its webhooks are still handled. The other instances pick the state up from the DB.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Pause or enable a gateway",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Gateway name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Status and reason",
+                        "name": "state",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GatewayStateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated state",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Gateway not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/reviews": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the transactions parked by the risk checks with the signals that flagged them, oldest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List parked transactions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "pending, processing, approved, rejected or failed, every status when empty",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of reviews, default 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reviews",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/reviews/{reviewID}/approve": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Approves a pending review and replays the parked deposit or withdrawal against its gateway. The caller that made the request cannot approve it. Held funds stay held through the gateway call and the review ends approved, or failed when the gateway call fails. A failed call releases the funds, an approved withdrawal keeps them held until its payout is paid, fails or is canceled.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Approve a parked transaction",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Review ID",
+                        "name": "reviewID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Optional note",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReviewDecisionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Decided review",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "The requester cannot approve their own request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Review not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Review already decided",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/reviews/{reviewID}/reject": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Rejects a pending review with a reason and releases its held funds. The parked transaction never reaches a gateway.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reject a parked transaction",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Review ID",
+                        "name": "reviewID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ReviewDecisionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Decided review",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or missing reason",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Review not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Review already decided",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/vault/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Re-wraps the data keys of every vault entry that is not yet under the active key-encryption key. Run it after changing DATA_ENCRYPTION_ACTIVE_KEY, before removing the old key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Rotate vault keys",
+                "responses": {
+                    "200": {
+                        "description": "Number of entries rewrapped",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/beneficiaries": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "beneficiaries"
+                ],
+                "summary": "List beneficiaries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID, required for internal callers",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Beneficiaries",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "user_id does not match the authenticated user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Validates the bank details against the rules of their country (ABA routing number, UK sort code, IFSC or IBAN), stores them in the vault and saves a pending beneficiary. Withdrawals can reference it once verified.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "beneficiaries"
+                ],
+                "summary": "Save a beneficiary",
+                "parameters": [
+                    {
+                        "description": "Beneficiary",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BeneficiaryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Saved beneficiary",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or bank details",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "user_id does not match the authenticated user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/beneficiaries/{beneficiaryID}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "beneficiaries"
+                ],
+                "summary": "Delete a beneficiary",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Beneficiary ID",
+                        "name": "beneficiaryID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID, required for internal callers",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Beneficiary deleted"
+                    },
+                    "400": {
+                        "description": "Invalid beneficiary ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Beneficiary not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/deposit": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Handles deposit creation with payment gateway integration and stores result in Redis",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "deposits"
+                ],
+                "summary": "Process a new deposit request",
+                "parameters": [
+                    {
+                        "description": "Deposit request payload",
+                        "name": "deposit",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DepositRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deposit created successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "202": {
+                        "description": "Deposit parked for manual approval",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or unsupported by the gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "user_id does not match the authenticated user, or declined by the risk checks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Gateway paused",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway timed out, retry with the same X-Request-ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/gateways/{countryID}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches a list of supported payment gateway IDs for a specified country from Redis or DB, sorted by their windowed success-rate score. When the optional query parameters are given, routing rules are applied before the score ranking and gateways whose capabilities cannot handle the currency, amount, type or method are dropped.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "gateways"
+                ],
+                "summary": "Get payment gateways by country",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Country ID",
+                        "name": "countryID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Transaction type (deposit or withdrawal)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency code",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Amount in the smallest currency unit",
+                        "name": "amount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payout method (standard or instant)",
+                        "name": "method",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of gateway IDs for the country",
+                        "schema": {
+                            "$ref": "#/definitions/api.GatewayResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Missing countryID or invalid amount",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found - No gateways for the country, paused gateways are left out",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error - Database or Redis failure",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Answers 200 while the process serves HTTP. It does not check dependencies, a failing dependency should not restart the service.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "Process is alive",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Checks Postgres, Redis, the Kafka brokers, the webhook consumers and the circuit breakers and returns a per-dependency report. Answers 503 while a critical dependency is down or the consumers are not subscribed yet. An open circuit breaker reports degraded without failing readiness.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Ready, status ok or degraded",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "A critical dependency is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/reviews/{reviewID}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the status of a deposit or withdrawal the risk checks parked for manual review, and the gateway response once approved",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reviews"
+                ],
+                "summary": "Get a parked transaction",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Review ID",
+                        "name": "reviewID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID, required for internal callers",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Review status",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid review ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Review not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/routing/dry-run": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Evaluates the routing rules in priority order against a hypothetical deposit or withdrawal and returns the resulting gateways, the matched rule and a trace of every rule evaluated",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "routing"
+                ],
+                "summary": "Dry-run the gateway routing rules",
+                "parameters": [
+                    {
+                        "description": "Hypothetical routing request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RoutingRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Routing decision with explanation",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoutingDecision"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/vault/bank-details": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Encrypts the bank details and stores them in the vault. The returned token can be sent as bank_details_token to /withdrawal instead of the raw details.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vault"
+                ],
+                "summary": "Tokenize bank details",
+                "parameters": [
+                    {
+                        "description": "Bank details",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TokenizeBankDetailsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "user_id does not match the authenticated user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhook/default-gateway": {
+            "post": {
+                "description": "Processes incoming webhook events from the default gateway, verifies the signature with GATEWAY_WEBHOOK_SECRET, and publishes the payment event it reports",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Handle default gateway webhook events",
+                "parameters": [
+                    {
+                        "description": "Default gateway webhook payload",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DefaultGatewayEvent"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the payload",
+                        "name": "X-Gateway-Signature",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook processed successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Payload too large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - Invalid signature",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error - Parsing or processing error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhook/stripe": {
+            "post": {
+                "description": "Processes incoming webhook events from Stripe, verifies the signature, and publishes the payment event it reports",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Handle Stripe webhook events",
+                "parameters": [
+                    {
+                        "description": "Stripe webhook payload (dynamic JSON structure)",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Stripe signature for verification",
+                        "name": "Stripe-Signature",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook processed successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request - Payload too large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - Invalid signature",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error - Processing or module error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/withdrawal": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Handles withdrawal creation with payment gateway integration and stores result in Redis",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "withdrawals"
+                ],
+                "summary": "Process a new withdrawal request",
+                "parameters": [
+                    {
+                        "description": "Withdrawal request payload",
+                        "name": "withdrawal",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CustomWithdrawalRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Withdrawal created successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "202": {
+                        "description": "Withdrawal above the approval threshold or flagged by the risk checks, parked for manual approval with its funds held",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload, unsupported by the gateway or insufficient balance",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "user_id does not match the authenticated user, or declined by the risk checks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Invalid gateway name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests, see Retry-After",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Gateway paused",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway timed out, retry with the same X-Request-ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.GatewayResponse": {
+            "type": "object",
             "required": [
                 "country_id",
                 "gateways"
@@ -341,59 +2476,169 @@ const docTemplate = `{
                     "type": "string",
                     "example": "US"
                 },
-                "gateways": {
-                    "description": "List of gateways sorted by score",
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Gateway"
-                    }
+                "gateways": {
+                    "description": "List of gateways sorted by score, with success ratio and sample size",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Gateway"
+                    }
+                }
+            }
+        },
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.Result"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "health.Result": {
+            "type": "object",
+            "properties": {
+                "critical": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "error": {
+                    "type": "string",
+                    "example": "dial tcp 127.0.0.1:5432: connect: connection refused"
+                },
+                "latency_ms": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "models.BankAccountDetails": {
+            "type": "object",
+            "required": [
+                "account_holder_name",
+                "account_holder_type",
+                "account_number",
+                "country",
+                "currency"
+            ],
+            "properties": {
+                "account_holder_name": {
+                    "description": "Name of the account holder",
+                    "type": "string",
+                    "example": "John Doe"
+                },
+                "account_holder_type": {
+                    "description": "\"individual\" or \"company\"",
+                    "type": "string",
+                    "enum": [
+                        "individual",
+                        "company"
+                    ],
+                    "example": "individual"
+                },
+                "account_number": {
+                    "description": "Bank account number, or IBAN in IBAN countries",
+                    "type": "string",
+                    "example": "1234567890"
+                },
+                "country": {
+                    "description": "Two-letter country code (e.g., \"US\")",
+                    "type": "string",
+                    "example": "US"
+                },
+                "currency": {
+                    "description": "Three-letter currency code (e.g., \"usd\")",
+                    "type": "string",
+                    "example": "usd"
+                },
+                "routing_number": {
+                    "description": "ABA routing number (US), sort code (GB) or IFSC (IN)",
+                    "type": "string",
+                    "example": "110000000"
+                }
+            }
+        },
+        "models.BeneficiaryRequest": {
+            "type": "object",
+            "required": [
+                "bank_details",
+                "user_id"
+            ],
+            "properties": {
+                "bank_details": {
+                    "description": "Bank details, validated against the rules of their country",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BankAccountDetails"
+                        }
+                    ]
+                },
+                "nickname": {
+                    "description": "Optional label shown to the user",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "Salary account"
+                },
+                "user_id": {
+                    "description": "Taken from the token for end users, required for internal callers",
+                    "type": "string",
+                    "example": "1"
                 }
             }
         },
-        "models.BankAccountDetails": {
+        "models.BeneficiaryVerificationRequest": {
             "type": "object",
             "required": [
-                "account_holder_name",
-                "account_holder_type",
-                "account_number",
-                "country",
-                "currency",
-                "routing_number"
+                "status"
             ],
             "properties": {
-                "account_holder_name": {
-                    "description": "Name of the account holder",
+                "reason": {
+                    "description": "Required when rejecting",
                     "type": "string",
-                    "example": "John Doe"
+                    "example": "name mismatch"
                 },
-                "account_holder_type": {
-                    "description": "\"individual\" or \"company\"",
+                "status": {
+                    "description": "verified or rejected",
                     "type": "string",
                     "enum": [
-                        "individual",
-                        "company"
+                        "verified",
+                        "rejected"
                     ],
-                    "example": "individual"
-                },
-                "account_number": {
-                    "description": "Bank account number",
-                    "type": "string",
-                    "example": "1234567890"
-                },
-                "country": {
-                    "description": "Two-letter country code (e.g., \"US\")",
+                    "example": "verified"
+                }
+            }
+        },
+        "models.CountryRequest": {
+            "type": "object",
+            "required": [
+                "code",
+                "currency",
+                "name"
+            ],
+            "properties": {
+                "code": {
+                    "description": "2-letter ISO country code",
                     "type": "string",
-                    "example": "US"
+                    "example": "IN"
                 },
                 "currency": {
-                    "description": "Three-letter currency code (e.g., \"usd\")",
+                    "description": "3-letter ISO currency code",
                     "type": "string",
-                    "example": "usd"
+                    "example": "INR"
                 },
-                "routing_number": {
-                    "description": "Bank routing number (ACH), typically 9 digits in the US",
+                "name": {
+                    "description": "Country name",
                     "type": "string",
-                    "example": "110000614"
+                    "example": "India"
                 }
             }
         },
@@ -401,7 +2646,6 @@ const docTemplate = `{
             "type": "object",
             "required": [
                 "amount",
-                "bank_details",
                 "country_id",
                 "currency",
                 "description",
@@ -417,13 +2661,23 @@ const docTemplate = `{
                     "example": 5000
                 },
                 "bank_details": {
-                    "description": "Custom bank details",
+                    "description": "Raw bank details, unless bank_details_token or beneficiary_id is set",
                     "allOf": [
                         {
                             "$ref": "#/definitions/models.BankAccountDetails"
                         }
                     ]
                 },
+                "bank_details_token": {
+                    "description": "Vault token from POST /vault/bank-details",
+                    "type": "string",
+                    "example": "btok_3q2-7wEAAAAAAAAAAAAAAA"
+                },
+                "beneficiary_id": {
+                    "description": "Verified beneficiary from POST /beneficiaries",
+                    "type": "integer",
+                    "example": 12
+                },
                 "country_id": {
                     "description": "2-letter ISO country code",
                     "type": "string",
@@ -450,15 +2704,14 @@ const docTemplate = `{
                     "example": "DEFAULT_GATEWAY"
                 },
                 "metadata": {
-                    "description": "Optional additional data",
+                    "description": "Optional additional data, user_id, gateway_id, country_id, hold_id and bank_account are reserved",
                     "type": "object",
                     "additionalProperties": {
                         "type": "string"
                     },
                     "example": {
-                        "country_id": "3",
-                        "currency": "USD",
-                        "gateway_id": "7"
+                        "invoice": "inv-42",
+                        "team": "payroll"
                     }
                 },
                 "method": {
@@ -477,7 +2730,7 @@ const docTemplate = `{
                     "example": "EXINITY PAYOUT"
                 },
                 "user_id": {
-                    "description": "User ID making the withdrawal",
+                    "description": "User ID, taken from the token for end users",
                     "type": "string",
                     "example": "1"
                 }
@@ -522,6 +2775,11 @@ const docTemplate = `{
                         }
                     ]
                 },
+                "failure_code": {
+                    "description": "Decline or error code on failure events",
+                    "type": "string",
+                    "example": "card_declined"
+                },
                 "id": {
                     "description": "Unique event identifier",
                     "type": "string",
@@ -571,7 +2829,7 @@ const docTemplate = `{
                     "example": "STRIPE"
                 },
                 "user_id": {
-                    "description": "Unique user identifier",
+                    "description": "User ID, taken from the token for end users",
                     "type": "string",
                     "example": "1"
                 }
@@ -584,6 +2842,13 @@ const docTemplate = `{
                 "name"
             ],
             "properties": {
+                "capabilities": {
+                    "description": "What the gateway supports in the country",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.GatewayCapability"
+                    }
+                },
                 "id": {
                     "description": "Unique gateway identifier",
                     "type": "string",
@@ -594,11 +2859,354 @@ const docTemplate = `{
                     "type": "string",
                     "example": "STRIPE"
                 },
+                "sample_size": {
+                    "description": "Number of outcomes in the scoring window",
+                    "type": "integer",
+                    "example": 120
+                },
                 "score": {
-                    "type": "integer"
+                    "description": "Ranking score derived from the success ratio",
+                    "type": "number",
+                    "example": 0.92
+                },
+                "success_ratio": {
+                    "description": "Decayed success ratio over the scoring window",
+                    "type": "number",
+                    "example": 0.95
+                }
+            }
+        },
+        "models.GatewayCapability": {
+            "type": "object",
+            "properties": {
+                "country_id": {
+                    "type": "string",
+                    "example": "2"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "AED"
+                },
+                "data_format": {
+                    "description": "Data format spoken by the gateway",
+                    "type": "string",
+                    "example": "JSON"
+                },
+                "gateway_id": {
+                    "type": "string",
+                    "example": "3"
+                },
+                "max_amount": {
+                    "description": "Inclusive, 0 for no upper limit",
+                    "type": "integer",
+                    "example": 10000000
+                },
+                "min_amount": {
+                    "description": "Inclusive, smallest currency unit",
+                    "type": "integer",
+                    "example": 100
+                },
+                "payout_methods": {
+                    "description": "Supported payout methods",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "standard",
+                        "instant"
+                    ]
+                },
+                "supports_deposit": {
+                    "description": "Deposits can be accepted",
+                    "type": "boolean",
+                    "example": true
+                },
+                "supports_withdrawal": {
+                    "description": "Payouts can be made",
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "models.GatewayRequest": {
+            "type": "object",
+            "required": [
+                "data_format_supported",
+                "name"
+            ],
+            "properties": {
+                "data_format_supported": {
+                    "description": "Payload format used by the gateway",
+                    "type": "string",
+                    "example": "application/json"
+                },
+                "enabled": {
+                    "description": "Defaults to true on creation",
+                    "type": "boolean",
+                    "example": true
+                },
+                "name": {
+                    "description": "Gateway name, must match the registered PSP",
+                    "type": "string",
+                    "example": "RAZORPAY"
+                }
+            }
+        },
+        "models.GatewayStateRequest": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "elevated decline rate"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "enabled",
+                        "paused"
+                    ],
+                    "example": "paused"
+                }
+            }
+        },
+        "models.ReviewDecisionRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "description": "Required when rejecting",
+                    "type": "string",
+                    "maxLength": 500,
+                    "example": "confirmed with the user by phone"
+                }
+            }
+        },
+        "models.RoutingDecision": {
+            "type": "object",
+            "properties": {
+                "gateways": {
+                    "description": "Candidate gateways after the matched rule was applied",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Gateway"
+                    }
+                },
+                "matched_rule": {
+                    "description": "First rule that matched, nil when score ranking alone applies",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.RoutingRule"
+                        }
+                    ]
+                },
+                "trace": {
+                    "description": "Every rule evaluated, in priority order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RuleEvaluation"
+                    }
+                }
+            }
+        },
+        "models.RoutingRequest": {
+            "type": "object",
+            "required": [
+                "country_id",
+                "type"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Amount in the smallest currency unit",
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 1500000
+                },
+                "at": {
+                    "description": "Evaluation time, defaults to now",
+                    "type": "string",
+                    "example": "2025-01-01T10:00:00Z"
+                },
+                "country_id": {
+                    "description": "Country ID",
+                    "type": "string",
+                    "example": "1"
+                },
+                "currency": {
+                    "description": "Currency code",
+                    "type": "string",
+                    "example": "INR"
+                },
+                "method": {
+                    "description": "Payout method",
+                    "type": "string",
+                    "example": "instant"
+                },
+                "type": {
+                    "description": "deposit or withdrawal",
+                    "type": "string",
+                    "enum": [
+                        "deposit",
+                        "withdrawal"
+                    ],
+                    "example": "deposit"
+                },
+                "user_id": {
+                    "description": "User ID, used to look up the segment",
+                    "type": "string",
+                    "example": "1"
+                },
+                "user_segment": {
+                    "description": "Overrides the stored user segment",
+                    "type": "string",
+                    "example": "vip"
+                }
+            }
+        },
+        "models.RoutingRule": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "route, prefer or exclude",
+                    "type": "string",
+                    "example": "route"
+                },
+                "country_id": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "INR"
+                },
+                "enabled": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "end_hour": {
+                    "description": "UTC hour the rule stops being active",
+                    "type": "integer",
+                    "example": 17
+                },
+                "gateway_id": {
+                    "description": "Gateway the action applies to",
+                    "type": "integer",
+                    "example": 2
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "max_amount": {
+                    "description": "Inclusive, smallest currency unit",
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "method": {
+                    "type": "string",
+                    "example": "instant"
+                },
+                "min_amount": {
+                    "description": "Inclusive, smallest currency unit",
+                    "type": "integer",
+                    "example": 1000001
+                },
+                "name": {
+                    "type": "string",
+                    "example": "large INR deposits via razorpay"
+                },
+                "priority": {
+                    "description": "Lower values are evaluated first",
+                    "type": "integer",
+                    "example": 10
+                },
+                "start_hour": {
+                    "description": "UTC hour the rule becomes active",
+                    "type": "integer",
+                    "example": 9
+                },
+                "transaction_type": {
+                    "type": "string",
+                    "example": "deposit"
+                },
+                "user_segment": {
+                    "type": "string",
+                    "example": "vip"
+                }
+            }
+        },
+        "models.RuleEvaluation": {
+            "type": "object",
+            "properties": {
+                "matched": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "type": "string",
+                    "example": "large INR deposits via razorpay"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "amount 5000 below minimum 1000001"
+                },
+                "rule_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "models.StatusRequest": {
+            "type": "object",
+            "required": [
+                "enabled"
+            ],
+            "properties": {
+                "enabled": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "models.TokenizeBankDetailsRequest": {
+            "type": "object",
+            "required": [
+                "bank_details",
+                "user_id"
+            ],
+            "properties": {
+                "bank_details": {
+                    "description": "Bank details to tokenize",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BankAccountDetails"
+                        }
+                    ]
+                },
+                "user_id": {
+                    "description": "Taken from the token for end users, required for internal callers",
+                    "type": "string",
+                    "example": "1"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key ID, sent with X-Timestamp and the HMAC X-Signature",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT bearer token, \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
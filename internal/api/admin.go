@@ -0,0 +1,488 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"payment-gateway/db"
+	store "payment-gateway/db/db"
+	"payment-gateway/internal/models"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// adminHandler is the signature shared by the admin handlers
+type adminHandler func(w http.ResponseWriter, r *http.Request, db *db.DB)
+
+// ListGatewaysHandler lists every gateway, including disabled ones.
+// @Summary List gateways
+// @Description Returns every configured gateway with its global enabled flag
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Gateways"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/gateways [get]
+func ListGatewaysHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	gateways, err := db.Admin.GetGateways(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching gateways", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, gateways)
+}
+
+// CreateGatewayHandler creates a gateway.
+// @Summary Create a gateway
+// @Description Creates a gateway, enabled unless stated otherwise. It only becomes routable once mapped to a country.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param gateway body models.GatewayRequest true "Gateway"
+// @Success 201 {object} map[string]interface{} "Created gateway"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/gateways [post]
+func CreateGatewayHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	var reqBody models.GatewayRequest
+	if !decodeAdminRequest(w, r, &reqBody) {
+		return
+	}
+
+	enabled := reqBody.Enabled == nil || *reqBody.Enabled
+	gateway, err := db.Admin.CreateGateway(r.Context(), store.Gateway{
+		Name:                reqBody.Name,
+		DataFormatSupported: reqBody.DataFormatSupported,
+		Enabled:             enabled,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating gateway", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusCreated, gateway)
+}
+
+// UpdateGatewayHandler updates a gateway.
+// @Summary Update a gateway
+// @Description Updates the name, data format and optionally the enabled flag of a gateway and invalidates the cached rankings of its countries
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param gatewayID path int true "Gateway ID"
+// @Param gateway body models.GatewayRequest true "Gateway"
+// @Success 200 {object} map[string]interface{} "Updated gateway"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Gateway not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/gateways/{gatewayID} [put]
+func UpdateGatewayHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	gatewayID, ok := pathID(w, r, "gatewayID")
+	if !ok {
+		return
+	}
+	var reqBody models.GatewayRequest
+	if !decodeAdminRequest(w, r, &reqBody) {
+		return
+	}
+
+	gateway := store.Gateway{ID: gatewayID, Name: reqBody.Name, DataFormatSupported: reqBody.DataFormatSupported}
+	if err := db.Admin.UpdateGateway(r.Context(), gateway); err != nil {
+		writeAdminError(w, r, err, "error updating gateway")
+		return
+	}
+	if reqBody.Enabled != nil {
+		if err := db.Admin.SetGatewayEnabled(r.Context(), gatewayID, *reqBody.Enabled); err != nil {
+			writeAdminError(w, r, err, "error updating gateway")
+			return
+		}
+	}
+
+	if err := invalidateGatewayCountries(r, db, gatewayID); err != nil {
+		slog.ErrorContext(r.Context(), "error invalidating gateway cache", "error", err)
+	}
+	writeData(w, http.StatusOK, gateway)
+}
+
+// DeleteGatewayHandler deletes a gateway.
+// @Summary Delete a gateway
+// @Description Deletes a gateway with its country mappings and invalidates the cached rankings of those countries
+// @Tags admin
+// @Security BearerAuth
+// @Param gatewayID path int true "Gateway ID"
+// @Success 204 "Gateway deleted"
+// @Failure 400 {object} map[string]string "Invalid gateway ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Gateway not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/gateways/{gatewayID} [delete]
+func DeleteGatewayHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	gatewayID, ok := pathID(w, r, "gatewayID")
+	if !ok {
+		return
+	}
+
+	// Mappings are gone after the delete, so collect the countries first
+	countries, err := db.Admin.GetSupportedCountriesByGateway(r.Context(), gatewayID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching gateway countries", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := db.Admin.DeleteGateway(r.Context(), gatewayID); err != nil {
+		writeAdminError(w, r, err, "error deleting gateway")
+		return
+	}
+
+	if err := db.Redis.InvalidateGatewaysByCountry(r.Context(), countryIDs(countries)...); err != nil {
+		slog.ErrorContext(r.Context(), "error invalidating gateway cache", "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetGatewayStatusHandler enables or disables a gateway in every country.
+// @Summary Enable or disable a gateway globally
+// @Description Disabled gateways are excluded from every country's ranking. The cached rankings of its countries are invalidated.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param gatewayID path int true "Gateway ID"
+// @Param status body models.StatusRequest true "Enabled flag"
+// @Success 200 {object} map[string]interface{} "Updated status"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Gateway not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/gateways/{gatewayID}/status [put]
+func SetGatewayStatusHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	gatewayID, ok := pathID(w, r, "gatewayID")
+	if !ok {
+		return
+	}
+	var reqBody models.StatusRequest
+	if !decodeAdminRequest(w, r, &reqBody) {
+		return
+	}
+
+	if err := db.Admin.SetGatewayEnabled(r.Context(), gatewayID, *reqBody.Enabled); err != nil {
+		writeAdminError(w, r, err, "error updating gateway")
+		return
+	}
+
+	if err := invalidateGatewayCountries(r, db, gatewayID); err != nil {
+		slog.ErrorContext(r.Context(), "error invalidating gateway cache", "error", err)
+	}
+	writeData(w, http.StatusOK, map[string]interface{}{"gateway_id": gatewayID, "enabled": *reqBody.Enabled})
+}
+
+// ListCountriesHandler lists every country.
+// @Summary List countries
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Countries"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/countries [get]
+func ListCountriesHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	countries, err := db.Admin.GetCountries(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching countries", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, countries)
+}
+
+// CreateCountryHandler creates a country.
+// @Summary Create a country
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param country body models.CountryRequest true "Country"
+// @Success 201 {object} map[string]interface{} "Created country"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/countries [post]
+func CreateCountryHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	var reqBody models.CountryRequest
+	if !decodeAdminRequest(w, r, &reqBody) {
+		return
+	}
+
+	country, err := db.Admin.CreateCountry(r.Context(), store.Country{Name: reqBody.Name, Code: reqBody.Code, Currency: reqBody.Currency})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating country", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusCreated, country)
+}
+
+// UpdateCountryHandler updates a country.
+// @Summary Update a country
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param countryID path int true "Country ID"
+// @Param country body models.CountryRequest true "Country"
+// @Success 200 {object} map[string]interface{} "Updated country"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Country not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/countries/{countryID} [put]
+func UpdateCountryHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	countryID, ok := pathID(w, r, "countryID")
+	if !ok {
+		return
+	}
+	var reqBody models.CountryRequest
+	if !decodeAdminRequest(w, r, &reqBody) {
+		return
+	}
+
+	country := store.Country{ID: countryID, Name: reqBody.Name, Code: reqBody.Code, Currency: reqBody.Currency}
+	if err := db.Admin.UpdateCountry(r.Context(), country); err != nil {
+		writeAdminError(w, r, err, "error updating country")
+		return
+	}
+	writeData(w, http.StatusOK, country)
+}
+
+// DeleteCountryHandler deletes a country.
+// @Summary Delete a country
+// @Description Deletes a country with its gateway mappings and invalidates its cached ranking
+// @Tags admin
+// @Security BearerAuth
+// @Param countryID path int true "Country ID"
+// @Success 204 "Country deleted"
+// @Failure 400 {object} map[string]string "Invalid country ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Country not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/countries/{countryID} [delete]
+func DeleteCountryHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	countryID, ok := pathID(w, r, "countryID")
+	if !ok {
+		return
+	}
+
+	if err := db.Admin.DeleteCountry(r.Context(), countryID); err != nil {
+		writeAdminError(w, r, err, "error deleting country")
+		return
+	}
+
+	if err := db.Redis.InvalidateGatewaysByCountry(r.Context(), strconv.Itoa(countryID)); err != nil {
+		slog.ErrorContext(r.Context(), "error invalidating gateway cache", "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListCountryGatewaysHandler lists the gateways mapped to a country.
+// @Summary List the gateway mappings of a country
+// @Description Returns every gateway mapped to the country with its per-country enabled flag
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param countryID path int true "Country ID"
+// @Success 200 {object} map[string]interface{} "Gateway mappings"
+// @Failure 400 {object} map[string]string "Invalid country ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/countries/{countryID}/gateways [get]
+func ListCountryGatewaysHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	countryID, ok := pathID(w, r, "countryID")
+	if !ok {
+		return
+	}
+
+	mappings, err := db.Admin.GetGatewayCountries(r.Context(), countryID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching gateway mappings", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, mappings)
+}
+
+// AddCountryGatewayHandler maps a gateway to a country.
+// @Summary Map a gateway to a country
+// @Description Creates or re-enables the mapping and invalidates the country's cached ranking
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param countryID path int true "Country ID"
+// @Param gatewayID path int true "Gateway ID"
+// @Success 200 {object} map[string]interface{} "Mapping created"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/countries/{countryID}/gateways/{gatewayID} [put]
+func AddCountryGatewayHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	countryID, ok := pathID(w, r, "countryID")
+	if !ok {
+		return
+	}
+	gatewayID, ok := pathID(w, r, "gatewayID")
+	if !ok {
+		return
+	}
+
+	if err := db.Admin.AddGatewayCountry(r.Context(), gatewayID, countryID); err != nil {
+		writeAdminError(w, r, err, "error mapping gateway")
+		return
+	}
+
+	if err := db.Redis.InvalidateGatewaysByCountry(r.Context(), strconv.Itoa(countryID)); err != nil {
+		slog.ErrorContext(r.Context(), "error invalidating gateway cache", "error", err)
+	}
+	writeData(w, http.StatusOK, store.GatewayCountry{GatewayID: gatewayID, CountryID: countryID, Enabled: true})
+}
+
+// RemoveCountryGatewayHandler unmaps a gateway from a country.
+// @Summary Unmap a gateway from a country
+// @Description Deletes the mapping and invalidates the country's cached ranking
+// @Tags admin
+// @Security BearerAuth
+// @Param countryID path int true "Country ID"
+// @Param gatewayID path int true "Gateway ID"
+// @Success 204 "Mapping deleted"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Mapping not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/countries/{countryID}/gateways/{gatewayID} [delete]
+func RemoveCountryGatewayHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	countryID, ok := pathID(w, r, "countryID")
+	if !ok {
+		return
+	}
+	gatewayID, ok := pathID(w, r, "gatewayID")
+	if !ok {
+		return
+	}
+
+	if err := db.Admin.RemoveGatewayCountry(r.Context(), gatewayID, countryID); err != nil {
+		writeAdminError(w, r, err, "error unmapping gateway")
+		return
+	}
+
+	if err := db.Redis.InvalidateGatewaysByCountry(r.Context(), strconv.Itoa(countryID)); err != nil {
+		slog.ErrorContext(r.Context(), "error invalidating gateway cache", "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetCountryGatewayStatusHandler enables or disables a gateway in a single country.
+// @Summary Enable or disable a gateway in a country
+// @Description Updates the per-country enabled flag and invalidates the country's cached ranking
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param countryID path int true "Country ID"
+// @Param gatewayID path int true "Gateway ID"
+// @Param status body models.StatusRequest true "Enabled flag"
+// @Success 200 {object} map[string]interface{} "Updated mapping"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Mapping not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/countries/{countryID}/gateways/{gatewayID}/status [put]
+func SetCountryGatewayStatusHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+	countryID, ok := pathID(w, r, "countryID")
+	if !ok {
+		return
+	}
+	gatewayID, ok := pathID(w, r, "gatewayID")
+	if !ok {
+		return
+	}
+	var reqBody models.StatusRequest
+	if !decodeAdminRequest(w, r, &reqBody) {
+		return
+	}
+
+	if err := db.Admin.SetGatewayCountryEnabled(r.Context(), gatewayID, countryID, *reqBody.Enabled); err != nil {
+		writeAdminError(w, r, err, "error updating gateway mapping")
+		return
+	}
+
+	if err := db.Redis.InvalidateGatewaysByCountry(r.Context(), strconv.Itoa(countryID)); err != nil {
+		slog.ErrorContext(r.Context(), "error invalidating gateway cache", "error", err)
+	}
+	writeData(w, http.StatusOK, store.GatewayCountry{GatewayID: gatewayID, CountryID: countryID, Enabled: *reqBody.Enabled})
+}
+
+// invalidateGatewayCountries drops the cached ranking of every country the gateway is mapped to
+func invalidateGatewayCountries(r *http.Request, db *db.DB, gatewayID int) error {
+	countries, err := db.Admin.GetSupportedCountriesByGateway(r.Context(), gatewayID)
+	if err != nil {
+		return err
+	}
+	return db.Redis.InvalidateGatewaysByCountry(r.Context(), countryIDs(countries)...)
+}
+
+// countryIDs returns the IDs of the countries as strings, as used in Redis keys
+func countryIDs(countries []store.Country) []string {
+	ids := make([]string, len(countries))
+	for i, country := range countries {
+		ids[i] = strconv.Itoa(country.ID)
+	}
+	return ids
+}
+
+// pathID parses a numeric path variable, answering 400 when it is invalid
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: invalid %s", name), http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeAdminRequest decodes and validates an admin request body, answering 400 on failure
+func decodeAdminRequest(w http.ResponseWriter, r *http.Request, reqBody interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(reqBody); err != nil {
+		slog.WarnContext(r.Context(), "invalid request body", "error", err)
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return false
+	}
+	if err := models.ValidateAdminRequest(reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeAdminError answers 404 for unknown records and 500 otherwise
+func writeAdminError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	slog.ErrorContext(r.Context(), message, "error", err)
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// writeData writes the payload wrapped in a "data" envelope
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
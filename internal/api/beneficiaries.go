@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"payment-gateway/internal/beneficiary"
+	"payment-gateway/internal/models"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateBeneficiaryHandler saves a payout bank account for a user.
+// @Summary Save a beneficiary
+// @Description Validates the bank details against the rules of their country (ABA routing number, UK sort code, IFSC or IBAN), stores them in the vault and saves a pending beneficiary. Withdrawals can reference it once verified.
+// @Tags beneficiaries
+// @Accept json
+// @Produce json
+// @Param request body models.BeneficiaryRequest true "Beneficiary"
+// @Success 201 {object} map[string]interface{} "Saved beneficiary"
+// @Failure 400 {object} map[string]string "Invalid request payload or bank details"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "user_id does not match the authenticated user"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
+// @Router /beneficiaries [post]
+func CreateBeneficiaryHandler(w http.ResponseWriter, r *http.Request, beneficiaries *beneficiary.Service) {
+	var reqBody models.BeneficiaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		slog.WarnContext(r.Context(), "invalid request body", "error", err)
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if !resolveUserID(w, r, &reqBody.UserID) {
+		return
+	}
+
+	if err := models.ValidateBeneficiaryRequest(reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := beneficiaries.Create(r.Context(), reqBody)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving beneficiary", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusCreated, saved)
+}
+
+// ListBeneficiariesHandler lists the saved beneficiaries of a user.
+// @Summary List beneficiaries
+// @Tags beneficiaries
+// @Produce json
+// @Param user_id query string false "User ID, required for internal callers" example:"1"
+// @Success 200 {object} map[string]interface{} "Beneficiaries"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "user_id does not match the authenticated user"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
+// @Router /beneficiaries [get]
+func ListBeneficiariesHandler(w http.ResponseWriter, r *http.Request, beneficiaries *beneficiary.Service) {
+	userID := r.URL.Query().Get("user_id")
+	if !resolveUserID(w, r, &userID) {
+		return
+	}
+	if userID == "" {
+		http.Error(w, "Bad Request: user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	list, err := beneficiaries.List(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching beneficiaries", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, list)
+}
+
+// DeleteBeneficiaryHandler deletes a saved beneficiary of a user.
+// @Summary Delete a beneficiary
+// @Tags beneficiaries
+// @Param beneficiaryID path int true "Beneficiary ID"
+// @Param user_id query string false "User ID, required for internal callers" example:"1"
+// @Success 204 "Beneficiary deleted"
+// @Failure 400 {object} map[string]string "Invalid beneficiary ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Beneficiary not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
+// @Router /beneficiaries/{beneficiaryID} [delete]
+func DeleteBeneficiaryHandler(w http.ResponseWriter, r *http.Request, beneficiaries *beneficiary.Service) {
+	id, err := strconv.ParseInt(mux.Vars(r)["beneficiaryID"], 10, 64)
+	if err != nil {
+		http.Error(w, "Bad Request: invalid beneficiaryID", http.StatusBadRequest)
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	if !resolveUserID(w, r, &userID) {
+		return
+	}
+
+	if err := beneficiaries.Delete(r.Context(), userID, id); err != nil {
+		writeBeneficiaryError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyBeneficiaryHandler records the outcome of a beneficiary verification.
+// @Summary Verify or reject a beneficiary
+// @Description Marks a beneficiary verified, making it usable for withdrawals, or rejected with a reason
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param beneficiaryID path int true "Beneficiary ID"
+// @Param request body models.BeneficiaryVerificationRequest true "Verification outcome"
+// @Success 200 {object} map[string]interface{} "Updated status"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Beneficiary not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/beneficiaries/{beneficiaryID}/verification [put]
+func VerifyBeneficiaryHandler(w http.ResponseWriter, r *http.Request, beneficiaries *beneficiary.Service) {
+	id, err := strconv.ParseInt(mux.Vars(r)["beneficiaryID"], 10, 64)
+	if err != nil {
+		http.Error(w, "Bad Request: invalid beneficiaryID", http.StatusBadRequest)
+		return
+	}
+
+	var reqBody models.BeneficiaryVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		slog.WarnContext(r.Context(), "invalid request body", "error", err)
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateBeneficiaryVerificationRequest(reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if err := beneficiaries.SetStatus(r.Context(), id, reqBody.Status, reqBody.Reason); err != nil {
+		writeBeneficiaryError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, map[string]interface{}{"id": id, "status": reqBody.Status})
+}
+
+// writeBeneficiaryError answers 404 for unknown beneficiaries and 500 otherwise
+func writeBeneficiaryError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, beneficiary.ErrNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	slog.ErrorContext(r.Context(), "error updating beneficiary", "error", err)
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
@@ -1,16 +1,31 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"payment-gateway/db"
-	"payment-gateway/db/redis"
+	store "payment-gateway/db/db"
+	"payment-gateway/internal/auth"
+	"payment-gateway/internal/beneficiary"
+	"payment-gateway/internal/logging"
+	"payment-gateway/internal/metrics"
 	"payment-gateway/internal/models"
 	"payment-gateway/internal/psp"
+	"payment-gateway/internal/ratelimit"
+	"payment-gateway/internal/risk"
+	"payment-gateway/internal/routing"
+	"payment-gateway/internal/tracing"
+	"payment-gateway/internal/vault"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DepositHandler handles deposit requests via POST request
@@ -21,11 +36,19 @@ import (
 // @Produce json
 // @Param deposit body models.DepositRequest true "Deposit request payload"
 // @Success 200 {object} map[string]interface{} "Deposit created successfully"
-// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Success 202 {object} map[string]interface{} "Deposit parked for manual approval"
+// @Failure 400 {object} map[string]string "Invalid request payload or unsupported by the gateway"
+// @Failure 403 {object} map[string]string "user_id does not match the authenticated user, or declined by the risk checks"
 // @Failure 405 {object} map[string]string "Method not allowed"
 // @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
+// @Failure 503 {object} map[string]string "Gateway paused"
+// @Failure 504 {object} map[string]string "Gateway timed out, retry with the same X-Request-ID"
 // @Router /deposit [post]
-func DepositHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, redisClient redis.IRedis) {
+func DepositHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB, rules *routing.Engine, risks *risk.Engine, reviews *risk.Queue) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -35,27 +58,121 @@ func DepositHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, redisC
 	var reqBody models.DepositRequest
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		log.Println("Error decoding request body:", err.Error())
+		slog.WarnContext(r.Context(), "invalid request body", "error", err)
 		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
+	// Act on the authenticated user's account
+	if !resolveUserID(w, r, &reqBody.UserID) {
+		return
+	}
+
 	// Validate request body
 	if err := models.ValidateDepositRequest(reqBody); err != nil {
-		log.Println("Error validating request body:", err.Error())
+		slog.WarnContext(r.Context(), "invalid request", "error", err)
 		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
 		return
 	}
 
-	// Generate Order ID
-	p, _ := psp.Get(reqBody.GatewayName)
-	orderID, client_secret, err := p.Deposit(reqBody)
+	// Check the gateway named by the request is the one of its ID and is enabled in the country
+	gateway, ok := checkGateway(w, r, db, reqBody.GatewayID, reqBody.GatewayName, reqBody.CountryID)
+	if !ok {
+		return
+	}
+
+	// Check the gateway can handle the deposit
+	capabilities, err := db.DB.GetGatewayCapabilitiesByCountry(r.Context(), reqBody.CountryID)
 	if err != nil {
-		log.Println("Error during deposit:", err.Error())
+		slog.ErrorContext(r.Context(), "error fetching gateway capabilities", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	log.Println("Generated OrderID:", orderID)
+	if err := validateDepositRequest(reqBody, gateway, psp, capabilities); err != nil {
+		slog.WarnContext(r.Context(), "deposit not supported by the gateway", "error", err)
+		writeGatewayError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// Check the routing rules allow the requested gateway
+	amount, _ := strconv.ParseInt(reqBody.Amount, 10, 64)
+	if !checkRouting(w, r, rules, models.RoutingRequest{
+		Type:      "deposit",
+		CountryID: reqBody.CountryID,
+		Currency:  reqBody.Currency,
+		Amount:    amount,
+		UserID:    reqBody.UserID,
+	}, reqBody.GatewayID) {
+		return
+	}
+
+	// Score the deposit before it reaches the gateway
+	if !screenRisk(w, r, risks, reviews, models.RiskRequest{
+		Type:      "deposit",
+		UserID:    reqBody.UserID,
+		CountryID: reqBody.CountryID,
+		Currency:  reqBody.Currency,
+		Amount:    amount,
+		GatewayID: reqBody.GatewayID,
+	}, reqBody) {
+		return
+	}
+
+	data, err := createDeposit(r.Context(), psp, db, reqBody)
+	if err != nil {
+		writePSPError(w, r, "deposit", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// recordGatewayLatency feeds the gateway score, a failure only costs scoring accuracy
+func recordGatewayLatency(ctx context.Context, db *db.DB, countryID, gatewayID string, latency time.Duration) {
+	if err := db.Redis.RecordGatewayLatency(ctx, countryID, gatewayID, latency); err != nil {
+		slog.WarnContext(ctx, "failed to record gateway latency", "country_id", countryID, "gateway_id", gatewayID, "error", err)
+	}
+}
+
+// recordGatewayOutcome feeds the gateway score, a failure only costs scoring accuracy
+func recordGatewayOutcome(ctx context.Context, db *db.DB, countryID, gatewayID string, outcome models.GatewayOutcome) {
+	if err := db.Redis.RecordGatewayOutcome(ctx, countryID, gatewayID, outcome); err != nil {
+		slog.WarnContext(ctx, "failed to record gateway outcome", "country_id", countryID, "gateway_id", gatewayID, "error", err)
+	}
+}
+
+// createDeposit creates the deposit at its gateway and stores it in Redis
+func createDeposit(ctx context.Context, psp *psp.PSP, db *db.DB, reqBody models.DepositRequest) (map[string]interface{}, error) {
+	// Generate Order ID
+	p, err := psp.Available(reqBody.GatewayName)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	spanCtx, span := tracing.Start(ctx, "psp.deposit", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("psp.gateway", p.GetName()), attribute.String("psp.gateway_id", reqBody.GatewayID)))
+	orderID, client_secret, err := p.Deposit(spanCtx, reqBody)
+	span.SetAttributes(attribute.String("psp.order_id", orderID))
+	tracing.End(span, err)
+	// The gateway answered, its outcome is recorded even if the client has gone
+	ctx = context.WithoutCancel(ctx)
+	recordGatewayLatency(ctx, db, reqBody.CountryID, reqBody.GatewayID, time.Since(start))
+	metrics.ObservePSPCall(p.GetName(), "deposit", start, err)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			recordGatewayOutcome(ctx, db, reqBody.CountryID, reqBody.GatewayID,
+				models.GatewayOutcome{ErrorType: models.GatewayErrorTimeout})
+		case !errors.Is(err, context.Canceled):
+			recordGatewayOutcome(ctx, db, reqBody.CountryID, reqBody.GatewayID,
+				models.GatewayOutcome{ErrorType: models.GatewayErrorUnavailable})
+		}
+		metrics.RecordPayment(p.GetName(), "deposit", metrics.OutcomeRejected)
+		return nil, err
+	}
+	metrics.RecordPayment(p.GetName(), "deposit", metrics.OutcomeCreated)
+	ctx = logging.WithOrderID(ctx, orderID)
+	slog.InfoContext(ctx, "deposit created", "gateway", reqBody.GatewayName, "user_id", reqBody.UserID)
 
 	// Store Data in Redis
 	data := map[string]interface{}{
@@ -66,18 +183,15 @@ func DepositHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, redisC
 		"country_id":    reqBody.CountryID,
 		"order_id":      orderID,
 		"client_secret": client_secret,
-		"status":        "created",
 	}
 
+	// A webhook may have stored a later status already
 	key := fmt.Sprintf("deposit:userid:%s:orderid:%s", reqBody.UserID, orderID)
-	err = redisClient.HSet(r.Context(), key, data)
-	if err != nil {
-		log.Println("Error storing data in redis:", err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	if err := db.Redis.CreateStatus(ctx, key, "created", data); err != nil {
+		return nil, fmt.Errorf("failed to store deposit in redis: %v", err)
 	}
-
-	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	data["status"] = "created"
+	return data, nil
 }
 
 // WithdrawalHandler handles withdrawal requests.
@@ -88,12 +202,20 @@ func DepositHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, redisC
 // @Produce json
 // @Param withdrawal body models.CustomWithdrawalRequest true "Withdrawal request payload"
 // @Success 200 {object} map[string]interface{} "Withdrawal created successfully"
-// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Success 202 {object} map[string]interface{} "Withdrawal above the approval threshold or flagged by the risk checks, parked for manual approval with its funds held"
+// @Failure 400 {object} map[string]string "Invalid request payload, unsupported by the gateway or insufficient balance"
 // @Failure 404 {object} map[string]string "Invalid gateway name"
+// @Failure 403 {object} map[string]string "user_id does not match the authenticated user, or declined by the risk checks"
 // @Failure 405 {object} map[string]string "Method not allowed"
 // @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
+// @Failure 503 {object} map[string]string "Gateway paused"
+// @Failure 504 {object} map[string]string "Gateway timed out, retry with the same X-Request-ID"
 // @Router /withdrawal [post]
-func WithdrawalHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB) {
+func WithdrawalHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB, rules *routing.Engine, tokens *vault.Vault, beneficiaries *beneficiary.Service, risks *risk.Engine, reviews *risk.Queue, velocity *ratelimit.VelocityLimit) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -103,32 +225,131 @@ func WithdrawalHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db
 	var reqBody models.CustomWithdrawalRequest
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		log.Println("Error decoding request body:", err.Error())
+		slog.WarnContext(r.Context(), "invalid request body", "error", err)
 		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
+	// Act on the authenticated user's account
+	if !resolveUserID(w, r, &reqBody.UserID) {
+		return
+	}
+
+	// Count the withdrawal against the user it pays out for, whoever the caller is
+	w, done, ok := velocity.Reserve(w, r, reqBody.UserID)
+	if !ok {
+		return
+	}
+	defer done()
+
 	// Validate request body
 	if err := models.ValidateCustomWithdrawalRequest(reqBody); err != nil {
-		log.Println("Error validating request body:", err.Error())
+		slog.WarnContext(r.Context(), "invalid request", "error", err)
 		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
 		return
 	}
 
-	// Generate Order ID
-	p, err := psp.Get(reqBody.GatewayName)
-	if err != nil {
-		log.Println("Error invalid Gateway Name", reqBody.GatewayName)
-		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusNotFound)
+	// Swap a bank details token or beneficiary for the details held in the vault
+	payeeAddedAt, ok := resolveBankDetails(w, r, tokens, beneficiaries, &reqBody)
+	if !ok {
+		return
+	}
+
+	// Check the gateway named by the request is the one of its ID and is enabled in the country
+	gateway, ok := checkGateway(w, r, db, reqBody.GatewayID, reqBody.GatewayName, reqBody.CountryID)
+	if !ok {
 		return
 	}
-	payoutID, err := p.Withdrawal(reqBody, db)
+
+	// Check the gateway can handle the withdrawal
+	capabilities, err := db.DB.GetGatewayCapabilitiesByCountry(r.Context(), reqBody.CountryID)
 	if err != nil {
-		log.Println("Error during deposit:", err.Error())
+		slog.ErrorContext(r.Context(), "error fetching gateway capabilities", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	log.Println("Generated payoutID:", payoutID)
+	if err := validateWithdrawalRequest(reqBody, gateway, psp, capabilities); err != nil {
+		slog.WarnContext(r.Context(), "withdrawal not supported by the gateway", "error", err)
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	// Check the routing rules allow the requested gateway
+	if !checkRouting(w, r, rules, models.RoutingRequest{
+		Type:      "withdrawal",
+		CountryID: reqBody.CountryID,
+		Currency:  reqBody.Currency,
+		Amount:    reqBody.Amount,
+		UserID:    reqBody.UserID,
+		Method:    reqBody.Method,
+	}, reqBody.GatewayID) {
+		return
+	}
+
+	if _, err := psp.Available(reqBody.GatewayName); err != nil {
+		slog.WarnContext(r.Context(), "gateway unavailable", "gateway", reqBody.GatewayName, "error", err)
+		writeGatewayError(w, err, http.StatusNotFound)
+		return
+	}
+
+	// Score the withdrawal before it reaches the gateway
+	if !screenRisk(w, r, risks, reviews, models.RiskRequest{
+		Type:         "withdrawal",
+		UserID:       reqBody.UserID,
+		CountryID:    reqBody.CountryID,
+		Currency:     reqBody.Currency,
+		Amount:       reqBody.Amount,
+		GatewayID:    reqBody.GatewayID,
+		PayeeAddedAt: payeeAddedAt,
+	}, reqBody) {
+		return
+	}
+
+	data, err := createWithdrawal(r.Context(), psp, db, reqBody)
+	if err != nil {
+		writePSPError(w, r, "withdrawal", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+
+}
+
+// createWithdrawal creates the payout at its gateway and stores it in Redis
+func createWithdrawal(ctx context.Context, psp *psp.PSP, db *db.DB, reqBody models.CustomWithdrawalRequest) (map[string]interface{}, error) {
+	// Generate Order ID
+	p, err := psp.Available(reqBody.GatewayName)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	spanCtx, span := tracing.Start(ctx, "psp.withdrawal", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("psp.gateway", p.GetName()), attribute.String("psp.gateway_id", reqBody.GatewayID)))
+	payoutID, err := p.Withdrawal(spanCtx, reqBody, db)
+	span.SetAttributes(attribute.String("psp.order_id", payoutID))
+	tracing.End(span, err)
+	// The gateway answered, its outcome is recorded even if the client has gone. A
+	// balance too low is declined before the gateway is called.
+	ctx = context.WithoutCancel(ctx)
+	if !errors.Is(err, risk.ErrInsufficientFunds) {
+		recordGatewayLatency(ctx, db, reqBody.CountryID, reqBody.GatewayID, time.Since(start))
+	}
+	metrics.ObservePSPCall(p.GetName(), "withdrawal", start, err)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			recordGatewayOutcome(ctx, db, reqBody.CountryID, reqBody.GatewayID,
+				models.GatewayOutcome{ErrorType: models.GatewayErrorTimeout})
+		case !errors.Is(err, context.Canceled) && !errors.Is(err, risk.ErrInsufficientFunds):
+			recordGatewayOutcome(ctx, db, reqBody.CountryID, reqBody.GatewayID,
+				models.GatewayOutcome{ErrorType: models.GatewayErrorUnavailable})
+		}
+		metrics.RecordPayment(p.GetName(), "withdrawal", metrics.OutcomeRejected)
+		return nil, err
+	}
+	metrics.RecordPayment(p.GetName(), "withdrawal", metrics.OutcomeCreated)
+	ctx = logging.WithOrderID(ctx, payoutID)
+	slog.InfoContext(ctx, "withdrawal created", "gateway", reqBody.GatewayName, "user_id", reqBody.UserID)
 
 	// Store Data in Redis
 	data := map[string]interface{}{
@@ -138,34 +359,58 @@ func WithdrawalHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db
 		"gateway_id": reqBody.GatewayID,
 		"country_id": reqBody.CountryID,
 		"orderid":    payoutID,
-		"status":     "created",
 	}
 
+	// A webhook may have stored a later status already
 	key := fmt.Sprintf("withdrawal:userid:%s:orderid:%s", reqBody.UserID, payoutID)
-	err = db.Redis.HSet(r.Context(), key, data)
-	if err != nil {
-		log.Println("Error storing data in redis:", err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	if err := db.Redis.CreateStatus(ctx, key, "created", data); err != nil {
+		return nil, fmt.Errorf("failed to store withdrawal in redis: %v", err)
 	}
+	data["status"] = "created"
+	return data, nil
+}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
-
+// writePSPError answers a failed gateway call. A timeout gets a 504 the caller can
+// retry with the same X-Request-ID, the gateway deduplicates on it; a client that
+// disconnected gets nothing.
+func writePSPError(w http.ResponseWriter, r *http.Request, operation string, err error) {
+	switch {
+	case errors.Is(err, risk.ErrInsufficientFunds):
+		slog.WarnContext(r.Context(), operation+" declined", "error", err)
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.WarnContext(r.Context(), "gateway timed out", "operation", operation, "error", err)
+		http.Error(w, "Gateway Timeout: retry with the same X-Request-ID", http.StatusGatewayTimeout)
+	case errors.Is(err, context.Canceled):
+		slog.InfoContext(r.Context(), "client disconnected during gateway call", "operation", operation, "error", err)
+	default:
+		slog.ErrorContext(r.Context(), "error during "+operation, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
 }
 
 // GetGatewayByCountryHandler retrieves supported gateways for a given country.
 // @Summary Get payment gateways by country
-// @Description Fetches a list of supported payment gateway IDs for a specified country from Redis or DB, sorted by score
+// @Description Fetches a list of supported payment gateway IDs for a specified country from Redis or DB, sorted by their windowed success-rate score. When the optional query parameters are given, routing rules are applied before the score ranking and gateways whose capabilities cannot handle the currency, amount, type or method are dropped.
 // @Tags gateways
 // @Accept json
 // @Produce json
 // @Param countryID path string true "Country ID" example:"3"
+// @Param type query string false "Transaction type (deposit or withdrawal)" example:"deposit"
+// @Param currency query string false "Currency code" example:"INR"
+// @Param amount query int false "Amount in the smallest currency unit" example:"1500000"
+// @Param user_id query string false "User ID" example:"1"
+// @Param method query string false "Payout method (standard or instant)" example:"instant"
 // @Success 200 {object} GatewayResponse "List of gateway IDs for the country"
-// @Failure 400 {object} map[string]string "Bad Request - Missing countryID"
-// @Failure 404 {object} map[string]string "Not Found - No gateways for the country"
+// @Failure 400 {object} map[string]string "Bad Request - Missing countryID or invalid amount"
+// @Failure 404 {object} map[string]string "Not Found - No gateways for the country, paused gateways are left out"
 // @Failure 500 {object} map[string]string "Internal Server Error - Database or Redis failure"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
 // @Router /gateways/{countryID} [get]
-func GetGatewayByCountryHandler(w http.ResponseWriter, r *http.Request, db *db.DB) {
+func GetGatewayByCountryHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB, rules *routing.Engine) {
 	vars := mux.Vars(r)
 	countryID := vars["countryID"]
 
@@ -174,38 +419,226 @@ func GetGatewayByCountryHandler(w http.ResponseWriter, r *http.Request, db *db.D
 		return
 	}
 
-	// Get gateways for the country from Redis Set
-	gatewayIDs, err := db.Redis.GetGatewaysByCountry(r.Context(), countryID)
-	if err != nil || len(gatewayIDs) == 0 {
-		log.Println("Cache miss, fetching from DB")
-
-		// Fetch from DB
-		gatewayIDs, err = db.DB.GetSupportedGatewaysByCountries(countryID)
+	query := r.URL.Query()
+	req := models.RoutingRequest{
+		Type:      query.Get("type"),
+		CountryID: countryID,
+		Currency:  query.Get("currency"),
+		UserID:    query.Get("user_id"),
+		Method:    query.Get("method"),
+	}
+	if identity, ok := auth.FromContext(r.Context()); ok && identity.UserID != "" {
+		req.UserID = identity.UserID
+	}
+	if amount := query.Get("amount"); amount != "" {
+		var err error
+		req.Amount, err = strconv.ParseInt(amount, 10, 64)
 		if err != nil {
-			http.Error(w, "Error fetching gateways", http.StatusInternalServerError)
-			return
-		}
-		if len(gatewayIDs) == 0 {
-			http.Error(w, "No gateways ID present for the country", http.StatusNotFound)
+			http.Error(w, fmt.Sprintf("Bad Request: invalid amount %s", amount), http.StatusBadRequest)
 			return
 		}
+	}
 
-		// Store the gateways in Redis Set
-		err = db.Redis.SaveGatewaysToRedisHashSet(r.Context(), countryID, gatewayIDs)
-		if err != nil {
-			http.Error(w, "Error redis insert gateways", http.StatusInternalServerError)
-			return
-		}
+	gateways, err := getRankedGateways(r, db, countryID)
+	if err != nil {
+		http.Error(w, "Error fetching gateways", http.StatusInternalServerError)
+		return
+	}
+	// Paused gateways stay ranked but are not offered
+	gateways = availableGateways(psp, gateways)
+	if len(gateways) == 0 {
+		http.Error(w, "No gateways ID present for the country", http.StatusNotFound)
+		return
+	}
+
+	decision, err := rules.Evaluate(r.Context(), req, gateways)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error evaluating routing rules", "error", err)
+		http.Error(w, "Error evaluating routing rules", http.StatusInternalServerError)
+		return
 	}
 
-	// Get scores for each gateway and sort by score
-	sortedGateways, err := db.Redis.GetGatewaysByCountry(r.Context(), countryID)
+	// Drop gateways that cannot handle the requested currency, amount, type or method
+	capabilities, err := db.DB.GetGatewayCapabilitiesByCountry(r.Context(), countryID)
 	if err != nil {
-		http.Error(w, "Error fetching gateway scores", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error fetching gateway capabilities", "error", err)
+		http.Error(w, "Error fetching gateway capabilities", http.StatusInternalServerError)
+		return
+	}
+	gateways = filterByCapabilities(decision.Gateways, capabilities, models.CapabilityRequest{
+		Type:     req.Type,
+		Currency: req.Currency,
+		Amount:   req.Amount,
+		Method:   req.Method,
+	})
+	if len(gateways) == 0 {
+		http.Error(w, "No gateways available for the request", http.StatusNotFound)
 		return
 	}
 
 	// Respond with sorted gateways
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(GatewayResponse{CountryID: countryID, Gateways: sortedGateways})
+	json.NewEncoder(w).Encode(GatewayResponse{CountryID: countryID, Gateways: gateways})
+}
+
+// resolveUserID binds the request to the authenticated caller. End users may only act
+// on their own account; internal callers name the user in the request body.
+func resolveUserID(w http.ResponseWriter, r *http.Request, userID *string) bool {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if identity.UserID == "" {
+		return true
+	}
+	if *userID != "" && *userID != identity.UserID {
+		slog.WarnContext(r.Context(), "user attempted to act as another user", "user_id", identity.UserID, "target_user_id", *userID)
+		http.Error(w, "Forbidden: user_id does not match the authenticated user", http.StatusForbidden)
+		return false
+	}
+	*userID = identity.UserID
+	return true
+}
+
+// getRankedGateways returns the score-ranked gateways of a country, filling the
+// Redis ranking from the DB on a cache miss.
+func getRankedGateways(r *http.Request, db *db.DB, countryID string) ([]models.Gateway, error) {
+	// Get the ranked gateways for the country from Redis
+	gateways, err := db.Redis.GetGatewaysByCountry(r.Context(), countryID)
+	if err == nil && len(gateways) > 0 {
+		return gateways, nil
+	}
+	slog.DebugContext(r.Context(), "gateway ranking cache miss, fetching from DB", "country_id", countryID)
+
+	// Fetch from DB
+	gateways, err = db.DB.GetSupportedGatewaysByCountries(r.Context(), countryID)
+	if err != nil || len(gateways) == 0 {
+		return gateways, err
+	}
+
+	// Store the gateways in the Redis ranking and read back the scored ranking
+	if err := db.Redis.SaveGatewaysByCountry(r.Context(), countryID, gateways); err != nil {
+		return nil, err
+	}
+	return db.Redis.GetGatewaysByCountry(r.Context(), countryID)
+}
+
+// filterByCapabilities keeps the gateways whose catalogue supports the request and
+// attaches their capabilities for the response.
+func filterByCapabilities(gateways []models.Gateway, capabilities []models.GatewayCapability, req models.CapabilityRequest) []models.Gateway {
+	var result []models.Gateway
+	for _, gateway := range gateways {
+		gatewayCaps := gatewayCapabilities(capabilities, gateway.ID)
+		if err := models.CheckCapabilities(gatewayCaps, req); err != nil {
+			continue
+		}
+		gateway.Capabilities = gatewayCaps
+		result = append(result, gateway)
+	}
+	return result
+}
+
+// checkRouting writes a 400 response and returns false when the routing rules do not
+// allow the gateway for the request.
+func checkRouting(w http.ResponseWriter, r *http.Request, rules *routing.Engine, req models.RoutingRequest, gatewayID string) bool {
+	allowed, rule, err := rules.Allows(r.Context(), req, gatewayID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error evaluating routing rules", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if !allowed {
+		slog.InfoContext(r.Context(), "gateway rejected by routing rule", "gateway_id", gatewayID, "rule", rule.Name)
+		http.Error(w, fmt.Sprintf("Bad Request: gateway %s not allowed by routing rule %q", gatewayID, rule.Name), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// checkGateway returns the gateway of the ID, or writes a 400 and returns false unless
+// the gateway name is the one of the ID and the gateway is enabled, both globally and
+// in the country. The routing rules and capabilities are keyed by the ID while the
+// payment goes to the gateway of the name, so the two must agree.
+func checkGateway(w http.ResponseWriter, r *http.Request, db *db.DB, gatewayID, gatewayName, countryID string) (store.Gateway, bool) {
+	id, err := strconv.Atoi(gatewayID)
+	if err != nil {
+		http.Error(w, "Bad Request: gateway_id must be an integer", http.StatusBadRequest)
+		return store.Gateway{}, false
+	}
+	country, err := strconv.Atoi(countryID)
+	if err != nil {
+		http.Error(w, "Bad Request: country_id must be an integer", http.StatusBadRequest)
+		return store.Gateway{}, false
+	}
+	gateway, err := db.DB.GetGateway(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("Bad Request: unknown gateway_id %s", gatewayID), http.StatusBadRequest)
+		return store.Gateway{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching gateway", "gateway_id", gatewayID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return store.Gateway{}, false
+	}
+	if gateway.Name != gatewayName {
+		slog.WarnContext(r.Context(), "gateway name does not match the gateway ID", "gateway_id", gatewayID, "gateway", gatewayName)
+		http.Error(w, fmt.Sprintf("Bad Request: gateway_name %s is not gateway %s", gatewayName, gatewayID), http.StatusBadRequest)
+		return store.Gateway{}, false
+	}
+	if !gateway.Enabled {
+		slog.WarnContext(r.Context(), "gateway disabled", "gateway_id", gatewayID)
+		http.Error(w, fmt.Sprintf("Bad Request: gateway %s is disabled", gatewayName), http.StatusBadRequest)
+		return store.Gateway{}, false
+	}
+	mapping, err := db.DB.GetGatewayCountry(r.Context(), id, country)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("Bad Request: gateway %s is not available in country %s", gatewayName, countryID), http.StatusBadRequest)
+		return store.Gateway{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching gateway country", "gateway_id", gatewayID, "country_id", countryID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return store.Gateway{}, false
+	}
+	if !mapping.Enabled {
+		slog.WarnContext(r.Context(), "gateway disabled in the country", "gateway_id", gatewayID, "country_id", countryID)
+		http.Error(w, fmt.Sprintf("Bad Request: gateway %s is disabled in country %s", gatewayName, countryID), http.StatusBadRequest)
+		return store.Gateway{}, false
+	}
+	return gateway, true
+}
+
+// screenRisk runs the risk rules and writes the response when the transaction does not
+// go ahead: 403 when denied, 202 with the review ID when parked for manual approval.
+// Parked withdrawals hold their funds until the review is decided.
+func screenRisk(w http.ResponseWriter, r *http.Request, risks *risk.Engine, reviews *risk.Queue, req models.RiskRequest, payload interface{}) bool {
+	assessment := risks.Assess(r.Context(), req)
+	switch assessment.Decision {
+	case models.RiskDeny:
+		slog.WarnContext(r.Context(), "transaction denied by risk rules", "type", req.Type, "user_id", req.UserID, "signals", assessment.Signals)
+		http.Error(w, "Forbidden: declined by risk checks", http.StatusForbidden)
+		return false
+	case models.RiskReview:
+		review, err := reviews.Park(r.Context(), req, assessment, payload, subject(r))
+		if errors.Is(err, risk.ErrInsufficientFunds) {
+			http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+			return false
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error parking transaction for review", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return false
+		}
+		slog.InfoContext(r.Context(), "transaction parked for review", "type", req.Type, "user_id", req.UserID, "review_id", review.ID, "signals", assessment.Signals)
+		writeData(w, http.StatusAccepted, map[string]interface{}{"review_id": review.ID, "status": "pending_approval"})
+		return false
+	}
+	return true
+}
+
+// subject names the authenticated caller
+func subject(r *http.Request) string {
+	identity, _ := auth.FromContext(r.Context())
+	return identity.Subject
 }
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"payment-gateway/internal/health"
+)
+
+// LivenessHandler reports that the process is up.
+// @Summary Liveness probe
+// @Description Answers 200 while the process serves HTTP. It does not check dependencies, a failing dependency should not restart the service.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string "Process is alive"
+// @Router /healthz [get]
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": health.StatusOK})
+}
+
+// ReadinessHandler reports whether the service can take traffic.
+// @Summary Readiness probe
+// @Description Checks Postgres, Redis, the Kafka brokers, the webhook consumers and the circuit breakers and returns a per-dependency report. Answers 503 while a critical dependency is down or the consumers are not subscribed yet. An open circuit breaker reports degraded without failing readiness.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report "Ready, status ok or degraded"
+// @Failure 503 {object} health.Report "A critical dependency is unavailable"
+// @Router /readyz [get]
+func ReadinessHandler(w http.ResponseWriter, r *http.Request, checker *health.Checker) {
+	report := checker.Report(r.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		slog.WarnContext(r.Context(), "readiness check failed", "checks", report.Checks)
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
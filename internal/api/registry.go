@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"payment-gateway/db"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ListGatewayStatesHandler lists the registered gateways with their state.
+// @Summary List registered gateways
+// @Description Returns every gateway registered with this instance and whether it is enabled or paused
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Gateway states"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /admin/registry [get]
+func ListGatewayStatesHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP) {
+	writeData(w, http.StatusOK, psp.List())
+}
+
+// SetGatewayStateHandler pauses or enables a gateway by name.
+// @Summary Pause or enable a gateway
+// @Description Sets the gateway's enabled flag, the one of /admin/gateways/{gatewayID}/status. A paused gateway is dropped from /gateways/{countryID} and refuses new deposits and withdrawals, its webhooks are still handled. The other instances pick the state up from the DB.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Gateway name" example:"STRIPE"
+// @Param state body models.GatewayStateRequest true "Status and reason"
+// @Success 200 {object} map[string]interface{} "Updated state"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Gateway not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/registry/{name} [put]
+func SetGatewayStateHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB) {
+	name := mux.Vars(r)["name"]
+	var reqBody models.GatewayStateRequest
+	if !decodeAdminRequest(w, r, &reqBody) {
+		return
+	}
+
+	state := models.GatewayState{
+		Name:      name,
+		Status:    reqBody.Status,
+		Reason:    reqBody.Reason,
+		UpdatedBy: subject(r),
+		UpdatedAt: time.Now().UTC(),
+	}
+	gatewayID, err := db.Admin.SetGatewayState(r.Context(), state)
+	if err != nil {
+		writeAdminError(w, r, err, "error saving gateway state")
+		return
+	}
+	psp.SetState(state)
+
+	if err := invalidateGatewayCountries(r, db, gatewayID); err != nil {
+		slog.ErrorContext(r.Context(), "error invalidating gateway cache", "error", err)
+	}
+	slog.WarnContext(r.Context(), "gateway state changed", "gateway", name, "status", state.Status, "reason", state.Reason, "updated_by", state.UpdatedBy)
+	writeData(w, http.StatusOK, state)
+}
+
+// writeGatewayError answers 503 for a paused gateway and status otherwise
+func writeGatewayError(w http.ResponseWriter, err error, status int) {
+	if errors.Is(err, psp.ErrPaused) {
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, fmt.Sprintf("%s: %s", http.StatusText(status), err.Error()), status)
+}
+
+// availableGateways drops the gateways that are not registered or are paused
+func availableGateways(registry *psp.PSP, gateways []models.Gateway) []models.Gateway {
+	var result []models.Gateway
+	for _, gateway := range gateways {
+		if _, err := registry.Available(gateway.Name); err == nil {
+			result = append(result, gateway)
+		}
+	}
+	return result
+}
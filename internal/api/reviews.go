@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"payment-gateway/db"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp"
+	"payment-gateway/internal/risk"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// GetReviewHandler returns the status of a transaction parked for manual review.
+// @Summary Get a parked transaction
+// @Description Returns the status of a deposit or withdrawal the risk checks parked for manual review, and the gateway response once approved
+// @Tags reviews
+// @Produce json
+// @Param reviewID path int true "Review ID"
+// @Param user_id query string false "User ID, required for internal callers" example:"1"
+// @Success 200 {object} map[string]interface{} "Review status"
+// @Failure 400 {object} map[string]string "Invalid review ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
+// @Router /reviews/{reviewID} [get]
+func GetReviewHandler(w http.ResponseWriter, r *http.Request, reviews *risk.Queue) {
+	id, ok := reviewID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	if !resolveUserID(w, r, &userID) {
+		return
+	}
+
+	review, err := reviews.Get(r.Context(), userID, id)
+	if err != nil {
+		writeReviewError(w, r, err)
+		return
+	}
+	// The signals stay internal so the rules cannot be probed
+	writeData(w, http.StatusOK, map[string]interface{}{
+		"id":         review.ID,
+		"type":       review.Type,
+		"status":     review.Status,
+		"result":     review.Result,
+		"created_at": review.CreatedAt,
+		"decided_at": review.DecidedAt,
+	})
+}
+
+// ListReviewsHandler lists the manual review queue.
+// @Summary List parked transactions
+// @Description Returns the transactions parked by the risk checks with the signals that flagged them, oldest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "pending, processing, approved, rejected or failed, every status when empty" example:"pending"
+// @Param limit query int false "Maximum number of reviews, default 100" example:"50"
+// @Success 200 {object} map[string]interface{} "Reviews"
+// @Failure 400 {object} map[string]string "Invalid limit"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/reviews [get]
+func ListReviewsHandler(w http.ResponseWriter, r *http.Request, reviews *risk.Queue) {
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			http.Error(w, "Bad Request: invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	list, err := reviews.List(r.Context(), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching reviews", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, list)
+}
+
+// ApproveReviewHandler approves a parked transaction and sends it to its gateway.
+// @Summary Approve a parked transaction
+// @Description Approves a pending review and replays the parked deposit or withdrawal against its gateway. The caller that made the request cannot approve it. Held funds stay held through the gateway call and the review ends approved, or failed when the gateway call fails. A failed call releases the funds, an approved withdrawal keeps them held until its payout is paid, fails or is canceled.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param reviewID path int true "Review ID"
+// @Param request body models.ReviewDecisionRequest false "Optional note"
+// @Success 200 {object} map[string]interface{} "Decided review"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "The requester cannot approve their own request"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 409 {object} map[string]string "Review already decided"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/reviews/{reviewID}/approve [post]
+func ApproveReviewHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB, reviews *risk.Queue) {
+	id, ok := reviewID(w, r)
+	if !ok {
+		return
+	}
+	reqBody, ok := decodeReviewDecision(w, r)
+	if !ok {
+		return
+	}
+
+	review, payload, err := reviews.Claim(r.Context(), id, subject(r), reqBody.Reason)
+	if err != nil {
+		writeReviewError(w, r, err)
+		return
+	}
+
+	// Replay the parked request, the checks it passed before parking are not repeated.
+	// The review is claimed, so the replay outlives a reviewer that disconnects.
+	ctx := context.WithoutCancel(r.Context())
+	var result map[string]interface{}
+	switch review.Type {
+	case "deposit":
+		var deposit models.DepositRequest
+		if err = json.Unmarshal(payload, &deposit); err == nil {
+			result, err = createDeposit(ctx, psp, db, deposit)
+		}
+	case "withdrawal":
+		var withdrawal models.CustomWithdrawalRequest
+		if err = json.Unmarshal(payload, &withdrawal); err == nil {
+			// The gateway pays out of the funds held for the review
+			withdrawal.HoldID = review.HoldID
+			result, err = createWithdrawal(ctx, psp, db, withdrawal)
+		}
+	default:
+		err = fmt.Errorf("unknown transaction type %s", review.Type)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error sending approved review to the gateway", "review_id", id, "error", err)
+	}
+
+	if err := reviews.Complete(ctx, review, result, err); err != nil {
+		writeReviewError(w, r, err)
+		return
+	}
+	decided, err := reviews.Get(ctx, "", id)
+	if err != nil {
+		writeReviewError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, decided)
+}
+
+// RejectReviewHandler rejects a parked transaction.
+// @Summary Reject a parked transaction
+// @Description Rejects a pending review with a reason and releases its held funds. The parked transaction never reaches a gateway.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param reviewID path int true "Review ID"
+// @Param request body models.ReviewDecisionRequest true "Reason"
+// @Success 200 {object} map[string]interface{} "Decided review"
+// @Failure 400 {object} map[string]string "Invalid request payload or missing reason"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 409 {object} map[string]string "Review already decided"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/reviews/{reviewID}/reject [post]
+func RejectReviewHandler(w http.ResponseWriter, r *http.Request, reviews *risk.Queue) {
+	id, ok := reviewID(w, r)
+	if !ok {
+		return
+	}
+	reqBody, ok := decodeReviewDecision(w, r)
+	if !ok {
+		return
+	}
+
+	if err := reviews.Reject(r.Context(), id, subject(r), reqBody.Reason); err != nil {
+		writeReviewError(w, r, err)
+		return
+	}
+	decided, err := reviews.Get(r.Context(), "", id)
+	if err != nil {
+		writeReviewError(w, r, err)
+		return
+	}
+	writeData(w, http.StatusOK, decided)
+}
+
+func reviewID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["reviewID"], 10, 64)
+	if err != nil {
+		http.Error(w, "Bad Request: invalid reviewID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeReviewDecision decodes an optional decision body, answering 400 on failure
+func decodeReviewDecision(w http.ResponseWriter, r *http.Request) (models.ReviewDecisionRequest, bool) {
+	var reqBody models.ReviewDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && err != io.EOF {
+		slog.WarnContext(r.Context(), "invalid request body", "error", err)
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return reqBody, false
+	}
+	if err := models.ValidateReviewDecisionRequest(reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return reqBody, false
+	}
+	return reqBody, true
+}
+
+// writeReviewError maps review queue errors to responses
+func writeReviewError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, risk.ErrReviewNotFound):
+		http.Error(w, "Not Found", http.StatusNotFound)
+	case errors.Is(err, risk.ErrReviewDecided):
+		http.Error(w, "Conflict: review has already been decided", http.StatusConflict)
+	case errors.Is(err, risk.ErrSelfApproval):
+		http.Error(w, fmt.Sprintf("Forbidden: %s", err.Error()), http.StatusForbidden)
+	case errors.Is(err, risk.ErrReasonRequired):
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+	default:
+		slog.ErrorContext(r.Context(), "error updating review", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
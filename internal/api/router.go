@@ -3,55 +3,195 @@ package api
 import (
 	"net/http"
 	"payment-gateway/db"
+	"payment-gateway/internal/auth"
+	"payment-gateway/internal/beneficiary"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/health"
+	"payment-gateway/internal/logging"
+	"payment-gateway/internal/metrics"
 	"payment-gateway/internal/psp"
+	"payment-gateway/internal/ratelimit"
+	"payment-gateway/internal/risk"
+	"payment-gateway/internal/routing"
+	"payment-gateway/internal/tracing"
+	"payment-gateway/internal/vault"
 
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func SetupRouter(psp *psp.PSP, db *db.DB) *mux.Router {
+func SetupRouter(psp *psp.PSP, db *db.DB, authn *auth.Authenticator, limiter *ratelimit.Limiter, tokens *vault.Vault, beneficiaries *beneficiary.Service, reviews *risk.Queue, checker *health.Checker, cfg *config.Config) *mux.Router {
 	router := mux.NewRouter()
-	router.Use(CORS)
+	router.Use(tracing.Middleware, logging.Middleware, metrics.Middleware, CORS)
+
+	rules := routing.NewEngine(db.DB)
+	risks := risk.NewEngine(risk.DefaultRules(db.Risk, cfg.Risk)...)
+
+	// guard limits the client IP, authenticates the caller and then applies the route's policy
+	guard := func(policy string, scopes ...string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return limiter.Limit(ratelimit.PolicyIP)(authn.Require(scopes...)(limiter.Limit(policy)(next)))
+		}
+	}
+	// Users act on their own account, internal services on behalf of any user
+	userOrInternal := func(policy string) func(http.Handler) http.Handler {
+		return guard(policy, auth.ScopeUser, auth.ScopeInternal)
+	}
+	withdrawalVelocity := limiter.VelocityLimit("withdrawal", ratelimit.Velocity(cfg.RateLimit.WithdrawalVelocity))
 
 	// get-gateway-by-country
-	router.HandleFunc("/gateways/{countryID}", http.HandlerFunc(
+	router.Handle("/gateways/{countryID}", userOrInternal(ratelimit.PolicyRead)(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			GetGatewayByCountryHandler(w, r, db) // Pass the psp instance here
+			GetGatewayByCountryHandler(w, r, psp, db, rules) // Pass the psp instance here
 		},
-	)).Methods("GET", "OPTIONS")
+	))).Methods("GET", "OPTIONS")
 
 	// deposit
-	router.Handle("/deposit", http.HandlerFunc(
+	router.Handle("/deposit", userOrInternal(ratelimit.PolicyDeposit)(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			DepositHandler(w, r, psp, db.Redis) // Pass the psp instance here
+			DepositHandler(w, r, psp, db, rules, risks, reviews) // Pass the psp instance here
 		},
-	)).Methods("POST", "OPTIONS")
+	))).Methods("POST", "OPTIONS")
 
 	// withdrawal
-	router.Handle("/withdrawal", http.HandlerFunc(
+	router.Handle("/withdrawal", userOrInternal(ratelimit.PolicyWithdrawal)(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			WithdrawalHandler(w, r, psp, db) // Pass the psp instance here
+			WithdrawalHandler(w, r, psp, db, rules, tokens, beneficiaries, risks, reviews, withdrawalVelocity) // Pass the psp instance here
 		},
-	)).Methods("POST", "OPTIONS")
+	))).Methods("POST", "OPTIONS")
 
-	// webhook
-	router.Handle("/webhook", http.HandlerFunc(WebhookHandler)).Methods("POST", "OPTIONS")
+	// bank details vault
+	router.Handle("/vault/bank-details", userOrInternal(ratelimit.PolicyRead)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			TokenizeBankDetailsHandler(w, r, tokens)
+		},
+	))).Methods("POST", "OPTIONS")
+
+	// beneficiaries
+	router.Handle("/beneficiaries", userOrInternal(ratelimit.PolicyRead)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				ListBeneficiariesHandler(w, r, beneficiaries)
+				return
+			}
+			CreateBeneficiaryHandler(w, r, beneficiaries)
+		},
+	))).Methods("GET", "POST", "OPTIONS")
+	router.Handle("/beneficiaries/{beneficiaryID}", userOrInternal(ratelimit.PolicyRead)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			DeleteBeneficiaryHandler(w, r, beneficiaries)
+		},
+	))).Methods("DELETE", "OPTIONS")
+
+	// transactions parked for manual review
+	router.Handle("/reviews/{reviewID}", userOrInternal(ratelimit.PolicyRead)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			GetReviewHandler(w, r, reviews)
+		},
+	))).Methods("GET", "OPTIONS")
+
+	// routing dry-run
+	router.Handle("/routing/dry-run", guard(ratelimit.PolicyRead, auth.ScopeAdmin, auth.ScopeInternal)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			RoutingDryRunHandler(w, r, db, rules)
+		},
+	))).Methods("POST", "OPTIONS")
+
+	// webhooks are authenticated by the gateway signatures
+	webhookLimit := limiter.Limit(ratelimit.PolicyWebhook)
+	router.Handle("/webhook", webhookLimit(http.HandlerFunc(WebhookHandler))).Methods("POST", "OPTIONS")
 
 	// stripe webhook
-	router.Handle("/webhook/stripe", http.HandlerFunc(
+	router.Handle("/webhook/stripe", webhookLimit(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			StripeWebhookHandler(w, r, psp, db) // Pass the psp instance here
+			StripeWebhookHandler(w, r, psp, db, cfg.Stripe.WebhookSecret) // Pass the psp instance here
 		},
-	)).Methods("POST", "OPTIONS")
+	))).Methods("POST", "OPTIONS")
 
 	// stripe webhook
-	router.Handle("/webhook/default-gateway", http.HandlerFunc(
+	router.Handle("/webhook/default-gateway", webhookLimit(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			DefaultGatewayWebhookHandler(w, r, psp, db, cfg.DefaultGateway.WebhookSecret) // Pass the psp instance here
+		},
+	))).Methods("POST", "OPTIONS")
+
+	// admin
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(limiter.Limit(ratelimit.PolicyIP), authn.Require(auth.ScopeAdmin), limiter.Limit(ratelimit.PolicyAdmin))
+	adminRoutes := []struct {
+		path    string
+		method  string
+		handler adminHandler
+	}{
+		{"/gateways", "GET", ListGatewaysHandler},
+		{"/gateways", "POST", CreateGatewayHandler},
+		{"/gateways/{gatewayID}", "PUT", UpdateGatewayHandler},
+		{"/gateways/{gatewayID}", "DELETE", DeleteGatewayHandler},
+		{"/gateways/{gatewayID}/status", "PUT", SetGatewayStatusHandler},
+		{"/countries", "GET", ListCountriesHandler},
+		{"/countries", "POST", CreateCountryHandler},
+		{"/countries/{countryID}", "PUT", UpdateCountryHandler},
+		{"/countries/{countryID}", "DELETE", DeleteCountryHandler},
+		{"/countries/{countryID}/gateways", "GET", ListCountryGatewaysHandler},
+		{"/countries/{countryID}/gateways/{gatewayID}", "PUT", AddCountryGatewayHandler},
+		{"/countries/{countryID}/gateways/{gatewayID}", "DELETE", RemoveCountryGatewayHandler},
+		{"/countries/{countryID}/gateways/{gatewayID}/status", "PUT", SetCountryGatewayStatusHandler},
+	}
+	for _, route := range adminRoutes {
+		handler := route.handler
+		admin.Handle(route.path, http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				handler(w, r, db)
+			},
+		)).Methods(route.method, "OPTIONS")
+	}
+
+	admin.Handle("/beneficiaries/{beneficiaryID}/verification", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			VerifyBeneficiaryHandler(w, r, beneficiaries)
+		},
+	)).Methods("PUT", "OPTIONS")
+	admin.Handle("/reviews", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ListReviewsHandler(w, r, reviews)
+		},
+	)).Methods("GET", "OPTIONS")
+	admin.Handle("/reviews/{reviewID}/approve", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ApproveReviewHandler(w, r, psp, db, reviews)
+		},
+	)).Methods("POST", "OPTIONS")
+	admin.Handle("/reviews/{reviewID}/reject", http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			DefaultGatewayWebhookHandler(w, r, psp, db) // Pass the psp instance here
+			RejectReviewHandler(w, r, reviews)
+		},
+	)).Methods("POST", "OPTIONS")
+	admin.Handle("/registry", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ListGatewayStatesHandler(w, r, psp)
+		},
+	)).Methods("GET", "OPTIONS")
+	admin.Handle("/registry/{name}", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			SetGatewayStateHandler(w, r, psp, db)
+		},
+	)).Methods("PUT", "OPTIONS")
+	admin.Handle("/vault/rotate", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			RotateVaultKeysHandler(w, r, tokens)
 		},
 	)).Methods("POST", "OPTIONS")
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
+	// Prometheus scrape endpoint
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// probes
+	router.HandleFunc("/healthz", LivenessHandler).Methods("GET")
+	router.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ReadinessHandler(w, r, checker)
+	}).Methods("GET")
+
 	return router
 }
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"payment-gateway/db"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/routing"
+)
+
+// RoutingDryRunHandler explains how a hypothetical request would be routed.
+// @Summary Dry-run the gateway routing rules
+// @Description Evaluates the routing rules in priority order against a hypothetical deposit or withdrawal and returns the resulting gateways, the matched rule and a trace of every rule evaluated
+// @Tags routing
+// @Accept json
+// @Produce json
+// @Param request body models.RoutingRequest true "Hypothetical routing request"
+// @Success 200 {object} models.RoutingDecision "Routing decision with explanation"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
+// @Router /routing/dry-run [post]
+func RoutingDryRunHandler(w http.ResponseWriter, r *http.Request, db *db.DB, rules *routing.Engine) {
+	var reqBody models.RoutingRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		slog.WarnContext(r.Context(), "invalid request body", "error", err)
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.ValidateRoutingRequest(reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	gateways, err := getRankedGateways(r, db, reqBody.CountryID)
+	if err != nil {
+		http.Error(w, "Error fetching gateways", http.StatusInternalServerError)
+		return
+	}
+
+	decision, err := rules.Evaluate(r.Context(), reqBody, gateways)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error evaluating routing rules", "error", err)
+		http.Error(w, "Error evaluating routing rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decision)
+}
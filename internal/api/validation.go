@@ -2,12 +2,15 @@ package api
 
 import (
 	"fmt"
+	store "payment-gateway/db/db"
 	"payment-gateway/internal/models"
 	"payment-gateway/internal/psp"
+	"strconv"
 )
 
-// validateDepositRequest validates the deposit request body.
-func validateDepositRequest(req models.DepositRequest, psp *psp.PSP) error {
+// validateDepositRequest validates the deposit request body against the capabilities
+// of gateway, the one called for the deposit, in the requested country.
+func validateDepositRequest(req models.DepositRequest, gateway store.Gateway, psp *psp.PSP, capabilities []models.GatewayCapability) error {
 	if req.Amount == "" {
 		return fmt.Errorf("amount is required")
 	}
@@ -26,15 +29,44 @@ func validateDepositRequest(req models.DepositRequest, psp *psp.PSP) error {
 	if req.CountryID == "" {
 		return fmt.Errorf("country_id is required")
 	}
-	if _, err := psp.Get(req.GatewayName); err != nil {
+	if _, err := psp.Available(gateway.Name); err != nil {
 		return err
 	}
-	// Add more validation rules here as needed (e.g., currency format, amount range)
+	amount, err := strconv.ParseInt(req.Amount, 10, 64)
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("amount must be a positive integer in the smallest currency unit")
+	}
+	if err := models.CheckCapabilities(gatewayCapabilities(capabilities, strconv.Itoa(gateway.ID)), models.CapabilityRequest{
+		Type:     "deposit",
+		Currency: req.Currency,
+		Amount:   amount,
+	}); err != nil {
+		return fmt.Errorf("gateway %s: %v", gateway.Name, err)
+	}
 	return nil
 }
 
-// validateDepositRequest validates the deposit request body.
-func validateWithdrawalRequest(req models.CustomWithdrawalRequest, psp *psp.PSP) error {
-	// Add more validation rules here as needed (e.g., currency format, amount range)
+// validateWithdrawalRequest validates the withdrawal request body against the capabilities
+// of gateway, the one called for the payout, in the requested country.
+func validateWithdrawalRequest(req models.CustomWithdrawalRequest, gateway store.Gateway, psp *psp.PSP, capabilities []models.GatewayCapability) error {
+	if err := models.CheckCapabilities(gatewayCapabilities(capabilities, strconv.Itoa(gateway.ID)), models.CapabilityRequest{
+		Type:     "withdrawal",
+		Currency: req.Currency,
+		Amount:   req.Amount,
+		Method:   req.Method,
+	}); err != nil {
+		return fmt.Errorf("gateway %s: %v", gateway.Name, err)
+	}
 	return nil
 }
+
+// gatewayCapabilities returns the capabilities belonging to one gateway
+func gatewayCapabilities(capabilities []models.GatewayCapability, gatewayID string) []models.GatewayCapability {
+	var result []models.GatewayCapability
+	for _, capability := range capabilities {
+		if capability.GatewayID == gatewayID {
+			result = append(result, capability)
+		}
+	}
+	return result
+}
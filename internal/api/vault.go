@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"payment-gateway/internal/beneficiary"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/vault"
+	"time"
+)
+
+// TokenizeBankDetailsHandler stores bank details in the vault and returns an opaque token.
+// @Summary Tokenize bank details
+// @Description Encrypts the bank details and stores them in the vault. The returned token can be sent as bank_details_token to /withdrawal instead of the raw details.
+// @Tags vault
+// @Accept json
+// @Produce json
+// @Param request body models.TokenizeBankDetailsRequest true "Bank details"
+// @Success 201 {object} map[string]interface{} "Token"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "user_id does not match the authenticated user"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
+// @Router /vault/bank-details [post]
+func TokenizeBankDetailsHandler(w http.ResponseWriter, r *http.Request, tokens *vault.Vault) {
+	var reqBody models.TokenizeBankDetailsRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		slog.WarnContext(r.Context(), "invalid request body", "error", err)
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if !resolveUserID(w, r, &reqBody.UserID) {
+		return
+	}
+
+	if err := models.ValidateTokenizeBankDetailsRequest(reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	entry, err := tokens.Tokenize(r.Context(), reqBody.UserID, reqBody.BankDetails)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error tokenizing bank details", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusCreated, entry)
+}
+
+// RotateVaultKeysHandler re-wraps every vault entry with the active key-encryption key.
+// @Summary Rotate vault keys
+// @Description Re-wraps the data keys of every vault entry that is not yet under the active key-encryption key. Run it after changing DATA_ENCRYPTION_ACTIVE_KEY, before removing the old key.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Number of entries rewrapped"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/vault/rotate [post]
+func RotateVaultKeysHandler(w http.ResponseWriter, r *http.Request, tokens *vault.Vault) {
+	rotated, err := tokens.RotateKeys(r.Context(), 100)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rotating vault keys", "rotated", rotated, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, map[string]interface{}{"rotated": rotated})
+}
+
+// resolveBankDetails replaces a bank details token or beneficiary reference with
+// the details stored in the vault, and returns when the details were saved. Raw
+// bank details were never saved, they count as added now.
+func resolveBankDetails(w http.ResponseWriter, r *http.Request, tokens *vault.Vault, beneficiaries *beneficiary.Service, req *models.CustomWithdrawalRequest) (*time.Time, bool) {
+	var details models.BankAccountDetails
+	var addedAt time.Time
+	var err error
+	switch {
+	case req.BeneficiaryID != 0:
+		details, addedAt, err = beneficiaries.BankDetails(r.Context(), req.UserID, req.BeneficiaryID, req.Currency)
+	case req.BankDetailsToken != "":
+		var entry models.VaultEntry
+		details, entry, err = tokens.Detokenize(r.Context(), req.UserID, req.BankDetailsToken)
+		addedAt = entry.CreatedAt
+	case req.BankDetails != nil:
+		addedAt = time.Now().UTC()
+		return &addedAt, true
+	default:
+		return nil, true
+	}
+
+	switch {
+	case errors.Is(err, vault.ErrTokenNotFound):
+		http.Error(w, "Bad Request: unknown bank_details_token", http.StatusBadRequest)
+		return nil, false
+	case errors.Is(err, beneficiary.ErrNotFound), errors.Is(err, beneficiary.ErrNotVerified), errors.Is(err, beneficiary.ErrCurrencyMismatch):
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return nil, false
+	case err != nil:
+		slog.ErrorContext(r.Context(), "error reading bank details from the vault", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	req.BankDetails = &details
+	return &addedAt, true
+}
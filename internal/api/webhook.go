@@ -4,19 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"payment-gateway/db"
-	"payment-gateway/internal/models"
 	"payment-gateway/internal/psp"
+	"payment-gateway/internal/psp/defaultgateway"
+	"time"
 
-	"github.com/stripe/stripe-go/webhook"
+	"github.com/stripe/stripe-go/v81/webhook"
 )
 
 // StripeWebhookHandler handles webhook events from Stripe.
 // @Summary Handle Stripe webhook events
-// @Description Processes incoming webhook events from Stripe, verifies the signature, and delegates to the PSP service layer
+// @Description Processes incoming webhook events from Stripe, verifies the signature, and publishes the payment event it reports
 // @Tags webhooks
 // @Accept json
 // @Produce plain
@@ -26,8 +26,9 @@ import (
 // @Failure 400 {object} map[string]string "Bad Request - Payload too large"
 // @Failure 401 {object} map[string]string "Unauthorized - Invalid signature"
 // @Failure 500 {object} map[string]string "Internal Server Error - Processing or module error"
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
 // @Router /webhook/stripe [post]
-func StripeWebhookHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB) {
+func StripeWebhookHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB, webhookSecret string) {
 	const MaxBodyBytes = int64(65536) // Limit request size
 	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
 	payload, err := io.ReadAll(r.Body)
@@ -37,23 +38,22 @@ func StripeWebhookHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP,
 	}
 
 	// Verify Stripe signature
-	event, err := webhook.ConstructEvent(payload, r.Header.Get("Stripe-Signature"), os.Getenv("STRIPE_WEBHOOK_SECRET"))
-	if err != nil {
-		log.Printf("⚠️  Webhook signature verification failed: %v\n", err)
+	if err := webhook.ValidatePayload(payload, r.Header.Get("Stripe-Signature"), webhookSecret); err != nil {
+		slog.WarnContext(r.Context(), "stripe webhook signature verification failed", "error", err)
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
 	p, err := psp.Get("STRIPE")
 	if err != nil {
-		log.Printf("⚠️  Invalid gateway: %v\n", err)
+		slog.ErrorContext(r.Context(), "gateway not registered", "error", err)
 		http.Error(w, "Invalid error", http.StatusInternalServerError)
 		return
 	}
-	// Process event in the service layer
-	err = p.PublishWebhookToKafka(&event)
+	// The gateway publishes the payment event of the payload
+	err = p.PublishWebhook(r.Context(), payload)
 	if err != nil {
-		log.Printf("❌ Error handling event: %v", err)
+		slog.ErrorContext(r.Context(), "error publishing webhook event", "error", err)
 		http.Error(w, "Error processing event", http.StatusInternalServerError)
 		return
 	}
@@ -63,16 +63,19 @@ func StripeWebhookHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP,
 
 // DefaultGatewayWebhookHandler handles webhook events from the default gateway.
 // @Summary Handle default gateway webhook events
-// @Description Processes incoming webhook events from the default gateway, parses the payload, and delegates to the PSP service layer
+// @Description Processes incoming webhook events from the default gateway, verifies the signature with GATEWAY_WEBHOOK_SECRET, and publishes the payment event it reports
 // @Tags webhooks
 // @Accept json
 // @Produce plain
 // @Param payload body models.DefaultGatewayEvent true "Default gateway webhook payload"
+// @Param X-Gateway-Signature header string true "HMAC-SHA256 signature of the payload" example:"t=123456789,v1=abc123..."
 // @Success 200 {string} string "Webhook processed successfully"
 // @Failure 400 {object} map[string]string "Bad Request - Payload too large"
+// @Failure 401 {object} map[string]string "Unauthorized - Invalid signature"
 // @Failure 500 {object} map[string]string "Internal Server Error - Parsing or processing error"
+// @Failure 429 {object} map[string]string "Too many requests, see Retry-After"
 // @Router /webhook/default-gateway  [post]
-func DefaultGatewayWebhookHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB) {
+func DefaultGatewayWebhookHandler(w http.ResponseWriter, r *http.Request, psp *psp.PSP, db *db.DB, webhookSecret string) {
 	const MaxBodyBytes = int64(65536) // Limit request size
 	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
 	payload, err := io.ReadAll(r.Body)
@@ -81,28 +84,24 @@ func DefaultGatewayWebhookHandler(w http.ResponseWriter, r *http.Request, psp *p
 		return
 	}
 
-	// Verify Stripe signature
-	// TODO verify signature for default gateway
-
-	p, err := psp.Get("DEFAULT_GATEWAY")
-	if err != nil {
-		log.Printf("⚠️  Invalid gateway: %v\n", err)
-		http.Error(w, "Invalid error", http.StatusInternalServerError)
+	// Verify the gateway signature, unsigned webhooks are rejected
+	if err := defaultgateway.VerifySignature(payload, r.Header.Get(defaultgateway.SignatureHeader), webhookSecret, time.Now()); err != nil {
+		slog.WarnContext(r.Context(), "default gateway webhook signature verification failed", "error", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
-	event := &models.DefaultGatewayEvent{}
-	err = json.Unmarshal(payload, event)
+	p, err := psp.Get("DEFAULT_GATEWAY")
 	if err != nil {
-		log.Printf("❌ Error parsin event: %v", err)
-		http.Error(w, "Error parsin event", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "gateway not registered", "error", err)
+		http.Error(w, "Invalid error", http.StatusInternalServerError)
 		return
 	}
 
-	// Process event in the service layer
-	err = p.PublishWebhookToKafka(event)
+	// The gateway publishes the payment event of the payload
+	err = p.PublishWebhook(r.Context(), payload)
 	if err != nil {
-		log.Printf("❌ Error handling event: %v", err)
+		slog.ErrorContext(r.Context(), "error publishing webhook event", "error", err)
 		http.Error(w, "Error processing event", http.StatusInternalServerError)
 		return
 	}
@@ -112,7 +111,6 @@ func DefaultGatewayWebhookHandler(w http.ResponseWriter, r *http.Request, psp *p
 
 // WebhookHandler handles webhook events from Razorpay.
 func WebhookHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("webhook initiated")
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -120,26 +118,21 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Println("Error reading webhook body:", err.Error())
+		slog.WarnContext(r.Context(), "error reading razorpay webhook body", "error", err)
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 
-	// Print the payload
-	log.Println("Webhook Payload:")
-	// log.Println(string(body))
-
 	// Optionally, you can unmarshal the JSON payload to a struct
 	var payload map[string]interface{}
 	err = json.Unmarshal(body, &payload)
 	if err != nil {
-		log.Println("Error unmarshalling webhook payload:", err.Error())
+		slog.WarnContext(r.Context(), "invalid razorpay webhook payload", "error", err)
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	// print the payload nicely
-	formattedPayload, _ := json.MarshalIndent(payload, "", "  ")
-	fmt.Println(string(formattedPayload))
+	// Sensitive fields are redacted by the logger
+	slog.DebugContext(r.Context(), "razorpay webhook received", "payload", payload)
 
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, "Webhook received successfully")
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"payment-gateway/internal/config"
+	"strings"
+)
+
+// Authenticator resolves the caller of a request from a JWT bearer token or
+// an HMAC signed API key.
+type Authenticator struct {
+	jwt     *JWTVerifier
+	apiKeys *APIKeyVerifier
+}
+
+// New builds an authenticator. Either verifier may be nil to disable that method.
+func New(jwt *JWTVerifier, apiKeys *APIKeyVerifier) *Authenticator {
+	return &Authenticator{jwt: jwt, apiKeys: apiKeys}
+}
+
+// NewFromConfig builds an authenticator from the JWT key source and the API keys
+// of the configuration, API key signatures are recorded in replays. With neither
+// configured every protected route answers 401.
+func NewFromConfig(cfg config.Auth, replays ReplayStore) (*Authenticator, error) {
+	a := &Authenticator{}
+
+	if cfg.JWT.Secret != "" || cfg.JWT.PublicKey != "" || cfg.JWT.JWKSURL != "" {
+		verifier, err := NewJWTVerifier(JWTConfig(cfg.JWT))
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = verifier
+	}
+
+	if len(cfg.APIKeys) > 0 {
+		keys := make([]APIKey, 0, len(cfg.APIKeys))
+		for _, key := range cfg.APIKeys {
+			// Keys without scopes are granted the internal scope
+			scopes := key.Scopes
+			if len(scopes) == 0 {
+				scopes = []string{ScopeInternal}
+			}
+			keys = append(keys, APIKey{ID: key.ID, Secret: key.Secret, Scopes: scopes})
+		}
+		a.apiKeys = NewAPIKeyVerifier(keys, replays)
+	}
+
+	if a.jwt == nil && a.apiKeys == nil {
+		slog.Warn("no JWT key or API keys configured, authenticated routes will reject every request")
+	}
+	return a, nil
+}
+
+// Authenticate returns the identity of the caller
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	if r.Header.Get(HeaderAPIKey) != "" {
+		if a.apiKeys == nil {
+			return Identity{}, fmt.Errorf("API keys are not enabled")
+		}
+		return a.apiKeys.Verify(r)
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return Identity{}, fmt.Errorf("missing bearer token")
+	}
+	if a.jwt == nil {
+		return Identity{}, fmt.Errorf("bearer tokens are not enabled")
+	}
+	return a.jwt.Verify(strings.TrimPrefix(header, "Bearer "))
+}
+
+// Require returns a middleware that authenticates the caller, rejects callers
+// holding none of the scopes and stores the identity in the request context.
+func (a *Authenticator) Require(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			identity, err := a.Authenticate(r)
+			if err != nil {
+				slog.InfoContext(r.Context(), "authentication failed", "error", err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="payment-gateway"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !identity.HasScope(scopes...) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+		})
+	}
+}
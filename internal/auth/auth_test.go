@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func hs256Token(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{Secret: "secret", Issuer: "payments"})
+	assert.NoError(t, err)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		token    string
+		expected Identity
+		wantErr  bool
+	}{
+		{
+			name:     "user token defaults to the user scope",
+			token:    hs256Token(t, "secret", jwt.MapClaims{"sub": "42", "iss": "payments", "exp": exp}),
+			expected: Identity{Subject: "42", UserID: "42", Scopes: []string{ScopeUser}, Method: MethodJWT},
+		},
+		{
+			name:     "admin token does not act as a user",
+			token:    hs256Token(t, "secret", jwt.MapClaims{"sub": "ops", "iss": "payments", "exp": exp, "scope": "admin"}),
+			expected: Identity{Subject: "ops", Scopes: []string{ScopeAdmin}, Method: MethodJWT},
+		},
+		{
+			name:    "wrong secret",
+			token:   hs256Token(t, "other", jwt.MapClaims{"sub": "42", "iss": "payments", "exp": exp}),
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			token:   hs256Token(t, "secret", jwt.MapClaims{"sub": "42", "iss": "someone", "exp": exp}),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   hs256Token(t, "secret", jwt.MapClaims{"sub": "42", "iss": "payments", "exp": time.Now().Add(-time.Hour).Unix()}),
+			wantErr: true,
+		},
+		{
+			name:    "missing expiry",
+			token:   hs256Token(t, "secret", jwt.MapClaims{"sub": "42", "iss": "payments"}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := verifier.Verify(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, identity)
+		})
+	}
+}
+
+func TestJWTVerifier_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer server.Close()
+
+	verifier, err := NewJWTVerifier(JWTConfig{JWKSURL: server.URL})
+	assert.NoError(t, err)
+
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "7", "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		assert.NoError(t, err)
+		return signed
+	}
+
+	identity, err := verifier.Verify(sign("key-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "7", identity.UserID)
+
+	_, err = verifier.Verify(sign("key-2"))
+	assert.Error(t, err)
+
+	// HS256 tokens are refused when RSA keys are configured
+	_, err = verifier.Verify(hs256Token(t, "secret", jwt.MapClaims{"sub": "7", "exp": time.Now().Add(time.Hour).Unix()}))
+	assert.Error(t, err)
+}
+
+// memoryReplays is a ReplayStore shared by the verifiers of a test, the TTL is ignored
+type memoryReplays struct {
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func (m *memoryReplays) UseOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.used[key] {
+		return false, nil
+	}
+	m.used[key] = true
+	return true, nil
+}
+
+func TestAPIKeyVerifier_Verify(t *testing.T) {
+	keys := []APIKey{
+		{ID: "billing", Secret: "s3cret", Scopes: []string{ScopeInternal}},
+		{ID: "ops", Secret: "0ps", Scopes: []string{ScopeAdmin, ScopeInternal}},
+	}
+	// Two instances sharing the used signatures
+	replays := &memoryReplays{used: make(map[string]bool)}
+	verifier, other := NewAPIKeyVerifier(keys, replays), NewAPIKeyVerifier(keys, replays)
+	now := time.Unix(1700000000, 0)
+	verifier.now = func() time.Time { return now }
+	other.now = verifier.now
+
+	body := `{"user_id":"1","amount":"100"}`
+	request := func(keyID, secret string, at time.Time, signedBody string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/deposit", strings.NewReader(body))
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		r.Header.Set(HeaderAPIKey, keyID)
+		r.Header.Set(HeaderTimestamp, timestamp)
+		r.Header.Set(HeaderSignature, Sign(secret, timestamp, http.MethodPost, "/deposit", []byte(signedBody)))
+		return r
+	}
+
+	withQuery := func(r *http.Request, query string) *http.Request {
+		r.URL.RawQuery = query
+		r.RequestURI = r.URL.RequestURI()
+		return r
+	}
+	signedQuery := func(r *http.Request, secret, query, signedBody string) *http.Request {
+		r = withQuery(r, query)
+		r.Header.Set(HeaderSignature, Sign(secret, r.Header.Get(HeaderTimestamp), r.Method, r.URL.RequestURI(), []byte(signedBody)))
+		return r
+	}
+
+	oversized := func(secret string, at time.Time) *http.Request {
+		large := strings.Repeat("a", MaxBodyBytes+1)
+		r := httptest.NewRequest(http.MethodPost, "/deposit", strings.NewReader(large))
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		r.Header.Set(HeaderAPIKey, "billing")
+		r.Header.Set(HeaderTimestamp, timestamp)
+		r.Header.Set(HeaderSignature, Sign(secret, timestamp, http.MethodPost, "/deposit", []byte(large)))
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		verifier *APIKeyVerifier // Instance verifying the request, verifier when nil
+		request  *http.Request
+		expected Identity
+		wantErr  bool
+	}{
+		{
+			name:     "valid signature",
+			request:  request("billing", "s3cret", now, body),
+			expected: Identity{Subject: "billing", Scopes: []string{ScopeInternal}, Method: MethodAPIKey},
+		},
+		{
+			name:     "explicit scopes",
+			request:  request("ops", "0ps", now, body),
+			expected: Identity{Subject: "ops", Scopes: []string{ScopeAdmin, ScopeInternal}, Method: MethodAPIKey},
+		},
+		{name: "unknown key", request: request("nobody", "s3cret", now, body), wantErr: true},
+		{name: "wrong secret", request: request("billing", "other", now, body), wantErr: true},
+		{name: "tampered body", request: request("billing", "s3cret", now, `{"user_id":"2"}`), wantErr: true},
+		{name: "stale timestamp", request: request("billing", "s3cret", now.Add(-10*time.Minute), body), wantErr: true},
+		{name: "replayed signature", request: request("billing", "s3cret", now, body), wantErr: true},
+		{name: "replayed on another instance", verifier: other, request: request("ops", "0ps", now, body), wantErr: true},
+		{name: "oversized body", request: oversized("s3cret", now.Add(2*time.Second)), wantErr: true},
+		{name: "tampered query", request: withQuery(request("ops", "0ps", now.Add(time.Second), body), "user_id=2"), wantErr: true},
+		{
+			name:     "signed query",
+			request:  signedQuery(request("billing", "s3cret", now.Add(time.Second), body), "s3cret", "user_id=1", body),
+			expected: Identity{Subject: "billing", Scopes: []string{ScopeInternal}, Method: MethodAPIKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.verifier == nil {
+				tt.verifier = verifier
+			}
+			identity, err := tt.verifier.Verify(tt.request)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, identity)
+
+			// The body is still readable by the handler
+			var decoded map[string]string
+			assert.NoError(t, json.NewDecoder(tt.request.Body).Decode(&decoded))
+			assert.Equal(t, "1", decoded["user_id"])
+		})
+	}
+}
+
+func TestAuthenticator_Require(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{Secret: "secret"})
+	assert.NoError(t, err)
+	authn := New(verifier, nil)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	var seen Identity
+	handler := authn.Require(ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = FromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "missing token", header: "", status: http.StatusUnauthorized},
+		{name: "user scope", header: "Bearer " + hs256Token(t, "secret", jwt.MapClaims{"sub": "42", "exp": exp}), status: http.StatusForbidden},
+		{name: "admin scope", header: "Bearer " + hs256Token(t, "secret", jwt.MapClaims{"sub": "ops", "exp": exp, "scopes": []string{"admin"}}), status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/gateways", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+	assert.Equal(t, "ops", seen.Subject)
+}
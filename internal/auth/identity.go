@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+)
+
+// Scopes separating the three kinds of callers
+const (
+	ScopeUser     = "user"     // End users acting on their own account
+	ScopeAdmin    = "admin"    // Operators managing gateways, countries and rules
+	ScopeInternal = "internal" // Trusted services acting on behalf of any user
+)
+
+// Authentication methods
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	Subject string   // JWT subject or API key ID
+	UserID  string   // User the caller acts as, empty for service callers
+	Scopes  []string // Granted scopes
+	Method  string   // jwt or api_key
+}
+
+// HasScope reports whether the identity was granted any of the scopes
+func (i Identity) HasScope(scopes ...string) bool {
+	for _, granted := range i.Scopes {
+		for _, scope := range scopes {
+			if granted == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity stored by the auth middleware
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok
+}
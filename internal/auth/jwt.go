@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig selects how bearer tokens are verified. Exactly one of Secret,
+// PublicKey or JWKSURL is expected; the first one set wins.
+type JWTConfig struct {
+	Secret    string        // Shared HS256 secret
+	PublicKey string        // PEM encoded RSA public key
+	JWKSURL   string        // URL of a JSON Web Key Set holding RSA keys
+	JWKSTTL   time.Duration // How long fetched keys are cached
+	Issuer    string        // Expected "iss" claim, not checked when empty
+	Audience  string        // Expected "aud" claim, not checked when empty
+}
+
+// JWTVerifier validates bearer tokens and turns their claims into an identity
+type JWTVerifier struct {
+	cfg     JWTConfig
+	keyfunc jwt.Keyfunc
+	methods []string
+}
+
+// NewJWTVerifier builds a verifier for the configured key source
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	v := &JWTVerifier{cfg: cfg}
+	switch {
+	case cfg.Secret != "":
+		secret := []byte(cfg.Secret)
+		v.methods = []string{jwt.SigningMethodHS256.Alg()}
+		v.keyfunc = func(*jwt.Token) (interface{}, error) { return secret, nil }
+	case cfg.PublicKey != "":
+		key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(cfg.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT public key: %v", err)
+		}
+		v.methods = []string{jwt.SigningMethodRS256.Alg()}
+		v.keyfunc = func(*jwt.Token) (interface{}, error) { return key, nil }
+	case cfg.JWKSURL != "":
+		jwks := &jwksCache{url: cfg.JWKSURL, ttl: cfg.JWKSTTL, client: &http.Client{Timeout: 5 * time.Second}}
+		v.methods = []string{jwt.SigningMethodRS256.Alg()}
+		v.keyfunc = jwks.keyfunc
+	default:
+		return nil, fmt.Errorf("no JWT key configured")
+	}
+	return v, nil
+}
+
+// Verify parses and validates a bearer token
+func (v *JWTVerifier) Verify(token string) (Identity, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(v.methods), jwt.WithExpirationRequired(), jwt.WithLeeway(30 * time.Second)}
+	if v.cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(v.cfg.Issuer))
+	}
+	if v.cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(v.cfg.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, v.keyfunc, options...); err != nil {
+		return Identity{}, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Identity{}, fmt.Errorf("token has no subject")
+	}
+	identity := Identity{Subject: subject, Scopes: tokenScopes(claims), Method: MethodJWT}
+	if len(identity.Scopes) == 0 {
+		identity.Scopes = []string{ScopeUser}
+	}
+	// Only end users act as themselves, services name the user in the request
+	if identity.HasScope(ScopeUser) {
+		identity.UserID = subject
+	}
+	return identity, nil
+}
+
+// tokenScopes reads the space separated "scope" claim or the "scopes" array claim
+func tokenScopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	var scopes []string
+	if list, ok := claims["scopes"].([]interface{}); ok {
+		for _, item := range list {
+			if scope, ok := item.(string); ok {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// jwksCache fetches RSA keys from a JWKS endpoint and refreshes them when
+// they expire or a token names an unknown key ID.
+type jwksCache struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	mu         sync.Mutex
+	keys       map[string]*rsa.PublicKey
+	fetchedAt  time.Time
+	refreshing bool // A fetch is in flight, other callers use the current keys
+}
+
+func (c *jwksCache) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	ttl := c.ttl
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	c.mu.Lock()
+	key, ok := c.keys[kid]
+	fresh := ok && time.Since(c.fetchedAt) < ttl
+	// Refetch at most every few seconds so unknown key IDs cannot hammer the endpoint
+	refresh := !fresh && !c.refreshing && (time.Since(c.fetchedAt) > 10*time.Second || c.keys == nil)
+	if refresh {
+		c.refreshing = true
+	}
+	c.mu.Unlock()
+	if fresh {
+		return key, nil
+	}
+
+	if refresh {
+		// Fetch without the lock so a slow endpoint does not block tokens with known keys
+		keys, err := c.fetch()
+		c.mu.Lock()
+		c.refreshing = false
+		if err == nil {
+			c.keys = keys
+			c.fetchedAt = time.Now()
+		}
+		c.mu.Unlock()
+		if err != nil {
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// fetch downloads the RSA keys of the JWKS endpoint by key ID
+func (c *jwksCache) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
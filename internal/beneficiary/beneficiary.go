@@ -0,0 +1,113 @@
+package beneficiary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/vault"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for unknown beneficiaries and beneficiaries of another user
+	ErrNotFound = errors.New("beneficiary not found")
+	// ErrNotVerified is returned when a withdrawal references an unverified beneficiary
+	ErrNotVerified = errors.New("beneficiary is not verified")
+	// ErrCurrencyMismatch is returned when the withdrawal currency differs from the beneficiary's
+	ErrCurrencyMismatch = errors.New("beneficiary currency does not match the withdrawal currency")
+)
+
+// Store persists beneficiaries
+type Store interface {
+	CreateBeneficiary(ctx context.Context, beneficiary models.Beneficiary) (models.Beneficiary, error)
+	GetBeneficiary(ctx context.Context, id int64) (models.Beneficiary, error) // Returns ErrNotFound when missing or deleted
+	GetBeneficiariesByUser(ctx context.Context, userID string) ([]models.Beneficiary, error)
+	DeleteBeneficiary(ctx context.Context, id int64) error
+	SetBeneficiaryStatus(ctx context.Context, id int64, status, reason string) error
+}
+
+// Service manages the saved payout bank accounts of users. Bank details are
+// kept in the vault and beneficiaries only reference their token.
+type Service struct {
+	store Store
+	vault *vault.Vault
+}
+
+// New creates a beneficiary service
+func New(store Store, vault *vault.Vault) *Service {
+	return &Service{store: store, vault: vault}
+}
+
+// Create tokenizes the bank details and saves them as a pending beneficiary
+func (s *Service) Create(ctx context.Context, req models.BeneficiaryRequest) (models.Beneficiary, error) {
+	entry, err := s.vault.Tokenize(ctx, req.UserID, req.BankDetails)
+	if err != nil {
+		return models.Beneficiary{}, err
+	}
+
+	return s.store.CreateBeneficiary(ctx, models.Beneficiary{
+		UserID:            req.UserID,
+		Nickname:          req.Nickname,
+		Country:           strings.ToUpper(req.BankDetails.Country),
+		Currency:          strings.ToLower(req.BankDetails.Currency),
+		AccountHolderType: req.BankDetails.AccountHolderType,
+		Last4:             entry.Last4,
+		BankDetailsToken:  entry.Token,
+		Status:            models.BeneficiaryPending,
+	})
+}
+
+// List returns the beneficiaries of a user
+func (s *Service) List(ctx context.Context, userID string) ([]models.Beneficiary, error) {
+	return s.store.GetBeneficiariesByUser(ctx, userID)
+}
+
+// Get returns a beneficiary owned by the user
+func (s *Service) Get(ctx context.Context, userID string, id int64) (models.Beneficiary, error) {
+	beneficiary, err := s.store.GetBeneficiary(ctx, id)
+	if err != nil {
+		return models.Beneficiary{}, err
+	}
+	if beneficiary.UserID != userID {
+		return models.Beneficiary{}, ErrNotFound
+	}
+	return beneficiary, nil
+}
+
+// Delete removes a beneficiary owned by the user
+func (s *Service) Delete(ctx context.Context, userID string, id int64) error {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.store.DeleteBeneficiary(ctx, id)
+}
+
+// SetStatus records the outcome of a verification
+func (s *Service) SetStatus(ctx context.Context, id int64, status, reason string) error {
+	if status != models.BeneficiaryVerified && status != models.BeneficiaryRejected {
+		return fmt.Errorf("invalid beneficiary status %s", status)
+	}
+	return s.store.SetBeneficiaryStatus(ctx, id, status, reason)
+}
+
+// BankDetails returns the bank details of a verified beneficiary for a withdrawal in
+// the currency, and when the beneficiary was added
+func (s *Service) BankDetails(ctx context.Context, userID string, id int64, currency string) (models.BankAccountDetails, time.Time, error) {
+	beneficiary, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return models.BankAccountDetails{}, time.Time{}, err
+	}
+	if beneficiary.Status != models.BeneficiaryVerified {
+		return models.BankAccountDetails{}, time.Time{}, ErrNotVerified
+	}
+	if !strings.EqualFold(beneficiary.Currency, currency) {
+		return models.BankAccountDetails{}, time.Time{}, ErrCurrencyMismatch
+	}
+	details, _, err := s.vault.Detokenize(ctx, userID, beneficiary.BankDetailsToken)
+	if err != nil {
+		return models.BankAccountDetails{}, time.Time{}, err
+	}
+	return details, beneficiary.CreatedAt, nil
+}
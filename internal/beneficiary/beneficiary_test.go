@@ -0,0 +1,129 @@
+package beneficiary
+
+import (
+	"bytes"
+	"context"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/services"
+	"payment-gateway/internal/vault"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore keeps beneficiaries and vault entries in maps
+type memoryStore struct {
+	beneficiaries map[int64]models.Beneficiary
+	entries       map[string]models.VaultEntry
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{beneficiaries: map[int64]models.Beneficiary{}, entries: map[string]models.VaultEntry{}}
+}
+
+func (m *memoryStore) CreateBeneficiary(ctx context.Context, b models.Beneficiary) (models.Beneficiary, error) {
+	b.ID = int64(len(m.beneficiaries) + 1)
+	b.CreatedAt = time.Now().UTC()
+	m.beneficiaries[b.ID] = b
+	return b, nil
+}
+
+func (m *memoryStore) GetBeneficiary(ctx context.Context, id int64) (models.Beneficiary, error) {
+	b, ok := m.beneficiaries[id]
+	if !ok {
+		return models.Beneficiary{}, ErrNotFound
+	}
+	return b, nil
+}
+
+func (m *memoryStore) GetBeneficiariesByUser(ctx context.Context, userID string) ([]models.Beneficiary, error) {
+	var list []models.Beneficiary
+	for _, b := range m.beneficiaries {
+		if b.UserID == userID {
+			list = append(list, b)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryStore) DeleteBeneficiary(ctx context.Context, id int64) error {
+	delete(m.beneficiaries, id)
+	return nil
+}
+
+func (m *memoryStore) SetBeneficiaryStatus(ctx context.Context, id int64, status, reason string) error {
+	b, ok := m.beneficiaries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	b.Status, b.StatusReason = status, reason
+	m.beneficiaries[id] = b
+	return nil
+}
+
+func (m *memoryStore) SaveVaultEntry(ctx context.Context, entry models.VaultEntry) error {
+	m.entries[entry.Token] = entry
+	return nil
+}
+
+func (m *memoryStore) GetVaultEntry(ctx context.Context, token string) (models.VaultEntry, error) {
+	entry, ok := m.entries[token]
+	if !ok {
+		return models.VaultEntry{}, vault.ErrTokenNotFound
+	}
+	return entry, nil
+}
+
+func (m *memoryStore) GetVaultEntriesNotUsingKey(ctx context.Context, keyID string, limit int) ([]models.VaultEntry, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) UpdateVaultEntryKey(ctx context.Context, token, ciphertext, keyID string) error {
+	return nil
+}
+
+func TestService_BankDetails(t *testing.T) {
+	encryptor, err := services.NewEncryptor(map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)}, "k1")
+	assert.NoError(t, err)
+	store := newMemoryStore()
+	service := New(store, vault.New(store, encryptor))
+
+	details := models.BankAccountDetails{
+		AccountHolderName: "John Doe",
+		AccountNumber:     "31926819",
+		RoutingNumber:     "601613",
+		Country:           "gb",
+		Currency:          "GBP",
+		AccountHolderType: "individual",
+	}
+	saved, err := service.Create(context.Background(), models.BeneficiaryRequest{UserID: "1", Nickname: "Salary", BankDetails: details})
+	assert.NoError(t, err)
+	assert.Equal(t, models.BeneficiaryPending, saved.Status)
+	assert.Equal(t, "GB", saved.Country)
+	assert.Equal(t, "6819", saved.Last4)
+
+	// Pending beneficiaries cannot be paid
+	_, _, err = service.BankDetails(context.Background(), "1", saved.ID, "gbp")
+	assert.ErrorIs(t, err, ErrNotVerified)
+
+	assert.NoError(t, service.SetStatus(context.Background(), saved.ID, models.BeneficiaryVerified, ""))
+
+	got, addedAt, err := service.BankDetails(context.Background(), "1", saved.ID, "GBP")
+	assert.NoError(t, err)
+	assert.Equal(t, details, got)
+	assert.Equal(t, saved.CreatedAt, addedAt)
+
+	_, _, err = service.BankDetails(context.Background(), "1", saved.ID, "usd")
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	// Other users cannot see or use the beneficiary
+	_, _, err = service.BankDetails(context.Background(), "2", saved.ID, "gbp")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, service.Delete(context.Background(), "2", saved.ID), ErrNotFound)
+
+	assert.NoError(t, service.Delete(context.Background(), "1", saved.ID))
+	list, err := service.List(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,289 @@
+// Package bus carries the payment events from the API to their consumers. Bus is
+// implemented by Kafka (package kafka), Redis Streams and an in-memory backend.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"payment-gateway/internal/logging"
+	"payment-gateway/internal/metrics"
+	"payment-gateway/internal/tracing"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Backends selected by BUS_BACKEND
+const (
+	BackendKafka  = "kafka"
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+)
+
+// Message is a published or consumed message
+type Message struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers map[string]string
+	Offset  int64 // Position in the topic, set on messages consumed from Kafka and memory
+}
+
+// Handler handles a consumed message. Backends run it through Process: a failed
+// message is retried and dead-lettered once retrying cannot fix it, so handlers must
+// be idempotent. Errors redelivery cannot fix are marked with Permanent.
+type Handler func(ctx context.Context, msg Message) error
+
+// Headers set on dead-lettered messages
+const (
+	HeaderError    = "x-error"    // Error of the last attempt
+	HeaderAttempts = "x-attempts" // Number of attempts
+)
+
+// Failed messages are retried with exponential backoff before they are dead-lettered
+var (
+	retryAttempts = 5                      // Deliveries of a message before it is dead-lettered
+	retryBackoff  = 200 * time.Millisecond // Wait before the first retry, doubled on every retry
+)
+
+// permanentError is a handler error redelivery cannot fix
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string { return p.err.Error() }
+func (p *permanentError) Unwrap() error { return p.err }
+
+// Permanent marks err as one redelivery cannot fix, such as a message that does not
+// decode. The message is dead-lettered at once instead of being retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// DeadLetterTopic returns the topic the failed messages of topic are moved to
+func DeadLetterTopic(topic string) string {
+	return topic + ".dead-letter"
+}
+
+// Bus publishes messages and runs the subscribed handlers
+type Bus interface {
+	// Publish sends msg to its topic
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe runs handle for the messages published to topic after the previous
+	// subscriber of the consumer group stopped, or from now on the first time. It
+	// blocks until StopConsumers is called or the subscription fails.
+	Subscribe(topic string, handle Handler) error
+	// StopConsumers stops the subscriptions once they finish the message in flight,
+	// or returns the error of ctx
+	StopConsumers(ctx context.Context) error
+	// CheckConsumers returns an error unless every topic has a running subscription
+	CheckConsumers(topics ...string) error
+	// Ping checks the backend is reachable
+	Ping(ctx context.Context) error
+	// Close releases the backend, call StopConsumers first
+	Close() error
+}
+
+// Publish publishes an encoded message. The trace context and the request and order
+// IDs of ctx are added to its headers.
+func Publish(ctx context.Context, b Bus, msg Message) (err error) {
+	ctx, span := tracing.Start(ctx, msg.Topic+" publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(semconv.MessagingDestinationName(msg.Topic)))
+	defer func() { tracing.End(span, err) }()
+
+	headers := make(map[string]string, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		headers[logging.HeaderRequestID] = id
+	}
+	if id := logging.OrderID(ctx); id != "" {
+		headers[logging.HeaderOrderID] = id
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	msg.Headers = headers
+
+	err = b.Publish(ctx, msg)
+	metrics.KafkaPublished(msg.Topic, err)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "message published", "topic", msg.Topic, "key", msg.Key)
+	return nil
+}
+
+// Process delivers a consumed message until the handler succeeds, retrying failures
+// with exponential backoff. A permanent failure, or a message still failing after the
+// last attempt, is published to the dead letter topic with its error. Process returns
+// nil once the message may be acknowledged. It returns an error when the message must
+// stay unacknowledged to be redelivered: the consumers were stopped while it was
+// retried, or the dead letter publish failed. Stopping does not cancel an attempt in
+// flight.
+func Process(ctx context.Context, b Bus, msg Message, handle Handler) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := Deliver(context.WithoutCancel(ctx), msg, handle)
+		if err == nil {
+			return nil
+		}
+		if IsPermanent(err) || attempt >= retryAttempts {
+			return deadLetter(context.WithoutCancel(ctx), b, msg, attempt, err)
+		}
+
+		slog.WarnContext(MessageContext(ctx, msg), "message failed, retrying", "topic", msg.Topic, "key", msg.Key,
+			"attempt", attempt, "backoff", backoff.String(), "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("consumer stopped before the message was handled: %v", err)
+		}
+		backoff *= 2
+	}
+}
+
+// deadLetter publishes a message that failed for good to the dead letter topic
+func deadLetter(ctx context.Context, b Bus, msg Message, attempts int, cause error) error {
+	ctx = MessageContext(ctx, msg)
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[HeaderError] = cause.Error()
+	headers[HeaderAttempts] = strconv.Itoa(attempts)
+
+	dead := Message{Topic: DeadLetterTopic(msg.Topic), Key: msg.Key, Value: msg.Value, Headers: headers}
+	if err := Publish(ctx, b, dead); err != nil {
+		return fmt.Errorf("failed to dead-letter message: %v", err)
+	}
+	slog.ErrorContext(ctx, "message dead-lettered", "topic", msg.Topic, "key", msg.Key, "attempts", attempts, "error", cause)
+	return nil
+}
+
+// Deliver runs handle in a consumer span continuing the trace of the message, with
+// the request and order IDs of its headers, and returns the error of handle
+func Deliver(ctx context.Context, msg Message, handle Handler) error {
+	ctx, span := tracing.Start(MessageContext(ctx, msg), msg.Topic+" process", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(semconv.MessagingDestinationName(msg.Topic), semconv.MessagingKafkaMessageOffset(int(msg.Offset))))
+	err := handle(ctx, msg)
+	tracing.End(span, err)
+	metrics.KafkaConsumed(msg.Topic, err)
+	return err
+}
+
+// MessageContext returns ctx with the trace context and the request and order IDs of
+// the message headers
+func MessageContext(ctx context.Context, msg Message) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+	if id := msg.Headers[logging.HeaderRequestID]; id != "" {
+		ctx = logging.WithRequestID(ctx, id)
+	}
+	if id := msg.Headers[logging.HeaderOrderID]; id != "" {
+		ctx = logging.WithOrderID(ctx, id)
+	}
+	return ctx
+}
+
+// Consumers tracks the subscriptions of a backend so they can be checked and stopped.
+// Backends embed it and wrap their consume loop in Begin and End.
+type Consumers struct {
+	ctx    context.Context // Cancelled by StopConsumers
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex
+	stopping   bool
+	subscribed map[string]bool // Topics with a running subscription
+}
+
+// NewConsumers returns the tracker of a new backend
+func NewConsumers() *Consumers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Consumers{ctx: ctx, cancel: cancel, subscribed: make(map[string]bool)}
+}
+
+// Begin registers a subscription. It returns a context cancelled by StopConsumers,
+// or false once StopConsumers was called.
+func (c *Consumers) Begin() (context.Context, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopping {
+		return nil, false
+	}
+	c.wg.Add(1)
+	return c.ctx, true
+}
+
+// Subscribed marks the subscription to topic as running, once it reads messages
+func (c *Consumers) Subscribed(topic string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribed[topic] = true
+}
+
+// End unregisters a subscription to topic
+func (c *Consumers) End(topic string) {
+	c.mu.Lock()
+	c.subscribed[topic] = false
+	c.mu.Unlock()
+	c.wg.Done()
+}
+
+// StopConsumers cancels the subscriptions and waits for them to finish the message in
+// flight, or returns the error of ctx
+func (c *Consumers) StopConsumers(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopping = true
+	c.mu.Unlock()
+	c.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("consumers did not stop: %v", ctx.Err())
+	}
+}
+
+// CheckConsumers returns an error unless every topic has a running subscription
+func (c *Consumers) CheckConsumers(topics ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var missing []string
+	for _, topic := range topics {
+		if !c.subscribed[topic] {
+			missing = append(missing, topic)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("not subscribed to %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Cancel stops the subscriptions without waiting for them
+func (c *Consumers) Cancel() {
+	c.cancel()
+}
@@ -0,0 +1,216 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/logging"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type delivery struct {
+	ctx context.Context
+	msg Message
+}
+
+// subscribe runs a subscription to topic in the background once it is running
+func subscribe(t *testing.T, b Bus, topic string) <-chan delivery {
+	deliveries := make(chan delivery, 10)
+	go b.Subscribe(topic, func(ctx context.Context, msg Message) error {
+		deliveries <- delivery{ctx, msg}
+		return nil
+	})
+	require.Eventually(t, func() bool { return b.CheckConsumers(topic) == nil }, time.Second, time.Millisecond)
+	return deliveries
+}
+
+func receive(t *testing.T, deliveries <-chan delivery) delivery {
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not delivered")
+		return delivery{}
+	}
+}
+
+func newRedisStreams(t *testing.T, addr string) *RedisStreams {
+	b, err := NewRedisStreams(config.Redis{Addr: addr})
+	require.NoError(t, err)
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestBus(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tests := []struct {
+		name    string
+		new     func(t *testing.T) Bus
+		backlog bool // Messages published before the first subscription are delivered
+	}{
+		{name: "memory", new: func(t *testing.T) Bus { return NewMemory() }, backlog: true},
+		{name: "redis streams", new: func(t *testing.T) Bus { return newRedisStreams(t, miniredis.RunT(t).Addr()) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.new(t)
+			ctx := context.Background()
+
+			// Published before the first subscription
+			require.NoError(t, Publish(ctx, b, Message{Topic: "gateway.test", Key: "evt_0", Value: []byte(`{"id":"evt_0"}`)}))
+			deliveries := subscribe(t, b, "gateway.test")
+			if tt.backlog {
+				assert.Equal(t, "evt_0", receive(t, deliveries).msg.Key)
+			}
+
+			provider := sdktrace.NewTracerProvider()
+			ctx, span := provider.Tracer("test").Start(ctx, "webhook")
+			defer span.End()
+			ctx = logging.WithOrderID(logging.WithRequestID(ctx, "req-1"), "pi_1")
+			require.NoError(t, Publish(ctx, b, Message{Topic: "gateway.test", Key: "evt_1", Value: []byte(`{"id":"evt_1"}`),
+				Headers: map[string]string{"content-type": "application/json"}}))
+			require.NoError(t, Publish(context.Background(), b, Message{Topic: "gateway.test", Key: "evt_2", Value: []byte(`{"id":"evt_2"}`)}))
+
+			first := receive(t, deliveries)
+			assert.Equal(t, "evt_1", first.msg.Key)
+			assert.JSONEq(t, `{"id":"evt_1"}`, string(first.msg.Value))
+			assert.Equal(t, "application/json", first.msg.Headers["content-type"])
+			assert.Equal(t, "req-1", logging.RequestID(first.ctx))
+			assert.Equal(t, "pi_1", logging.OrderID(first.ctx))
+			assert.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(first.ctx).TraceID())
+			assert.Equal(t, "evt_2", receive(t, deliveries).msg.Key)
+
+			stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			require.NoError(t, b.StopConsumers(stopCtx))
+			assert.EqualError(t, b.CheckConsumers("gateway.test", "gateway.other"), "not subscribed to gateway.test, gateway.other")
+			assert.NoError(t, b.Subscribe("gateway.test", nil), "no subscription after stopping")
+		})
+	}
+}
+
+func TestRedisStreams_ResumesAfterRestart(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	first := newRedisStreams(t, server.Addr())
+	deliveries := subscribe(t, first, "gateway.test")
+	require.NoError(t, Publish(ctx, first, Message{Topic: "gateway.test", Key: "evt_1", Value: []byte("first")}))
+	assert.Equal(t, "evt_1", receive(t, deliveries).msg.Key)
+	stopCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	require.NoError(t, first.StopConsumers(stopCtx))
+
+	// Published while no instance runs, handled by the next one
+	require.NoError(t, Publish(ctx, first, Message{Topic: "gateway.test", Key: "evt_2", Value: []byte("second")}))
+	deliveries = subscribe(t, newRedisStreams(t, server.Addr()), "gateway.test")
+	assert.Equal(t, "evt_2", receive(t, deliveries).msg.Key)
+	assert.Empty(t, deliveries)
+}
+
+func TestProcess(t *testing.T) {
+	attempts, backoff := retryAttempts, retryBackoff
+	retryAttempts, retryBackoff = 3, time.Millisecond
+	t.Cleanup(func() { retryAttempts, retryBackoff = attempts, backoff })
+
+	transient := errors.New("database unavailable")
+	tests := []struct {
+		name         string
+		errs         []error // Errors of the successive attempts, then success
+		attempts     int
+		deadLettered bool
+	}{
+		{name: "success", attempts: 1},
+		{name: "transient failure retried", errs: []error{transient, transient}, attempts: 3},
+		{name: "permanent failure dead-lettered", errs: []error{Permanent(errors.New("invalid event"))}, attempts: 1, deadLettered: true},
+		{name: "retries exhausted", errs: []error{transient, transient, transient}, attempts: 3, deadLettered: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewMemory()
+			dead := subscribe(t, b, DeadLetterTopic("gateway.test"))
+			calls := 0
+			handle := func(ctx context.Context, msg Message) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			}
+
+			msg := Message{Topic: "gateway.test", Key: "evt_1", Value: []byte("event"), Headers: map[string]string{"content-type": "application/json"}}
+			assert.NoError(t, Process(context.Background(), b, msg, handle))
+			assert.Equal(t, tt.attempts, calls)
+			if !tt.deadLettered {
+				assert.Empty(t, dead)
+				return
+			}
+			d := receive(t, dead)
+			assert.Equal(t, "evt_1", d.msg.Key)
+			assert.Equal(t, "event", string(d.msg.Value))
+			assert.Equal(t, "application/json", d.msg.Headers["content-type"])
+			assert.Equal(t, tt.errs[len(tt.errs)-1].Error(), d.msg.Headers[HeaderError])
+			assert.Equal(t, strconv.Itoa(tt.attempts), d.msg.Headers[HeaderAttempts])
+		})
+	}
+}
+
+func TestRedisStreams_RedeliversUnhandledMessage(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = time.Hour
+	t.Cleanup(func() { retryBackoff = backoff })
+	server := miniredis.RunT(t)
+
+	first := newRedisStreams(t, server.Addr())
+	failed := make(chan struct{}, 1)
+	go first.Subscribe("gateway.test", func(ctx context.Context, msg Message) error {
+		failed <- struct{}{}
+		return errors.New("database unavailable")
+	})
+	require.Eventually(t, func() bool { return first.CheckConsumers("gateway.test") == nil }, time.Second, time.Millisecond)
+	require.NoError(t, Publish(context.Background(), first, Message{Topic: "gateway.test", Key: "evt_1"}))
+	<-failed
+
+	// Stopped while waiting to retry, the message stays pending for the next instance
+	stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, first.StopConsumers(stopCtx))
+	deliveries := subscribe(t, newRedisStreams(t, server.Addr()), "gateway.test")
+	assert.Equal(t, "evt_1", receive(t, deliveries).msg.Key)
+}
+
+func TestRedisStreams_ClaimsMessagesOfGoneConsumer(t *testing.T) {
+	idle := streamClaimIdle
+	streamClaimIdle = 10 * time.Millisecond
+	t.Cleanup(func() { streamClaimIdle = idle })
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	// Read by an instance that stops without acknowledging it and never comes back
+	gone := newRedisStreams(t, server.Addr())
+	require.NoError(t, gone.client.XGroupCreateMkStream(ctx, "gateway.test", streamGroup, "$").Err())
+	require.NoError(t, Publish(ctx, gone, Message{Topic: "gateway.test", Key: "evt_1"}))
+	require.NoError(t, gone.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: streamGroup, Consumer: "gone-pod", Streams: []string{"gateway.test", ">"}, Count: 1,
+	}).Err())
+	time.Sleep(2 * streamClaimIdle)
+
+	deliveries := subscribe(t, newRedisStreams(t, server.Addr()), "gateway.test")
+	assert.Equal(t, "evt_1", receive(t, deliveries).msg.Key)
+	require.Eventually(t, func() bool {
+		pending, err := gone.client.XPending(ctx, "gateway.test", streamGroup).Result()
+		return err == nil && pending.Count == 0
+	}, time.Second, time.Millisecond, "claimed message acknowledged")
+}
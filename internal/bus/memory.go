@@ -0,0 +1,105 @@
+package bus
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a bus keeping its topics in memory, for tests and single instance local
+// runs. The first subscriber of a topic gets every message published to it, including
+// those published before it subscribed; the next ones resume after the last message
+// acknowledged by the previous subscriber.
+type Memory struct {
+	*Consumers
+
+	mu      sync.Mutex
+	topics  map[string][]Message
+	acked   map[string]int64 // Offset of the next message per topic
+	changed chan struct{}    // Closed and replaced on every message
+}
+
+var _ Bus = (*Memory)(nil)
+
+// NewMemory returns an empty in-memory bus
+func NewMemory() *Memory {
+	return &Memory{
+		Consumers: NewConsumers(),
+		topics:    make(map[string][]Message),
+		acked:     make(map[string]int64),
+		changed:   make(chan struct{}),
+	}
+}
+
+// Publish appends a copy of msg to its topic
+func (m *Memory) Publish(ctx context.Context, msg Message) error {
+	headers := make(map[string]string, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	msg.Headers = headers
+	msg.Value = append([]byte(nil), msg.Value...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg.Offset = int64(len(m.topics[msg.Topic]))
+	m.topics[msg.Topic] = append(m.topics[msg.Topic], msg)
+	close(m.changed)
+	m.changed = make(chan struct{})
+	return nil
+}
+
+// Subscribe handles the messages of topic in order until StopConsumers is called
+func (m *Memory) Subscribe(topic string, handle Handler) error {
+	ctx, ok := m.Begin()
+	if !ok {
+		return nil
+	}
+	defer m.End(topic)
+
+	m.mu.Lock()
+	offset := m.acked[topic]
+	m.mu.Unlock()
+	m.Subscribed(topic)
+
+	for {
+		m.mu.Lock()
+		messages := m.topics[topic]
+		changed := m.changed
+		m.mu.Unlock()
+
+		for ; offset < int64(len(messages)); offset++ {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// A message left unacknowledged is redelivered to the next subscriber
+			if err := Process(ctx, m, messages[offset], handle); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			m.ack(topic, offset+1)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// ack records where the next subscriber of topic resumes
+func (m *Memory) ack(topic string, next int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acked[topic] = next
+}
+
+// Ping always succeeds
+func (m *Memory) Ping(ctx context.Context) error { return nil }
+
+// Close stops the subscriptions without waiting for them
+func (m *Memory) Close() error {
+	m.Cancel()
+	return nil
+}
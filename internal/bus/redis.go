@@ -0,0 +1,201 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"payment-gateway/internal/config"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	streamGroup         = "payment-gateway"
+	streamMaxLen        = 100000           // Streams are trimmed to about this many messages
+	streamBlock         = 5 * time.Second  // Longest wait of a read for new messages
+	streamClaimInterval = 30 * time.Second // How often pending messages of other consumers are claimed
+	headerPrefix        = "header:"
+)
+
+// streamClaimIdle is how long a message stays pending with a consumer before another
+// one claims it. It is well above the time a message takes to be handled with its retries.
+var streamClaimIdle = 2 * time.Minute
+
+// RedisStreams is a bus on Redis Streams, one stream per topic. The instances share
+// a consumer group, a message is handled by one of them and acknowledged with XACK
+// once handled. Messages left unacknowledged by a stopped instance are handled when
+// it restarts, or claimed with XAUTOCLAIM by any instance once pending for
+// streamClaimIdle, so those of an instance that never comes back are not lost.
+type RedisStreams struct {
+	*Consumers
+	client   *redis.Client
+	consumer string // Name of this instance in the group
+}
+
+var _ Bus = (*RedisStreams)(nil)
+
+// NewRedisStreams connects to Redis
+func NewRedisStreams(cfg config.Redis) (*RedisStreams, error) {
+	client := redis.NewClient(&redis.Options{Addr: cfg.Addr, Password: cfg.Password})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	consumer, err := os.Hostname()
+	if err != nil {
+		consumer = streamGroup
+	}
+	return &RedisStreams{Consumers: NewConsumers(), client: client, consumer: consumer}, nil
+}
+
+// Publish adds msg to the stream of its topic
+func (r *RedisStreams) Publish(ctx context.Context, msg Message) error {
+	values := []interface{}{"key", msg.Key, "value", msg.Value}
+	for key, value := range msg.Headers {
+		values = append(values, headerPrefix+key, value)
+	}
+	err := r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: msg.Topic,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to add message to stream: %v", err)
+	}
+	return nil
+}
+
+// Subscribe handles the messages of the topic stream until StopConsumers is called,
+// starting with those this instance received but did not acknowledge before
+func (r *RedisStreams) Subscribe(topic string, handle Handler) error {
+	ctx, ok := r.Begin()
+	if !ok {
+		return nil
+	}
+	defer r.End(topic)
+
+	// The group starts at the end of the stream when it is created
+	err := r.client.XGroupCreateMkStream(ctx, topic, streamGroup, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %v", err)
+	}
+	r.Subscribed(topic)
+
+	start := "0" // Pending messages of this consumer, then ">" for new ones
+	var claimed time.Time
+	for {
+		if time.Since(claimed) >= streamClaimInterval {
+			claimed = time.Now()
+			if err := r.claim(ctx, topic, handle); err != nil || ctx.Err() != nil {
+				return err
+			}
+		}
+
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    streamGroup,
+			Consumer: r.consumer,
+			Streams:  []string{topic, start},
+			Count:    10,
+			Block:    streamBlock,
+		}).Result()
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, redis.Nil):
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to read stream: %v", err)
+		}
+
+		messages := streams[0].Messages
+		if start == "0" && len(messages) == 0 {
+			start = ">"
+			continue
+		}
+		if err := r.process(ctx, topic, messages, handle); err != nil || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// claim takes over and handles the messages of the topic stream pending with any
+// consumer of the group for longer than streamClaimIdle
+func (r *RedisStreams) claim(ctx context.Context, topic string, handle Handler) error {
+	start := "0-0"
+	for {
+		messages, next, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   topic,
+			Group:    streamGroup,
+			Consumer: r.consumer,
+			MinIdle:  streamClaimIdle,
+			Start:    start,
+			Count:    10,
+		}).Result()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim pending messages: %v", err)
+		}
+		if err := r.process(ctx, topic, messages, handle); err != nil || ctx.Err() != nil {
+			return err
+		}
+		if next == "0-0" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// process handles messages in order and acknowledges each once handled. It returns
+// nil when stopped, the rest of the messages then stay pending.
+func (r *RedisStreams) process(ctx context.Context, topic string, messages []redis.XMessage, handle Handler) error {
+	for _, message := range messages {
+		// Stopping does not cancel the message in flight
+		if ctx.Err() != nil {
+			return nil
+		}
+		// A message left unacknowledged stays pending and is redelivered on restart
+		if err := Process(ctx, r, streamMessage(topic, message), handle); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := r.client.XAck(context.WithoutCancel(ctx), topic, streamGroup, message.ID).Err(); err != nil {
+			return fmt.Errorf("failed to acknowledge message: %v", err)
+		}
+	}
+	return nil
+}
+
+// streamMessage converts a stream entry to a message
+func streamMessage(topic string, entry redis.XMessage) Message {
+	msg := Message{Topic: topic, Headers: make(map[string]string)}
+	for field, value := range entry.Values {
+		s, _ := value.(string)
+		switch {
+		case field == "key":
+			msg.Key = s
+		case field == "value":
+			msg.Value = []byte(s)
+		case strings.HasPrefix(field, headerPrefix):
+			msg.Headers[strings.TrimPrefix(field, headerPrefix)] = s
+		}
+	}
+	return msg
+}
+
+// Ping checks that Redis is reachable
+func (r *RedisStreams) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Close stops the subscriptions without waiting for them and closes the connection pool
+func (r *RedisStreams) Close() error {
+	r.Cancel()
+	return r.client.Close()
+}
@@ -0,0 +1,652 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"payment-gateway/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/joho/godotenv"
+)
+
+// Config is the configuration of the service. Load reads it from the config file
+// and the environment, the environment wins.
+type Config struct {
+	Server         Server
+	Log            Log
+	Postgres       Postgres
+	Redis          Redis
+	Bus            Bus
+	Kafka          Kafka
+	Stripe         Stripe
+	Razorpay       Razorpay
+	DefaultGateway DefaultGateway
+	Registry       Registry
+	Auth           Auth
+	Encryption     Encryption
+	RateLimit      RateLimit
+	Risk           Risk
+	GatewayScore   GatewayScore
+	Tracing        Tracing
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Addr            string        // SERVER_ADDR
+	ShutdownTimeout time.Duration // SHUTDOWN_TIMEOUT, bounds the whole graceful shutdown
+}
+
+// Log configures the default logger
+type Log struct {
+	Level  string // LOG_LEVEL: debug, info, warn or error
+	Format string // LOG_FORMAT: json or text
+}
+
+// Postgres configures the database connection
+type Postgres struct {
+	Host     string // DB_HOST
+	Port     string // DB_PORT
+	User     string // DB_USER
+	Password string // DB_PASSWORD
+	Name     string // DB_NAME
+	SSLMode  string // DB_SSLMODE
+}
+
+// URL returns the connection string
+func (p Postgres) URL() string {
+	return "postgres://" + p.User + ":" + p.Password + "@" + p.Host + ":" + p.Port + "/" + p.Name + "?sslmode=" + p.SSLMode
+}
+
+// Redis configures the Redis connection
+type Redis struct {
+	Addr     string // REDIS_ADDR
+	Password string // REDIS_PASSWORD
+}
+
+// Bus configures the message bus carrying the gateway webhooks
+type Bus struct {
+	Backend  string // BUS_BACKEND: kafka, redis (Redis Streams on REDIS_ADDR) or memory (single instance, lost on restart)
+	Encoding string // BUS_ENCODING: json or protobuf (payments.v1.PaymentEvent of proto/payments/v1)
+}
+
+// Kafka configures the brokers
+type Kafka struct {
+	Brokers []string // KAFKA_BROKER, comma separated
+}
+
+// Stripe configures the Stripe gateway
+type Stripe struct {
+	Enabled       bool          // STRIPE_ENABLED
+	SecretKey     string        // STRIPE_SECRET_KEY
+	AccountID     string        // STRIPE_ACCOUNT_ID
+	WebhookSecret string        // STRIPE_WEBHOOK_SECRET
+	Timeout       time.Duration // STRIPE_TIMEOUT, bounds each deposit or withdrawal call
+}
+
+// Razorpay configures the Razorpay gateway
+type Razorpay struct {
+	Enabled   bool          // RAZORPAY_ENABLED
+	KeyID     string        // RAZORPAY_KEY_ID
+	KeySecret string        // RAZORPAY_KEY_SECRET
+	Timeout   time.Duration // RAZORPAY_TIMEOUT
+}
+
+// DefaultGateway configures the fallback gateway, its API keys are optional
+type DefaultGateway struct {
+	Enabled       bool          // GATEWAY_ENABLED
+	SecretKey     string        // GATEWAY_SECRET_KEY
+	AccountID     string        // GATEWAY_ACCOUNT_ID
+	Timeout       time.Duration // GATEWAY_TIMEOUT
+	URL           string        // GATEWAY_URL, the gateway API, e.g. the simulator; without it payments are only stubbed
+	WebhookSecret string        // GATEWAY_WEBHOOK_SECRET, required when enabled, signs the webhooks
+}
+
+// Registry configures the gateway registry
+type Registry struct {
+	RefreshInterval time.Duration // GATEWAY_STATE_REFRESH, how often the gateways' enabled flags are reloaded from the DB
+}
+
+// Auth configures the caller authentication, with neither a JWT key source nor API keys
+// every protected route answers 401
+type Auth struct {
+	JWT     JWT
+	APIKeys []APIKey // API_KEYS, "<id>:<secret>[:<scope>|<scope>]" separated by commas
+}
+
+// JWT selects how bearer tokens are verified, the first key source set wins
+type JWT struct {
+	Secret    string        // JWT_SECRET, shared HS256 secret
+	PublicKey string        // JWT_PUBLIC_KEY, PEM encoded RSA public key
+	JWKSURL   string        // JWT_JWKS_URL, JSON Web Key Set holding RSA keys
+	JWKSTTL   time.Duration // JWT_JWKS_TTL, how long fetched keys are cached
+	Issuer    string        // JWT_ISSUER, not checked when empty
+	Audience  string        // JWT_AUDIENCE, not checked when empty
+}
+
+// APIKey is a shared secret of a server-to-server caller
+type APIKey struct {
+	ID     string
+	Secret string
+	Scopes []string // Empty grants the internal scope
+}
+
+// Encryption configures the key-encryption keys of the vault
+type Encryption struct {
+	Keys      map[string][]byte // DATA_ENCRYPTION_KEYS, "<keyID>:<base64 32 byte key>" separated by commas
+	ActiveKey string            // DATA_ENCRYPTION_ACTIVE_KEY, wraps new values, the first key by default
+}
+
+// RateLimit configures the rate limit policies and the withdrawal velocity
+type RateLimit struct {
+	Policies           map[string]RatePolicy // RATE_LIMIT_<POLICY>, overrides of the built-in policies
+	TrustProxy         bool                  // RATE_LIMIT_TRUST_PROXY, use X-Forwarded-For for the client IP
+	WithdrawalVelocity Velocity              // WITHDRAWAL_VELOCITY_MAX and WITHDRAWAL_VELOCITY_WINDOW
+}
+
+// RatePolicy is a token bucket of Limit requests per Period with bursts of up to
+// Burst, read from "<limit>/<period>[,<burst>]"
+type RatePolicy struct {
+	Limit  int64
+	Period time.Duration
+	Burst  int64
+}
+
+// Velocity caps how many requests a caller may complete in a sliding window
+type Velocity struct {
+	Max    int64
+	Window time.Duration
+}
+
+// ratePolicies are the policies that can be overridden, as named by the ratelimit package
+var ratePolicies = []string{"ip", "read", "deposit", "withdrawal", "admin", "webhook"}
+
+// Risk tunes the built-in risk rules
+type Risk struct {
+	VelocityWindow  time.Duration // RISK_VELOCITY_WINDOW, window transactions are counted over
+	VelocityReview  int64         // RISK_VELOCITY_REVIEW, transactions in the window that send the next one to review, 0 disables
+	VelocityDeny    int64         // RISK_VELOCITY_DENY, transactions in the window that deny the next one, 0 disables
+	AmountLookback  time.Duration // RISK_AMOUNT_LOOKBACK, history the amount anomaly rule compares against
+	AmountFactor    float64       // RISK_AMOUNT_FACTOR, amounts above this multiple of the average are reviewed
+	AmountMinCount  int64         // RISK_AMOUNT_MIN_COUNT, past transactions needed before the average is trusted
+	AmountNewUser   int64         // RISK_AMOUNT_NEW_USER, amounts above this are reviewed until the history is long enough
+	CountryMismatch string        // RISK_COUNTRY_MISMATCH, decision when the country differs from the user's
+	PayeeCooling    time.Duration // RISK_PAYEE_COOLING, payout bank accounts saved more recently are reviewed, 0 disables
+	// ApprovalThresholds holds per currency the withdrawal amount from which a manual
+	// approval is required, WITHDRAWAL_APPROVAL_THRESHOLDS as "<currency>:<amount>,..."
+	ApprovalThresholds map[string]int64
+}
+
+// DefaultRisk returns the built-in risk thresholds
+func DefaultRisk() Risk {
+	return Risk{
+		VelocityWindow:  24 * time.Hour,
+		VelocityReview:  10,
+		VelocityDeny:    25,
+		AmountLookback:  90 * 24 * time.Hour,
+		AmountFactor:    5,
+		AmountMinCount:  3,
+		AmountNewUser:   500000,
+		CountryMismatch: models.RiskReview,
+		PayeeCooling:    24 * time.Hour,
+		ApprovalThresholds: map[string]int64{
+			"AED": 5000000,
+			"GBP": 1000000,
+			"INR": 100000000,
+			"USD": 1000000,
+		},
+	}
+}
+
+// GatewayScore controls how gateway success ratios are computed from the bucketed
+// outcome counters
+type GatewayScore struct {
+	BucketSize    time.Duration      // GATEWAY_SCORE_BUCKET, width of a single counter bucket
+	Buckets       int                // GATEWAY_SCORE_BUCKETS, number of buckets in the sliding window
+	Decay         float64            // GATEWAY_SCORE_DECAY, weight multiplier applied per bucket of age (0 < Decay <= 1)
+	MinSamples    int64              // GATEWAY_SCORE_MIN_SAMPLES, samples required before the ratio is trusted
+	PriorScore    float64            // GATEWAY_SCORE_PRIOR, score used while a gateway is below MinSamples
+	LatencyTarget time.Duration      // GATEWAY_SCORE_LATENCY_TARGET, average latency above which the score is penalised
+	LatencyWeight float64            // GATEWAY_SCORE_LATENCY_WEIGHT, maximum fraction of the score removed for slow gateways
+	ErrorWeights  map[string]float64 // GATEWAY_SCORE_ERROR_WEIGHTS, failure weight per error type, 1.0 when absent
+}
+
+// DefaultGatewayScore returns the built-in scoring configuration
+func DefaultGatewayScore() GatewayScore {
+	return GatewayScore{
+		BucketSize:    5 * time.Minute,
+		Buckets:       12,
+		Decay:         0.85,
+		MinSamples:    10,
+		PriorScore:    0.5,
+		LatencyTarget: 2 * time.Second,
+		LatencyWeight: 0.2,
+		ErrorWeights: map[string]float64{
+			// Declines are usually caused by the payer, not by the gateway
+			"card_declined":      0.25,
+			"insufficient_funds": 0.1,
+			"expired_card":       0.1,
+			// Infrastructure failures count more than a plain failure
+			models.GatewayErrorTimeout:     1.5,
+			models.GatewayErrorUnavailable: 1.5,
+		},
+	}
+}
+
+// Tracing configures the trace exporter, OTLP itself is configured by the standard
+// OTEL_EXPORTER_OTLP_* variables
+type Tracing struct {
+	Exporter    string // OTEL_TRACES_EXPORTER: otlp, stdout or none
+	ServiceName string // OTEL_SERVICE_NAME
+}
+
+// Error lists every missing and invalid key
+type Error struct {
+	Missing []string
+	Invalid []string
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "invalid "+strings.Join(e.Invalid, ", "))
+	}
+	return "invalid configuration: " + strings.Join(parts, "; ")
+}
+
+// Load loads file, if it exists, into the environment without overriding variables
+// that are already set and reads the configuration from the environment
+func Load(file string) (*Config, error) {
+	if err := godotenv.Load(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load %s: %v", file, err)
+	}
+	return FromEnv()
+}
+
+// FromEnv reads the configuration from the environment. A gateway is enabled by its
+// flag, or when the flag is unset by any of its keys being set; an enabled gateway
+// requires all of its keys.
+func FromEnv() (*Config, error) {
+	e := &env{}
+	cfg := &Config{
+		Server: Server{
+			Addr:            e.str("SERVER_ADDR", ":8080"),
+			ShutdownTimeout: e.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		},
+		Log: Log{
+			Level:  e.oneOf("LOG_LEVEL", "info", "debug", "info", "warn", "error"),
+			Format: e.oneOf("LOG_FORMAT", "json", "json", "text"),
+		},
+		Postgres: Postgres{
+			Host:     e.required("DB_HOST"),
+			Port:     e.str("DB_PORT", "5432"),
+			User:     e.required("DB_USER"),
+			Password: os.Getenv("DB_PASSWORD"),
+			Name:     e.required("DB_NAME"),
+			SSLMode:  e.str("DB_SSLMODE", "disable"),
+		},
+		Redis: Redis{
+			Addr:     e.str("REDIS_ADDR", "localhost:6379"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+		},
+		Bus: Bus{
+			Backend:  e.oneOf("BUS_BACKEND", "kafka", "kafka", "redis", "memory"),
+			Encoding: e.oneOf("BUS_ENCODING", "json", "json", "protobuf"),
+		},
+		Kafka: Kafka{
+			Brokers: e.list("KAFKA_BROKER", "localhost:9093"),
+		},
+	}
+
+	if cfg.Stripe.Enabled = e.gateway("STRIPE_ENABLED", "STRIPE_SECRET_KEY", "STRIPE_ACCOUNT_ID", "STRIPE_WEBHOOK_SECRET"); cfg.Stripe.Enabled {
+		cfg.Stripe.SecretKey = e.required("STRIPE_SECRET_KEY")
+		cfg.Stripe.AccountID = e.required("STRIPE_ACCOUNT_ID")
+		cfg.Stripe.WebhookSecret = e.required("STRIPE_WEBHOOK_SECRET")
+		cfg.Stripe.Timeout = e.duration("STRIPE_TIMEOUT", 30*time.Second)
+	}
+	if cfg.Razorpay.Enabled = e.gateway("RAZORPAY_ENABLED", "RAZORPAY_KEY_ID", "RAZORPAY_KEY_SECRET"); cfg.Razorpay.Enabled {
+		cfg.Razorpay.KeyID = e.required("RAZORPAY_KEY_ID")
+		cfg.Razorpay.KeySecret = e.required("RAZORPAY_KEY_SECRET")
+		cfg.Razorpay.Timeout = e.duration("RAZORPAY_TIMEOUT", 30*time.Second)
+	}
+	cfg.DefaultGateway = DefaultGateway{
+		Enabled:   e.boolean("GATEWAY_ENABLED", true),
+		SecretKey: os.Getenv("GATEWAY_SECRET_KEY"),
+		AccountID: os.Getenv("GATEWAY_ACCOUNT_ID"),
+		Timeout:   e.duration("GATEWAY_TIMEOUT", 30*time.Second),
+		URL:       strings.TrimSuffix(e.str("GATEWAY_URL", ""), "/"),
+	}
+	if cfg.DefaultGateway.Enabled {
+		// Unsigned webhooks would let anyone post payment events
+		cfg.DefaultGateway.WebhookSecret = e.required("GATEWAY_WEBHOOK_SECRET")
+	}
+	cfg.Registry.RefreshInterval = e.duration("GATEWAY_STATE_REFRESH", 10*time.Second)
+
+	cfg.Auth = Auth{
+		JWT: JWT{
+			Secret:    os.Getenv("JWT_SECRET"),
+			PublicKey: e.publicKey("JWT_PUBLIC_KEY"),
+			JWKSURL:   e.str("JWT_JWKS_URL", ""),
+			JWKSTTL:   e.duration("JWT_JWKS_TTL", time.Hour),
+			Issuer:    e.str("JWT_ISSUER", ""),
+			Audience:  e.str("JWT_AUDIENCE", ""),
+		},
+		APIKeys: e.apiKeys("API_KEYS"),
+	}
+	cfg.Encryption.Keys, cfg.Encryption.ActiveKey = e.encryptionKeys("DATA_ENCRYPTION_KEYS", "DATA_ENCRYPTION_ACTIVE_KEY")
+
+	cfg.RateLimit = RateLimit{
+		Policies:   make(map[string]RatePolicy),
+		TrustProxy: e.boolean("RATE_LIMIT_TRUST_PROXY", false),
+		WithdrawalVelocity: Velocity{
+			Max:    e.integer("WITHDRAWAL_VELOCITY_MAX", 5, 1),
+			Window: e.duration("WITHDRAWAL_VELOCITY_WINDOW", time.Hour),
+		},
+	}
+	for _, name := range ratePolicies {
+		if policy, ok := e.ratePolicy("RATE_LIMIT_" + strings.ToUpper(name)); ok {
+			cfg.RateLimit.Policies[name] = policy
+		}
+	}
+
+	risk := DefaultRisk()
+	cfg.Risk = Risk{
+		VelocityWindow:     e.duration("RISK_VELOCITY_WINDOW", risk.VelocityWindow),
+		VelocityReview:     e.integer("RISK_VELOCITY_REVIEW", risk.VelocityReview, 0),
+		VelocityDeny:       e.integer("RISK_VELOCITY_DENY", risk.VelocityDeny, 0),
+		AmountLookback:     e.duration("RISK_AMOUNT_LOOKBACK", risk.AmountLookback),
+		AmountFactor:       e.float("RISK_AMOUNT_FACTOR", risk.AmountFactor, func(v float64) bool { return v > 1 }),
+		AmountMinCount:     e.integer("RISK_AMOUNT_MIN_COUNT", risk.AmountMinCount, 0),
+		AmountNewUser:      e.integer("RISK_AMOUNT_NEW_USER", risk.AmountNewUser, 0),
+		CountryMismatch:    e.oneOf("RISK_COUNTRY_MISMATCH", risk.CountryMismatch, models.RiskAllow, models.RiskReview, models.RiskDeny),
+		PayeeCooling:       e.optionalDuration("RISK_PAYEE_COOLING", risk.PayeeCooling),
+		ApprovalThresholds: e.thresholds("WITHDRAWAL_APPROVAL_THRESHOLDS", risk.ApprovalThresholds),
+	}
+
+	fraction := func(v float64) bool { return v >= 0 && v <= 1 }
+	score := DefaultGatewayScore()
+	cfg.GatewayScore = GatewayScore{
+		BucketSize:    e.duration("GATEWAY_SCORE_BUCKET", score.BucketSize),
+		Buckets:       int(e.integer("GATEWAY_SCORE_BUCKETS", int64(score.Buckets), 1)),
+		Decay:         e.float("GATEWAY_SCORE_DECAY", score.Decay, func(v float64) bool { return v > 0 && v <= 1 }),
+		MinSamples:    e.integer("GATEWAY_SCORE_MIN_SAMPLES", score.MinSamples, 0),
+		PriorScore:    e.float("GATEWAY_SCORE_PRIOR", score.PriorScore, fraction),
+		LatencyTarget: e.duration("GATEWAY_SCORE_LATENCY_TARGET", score.LatencyTarget),
+		LatencyWeight: e.float("GATEWAY_SCORE_LATENCY_WEIGHT", score.LatencyWeight, fraction),
+		ErrorWeights:  e.weights("GATEWAY_SCORE_ERROR_WEIGHTS", score.ErrorWeights),
+	}
+
+	cfg.Tracing = Tracing{
+		Exporter:    e.oneOf("OTEL_TRACES_EXPORTER", "none", "otlp", "stdout", "none"),
+		ServiceName: e.str("OTEL_SERVICE_NAME", "payment-gateway"),
+	}
+
+	if len(e.err.Missing) > 0 || len(e.err.Invalid) > 0 {
+		return nil, &e.err
+	}
+	return cfg, nil
+}
+
+// env reads variables and collects the missing and invalid ones
+type env struct {
+	err Error
+}
+
+func (e *env) str(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func (e *env) required(key string) string {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		e.err.Missing = append(e.err.Missing, key)
+	}
+	return v
+}
+
+func (e *env) list(key, def string) []string {
+	var values []string
+	for _, v := range strings.Split(e.str(key, def), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		e.err.Invalid = append(e.err.Invalid, key)
+	}
+	return values
+}
+
+func (e *env) duration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s %q", key, v))
+		return def
+	}
+	return d
+}
+
+// optionalDuration is a duration that may be 0, which disables what it bounds
+func (e *env) optionalDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s %q", key, v))
+		return def
+	}
+	return d
+}
+
+func (e *env) integer(key string, def, min int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || i < min {
+		e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s %q", key, v))
+		return def
+	}
+	return i
+}
+
+func (e *env) float(key string, def float64, valid func(float64) bool) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || !valid(f) {
+		e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s %q", key, v))
+		return def
+	}
+	return f
+}
+
+func (e *env) boolean(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s %q", key, v))
+		return def
+	}
+	return b
+}
+
+func (e *env) oneOf(key, def string, allowed ...string) string {
+	v := strings.ToLower(e.str(key, def))
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s %q, expected %s", key, v, strings.Join(allowed, ", ")))
+	return def
+}
+
+// gateway reports whether a gateway is enabled by its flag, or by any of its keys
+func (e *env) gateway(flag string, keys ...string) bool {
+	if os.Getenv(flag) != "" {
+		return e.boolean(flag, false)
+	}
+	for _, key := range keys {
+		if strings.TrimSpace(os.Getenv(key)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// publicKey checks that the value is a PEM encoded RSA public key
+func (e *env) publicKey(key string) string {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return ""
+	}
+	if _, err := jwt.ParseRSAPublicKeyFromPEM([]byte(v)); err != nil {
+		e.err.Invalid = append(e.err.Invalid, key+", expected a PEM encoded RSA public key")
+		return ""
+	}
+	return v
+}
+
+// apiKeys parses "<id>:<secret>[:<scope>|<scope>]" entries, the secrets are not reported
+func (e *env) apiKeys(key string) []APIKey {
+	var keys []APIKey
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s entry %q, expected <id>:<secret>[:<scope>|<scope>]", key, parts[0]))
+			continue
+		}
+		apiKey := APIKey{ID: parts[0], Secret: parts[1]}
+		if len(parts) == 3 && parts[2] != "" {
+			apiKey.Scopes = strings.Split(parts[2], "|")
+		}
+		keys = append(keys, apiKey)
+	}
+	return keys
+}
+
+// encryptionKeys parses the required "<keyID>:<base64 key>" entries of 32 byte keys
+// and the active key, the first one listed by default. The keys are not reported.
+func (e *env) encryptionKeys(key, activeKey string) (map[string][]byte, string) {
+	v := e.required(key)
+	if v == "" {
+		return nil, ""
+	}
+	keys := make(map[string][]byte)
+	active := e.str(activeKey, "")
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, _ := strings.Cut(entry, ":")
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if id == "" || strings.Contains(id, ".") || err != nil || len(decoded) != 32 {
+			e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s entry %q, expected <keyID>:<base64 32 byte key>", key, id))
+			continue
+		}
+		keys[id] = decoded
+		if active == "" {
+			active = id
+		}
+	}
+	if _, ok := keys[active]; !ok && len(keys) > 0 {
+		e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s %q, not in %s", activeKey, active, key))
+	}
+	return keys, active
+}
+
+// ratePolicy parses "<limit>/<period>[,<burst>]", the burst defaults to the limit
+func (e *env) ratePolicy(key string) (RatePolicy, bool) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return RatePolicy{}, false
+	}
+	spec, burst, hasBurst := strings.Cut(v, ",")
+	limit, period, _ := strings.Cut(spec, "/")
+	var p RatePolicy
+	var err error
+	p.Limit, err = strconv.ParseInt(limit, 10, 64)
+	valid := err == nil && p.Limit > 0
+	p.Period, err = time.ParseDuration(period)
+	valid = valid && err == nil && p.Period > 0
+	p.Burst = p.Limit
+	if hasBurst {
+		p.Burst, err = strconv.ParseInt(burst, 10, 64)
+		valid = valid && err == nil && p.Burst > 0
+	}
+	if !valid {
+		e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s %q, expected <limit>/<period>[,<burst>]", key, v))
+		return RatePolicy{}, false
+	}
+	return p, true
+}
+
+// thresholds parses "<currency>:<amount>,..." replacing the defaults
+func (e *env) thresholds(key string, def map[string]int64) map[string]int64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	thresholds := make(map[string]int64)
+	for _, entry := range strings.Split(v, ",") {
+		currency, amount, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		i, err := strconv.ParseInt(amount, 10, 64)
+		if !ok || currency == "" || err != nil || i <= 0 {
+			e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s entry %q, expected <currency>:<amount>", key, entry))
+			continue
+		}
+		thresholds[strings.ToUpper(currency)] = i
+	}
+	return thresholds
+}
+
+// weights parses "<name>=<weight>,..." over the defaults
+func (e *env) weights(key string, def map[string]float64) map[string]float64 {
+	weights := make(map[string]float64, len(def))
+	for name, weight := range def {
+		weights[name] = weight
+	}
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(entry, "=")
+		f, err := strconv.ParseFloat(weight, 64)
+		if !ok || name == "" || err != nil || f < 0 {
+			e.err.Invalid = append(e.err.Invalid, fmt.Sprintf("%s entry %q, expected <error type>=<weight>", key, entry))
+			continue
+		}
+		weights[name] = f
+	}
+	return weights
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var keys = []string{
+	"SERVER_ADDR", "SHUTDOWN_TIMEOUT", "LOG_LEVEL", "LOG_FORMAT",
+	"DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME", "DB_SSLMODE",
+	"REDIS_ADDR", "REDIS_PASSWORD", "BUS_BACKEND", "BUS_ENCODING", "KAFKA_BROKER",
+	"STRIPE_ENABLED", "STRIPE_SECRET_KEY", "STRIPE_ACCOUNT_ID", "STRIPE_WEBHOOK_SECRET",
+	"RAZORPAY_ENABLED", "RAZORPAY_KEY_ID", "RAZORPAY_KEY_SECRET",
+	"GATEWAY_ENABLED", "GATEWAY_SECRET_KEY", "GATEWAY_ACCOUNT_ID", "GATEWAY_STATE_REFRESH",
+	"STRIPE_TIMEOUT", "RAZORPAY_TIMEOUT", "GATEWAY_TIMEOUT", "GATEWAY_URL", "GATEWAY_WEBHOOK_SECRET",
+	"JWT_SECRET", "JWT_PUBLIC_KEY", "JWT_JWKS_URL", "JWT_JWKS_TTL", "JWT_ISSUER", "JWT_AUDIENCE", "API_KEYS",
+	"DATA_ENCRYPTION_KEYS", "DATA_ENCRYPTION_ACTIVE_KEY",
+	"RATE_LIMIT_IP", "RATE_LIMIT_READ", "RATE_LIMIT_DEPOSIT", "RATE_LIMIT_WITHDRAWAL", "RATE_LIMIT_ADMIN", "RATE_LIMIT_WEBHOOK",
+	"RATE_LIMIT_TRUST_PROXY", "WITHDRAWAL_VELOCITY_MAX", "WITHDRAWAL_VELOCITY_WINDOW",
+	"RISK_VELOCITY_WINDOW", "RISK_VELOCITY_REVIEW", "RISK_VELOCITY_DENY", "RISK_AMOUNT_LOOKBACK", "RISK_AMOUNT_FACTOR",
+	"RISK_AMOUNT_MIN_COUNT", "RISK_AMOUNT_NEW_USER", "RISK_COUNTRY_MISMATCH", "RISK_PAYEE_COOLING", "WITHDRAWAL_APPROVAL_THRESHOLDS",
+	"GATEWAY_SCORE_BUCKET", "GATEWAY_SCORE_BUCKETS", "GATEWAY_SCORE_DECAY", "GATEWAY_SCORE_MIN_SAMPLES",
+	"GATEWAY_SCORE_LATENCY_TARGET", "GATEWAY_SCORE_PRIOR", "GATEWAY_SCORE_LATENCY_WEIGHT", "GATEWAY_SCORE_ERROR_WEIGHTS",
+	"OTEL_TRACES_EXPORTER", "OTEL_SERVICE_NAME",
+}
+
+const (
+	key1 = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=" // 32 bytes of 1
+	key2 = "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=" // 32 bytes of 2
+)
+
+func TestFromEnv(t *testing.T) {
+	database := map[string]string{"DB_HOST": "localhost", "DB_USER": "gateway", "DB_NAME": "payments", "DATA_ENCRYPTION_KEYS": "k1:" + key1, "GATEWAY_WEBHOOK_SECRET": "whsec_gw"}
+	with := func(extra map[string]string) map[string]string {
+		env := map[string]string{}
+		for k, v := range database {
+			env[k] = v
+		}
+		for k, v := range extra {
+			env[k] = v
+		}
+		return env
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, cfg *Config)
+		missing []string
+		invalid []string
+	}{
+		{
+			name: "defaults",
+			env:  database,
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, ":8080", cfg.Server.Addr)
+				assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout)
+				assert.Equal(t, "kafka", cfg.Bus.Backend)
+				assert.Equal(t, "json", cfg.Bus.Encoding)
+				assert.Equal(t, []string{"localhost:9093"}, cfg.Kafka.Brokers)
+				assert.Equal(t, "postgres://gateway:@localhost:5432/payments?sslmode=disable", cfg.Postgres.URL())
+				assert.False(t, cfg.Stripe.Enabled)
+				assert.False(t, cfg.Razorpay.Enabled)
+				assert.True(t, cfg.DefaultGateway.Enabled)
+				assert.Equal(t, "whsec_gw", cfg.DefaultGateway.WebhookSecret)
+				assert.Empty(t, cfg.Auth.APIKeys)
+				assert.Equal(t, "k1", cfg.Encryption.ActiveKey)
+				assert.Empty(t, cfg.RateLimit.Policies)
+				assert.Equal(t, Velocity{Max: 5, Window: time.Hour}, cfg.RateLimit.WithdrawalVelocity)
+				assert.Equal(t, DefaultRisk(), cfg.Risk)
+				assert.Equal(t, DefaultGatewayScore(), cfg.GatewayScore)
+				assert.Equal(t, Tracing{Exporter: "none", ServiceName: "payment-gateway"}, cfg.Tracing)
+			},
+		},
+		{
+			name: "overrides",
+			env: with(map[string]string{
+				"API_KEYS":                       "billing:s3cret, ops:0ps:admin|internal",
+				"DATA_ENCRYPTION_KEYS":           "k2:" + key2 + ",k1:" + key1,
+				"RATE_LIMIT_DEPOSIT":             "20/1m,5",
+				"RATE_LIMIT_READ":                "100/1h",
+				"WITHDRAWAL_VELOCITY_MAX":        "3",
+				"RISK_PAYEE_COOLING":             "0s",
+				"RISK_COUNTRY_MISMATCH":          "deny",
+				"WITHDRAWAL_APPROVAL_THRESHOLDS": "usd:200000",
+				"GATEWAY_SCORE_ERROR_WEIGHTS":    "card_declined=0.5,fraud=2",
+				"OTEL_TRACES_EXPORTER":           "otlp",
+			}),
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []APIKey{{ID: "billing", Secret: "s3cret"}, {ID: "ops", Secret: "0ps", Scopes: []string{"admin", "internal"}}}, cfg.Auth.APIKeys)
+				assert.Equal(t, "k2", cfg.Encryption.ActiveKey)
+				assert.Len(t, cfg.Encryption.Keys, 2)
+				assert.Equal(t, map[string]RatePolicy{
+					"deposit": {Limit: 20, Period: time.Minute, Burst: 5},
+					"read":    {Limit: 100, Period: time.Hour, Burst: 100},
+				}, cfg.RateLimit.Policies)
+				assert.Equal(t, int64(3), cfg.RateLimit.WithdrawalVelocity.Max)
+				assert.Zero(t, cfg.Risk.PayeeCooling)
+				assert.Equal(t, "deny", cfg.Risk.CountryMismatch)
+				assert.Equal(t, map[string]int64{"USD": 200000}, cfg.Risk.ApprovalThresholds)
+				assert.Equal(t, 0.5, cfg.GatewayScore.ErrorWeights["card_declined"])
+				assert.Equal(t, 2.0, cfg.GatewayScore.ErrorWeights["fraud"])
+				assert.Equal(t, 1.5, cfg.GatewayScore.ErrorWeights["timeout"])
+				assert.Equal(t, "otlp", cfg.Tracing.Exporter)
+			},
+		},
+		{
+			name: "gateway enabled by its keys",
+			env:  with(map[string]string{"STRIPE_SECRET_KEY": "sk_test_1", "STRIPE_ACCOUNT_ID": "acct_1", "STRIPE_WEBHOOK_SECRET": "whsec_1", "KAFKA_BROKER": "kafka-1:9092, kafka-2:9092"}),
+			check: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.Stripe.Enabled)
+				assert.Equal(t, "sk_test_1", cfg.Stripe.SecretKey)
+				assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, cfg.Kafka.Brokers)
+			},
+		},
+		{
+			name: "disabled gateway ignores its keys",
+			env:  with(map[string]string{"STRIPE_ENABLED": "false", "STRIPE_SECRET_KEY": "sk_test_1"}),
+			check: func(t *testing.T, cfg *Config) {
+				assert.False(t, cfg.Stripe.Enabled)
+				assert.Empty(t, cfg.Stripe.SecretKey)
+			},
+		},
+		{
+			name:    "every missing key is listed",
+			env:     map[string]string{"STRIPE_SECRET_KEY": "sk_test_1", "RAZORPAY_ENABLED": "true"},
+			missing: []string{"DB_HOST", "DB_USER", "DB_NAME", "STRIPE_ACCOUNT_ID", "STRIPE_WEBHOOK_SECRET", "RAZORPAY_KEY_ID", "RAZORPAY_KEY_SECRET", "GATEWAY_WEBHOOK_SECRET", "DATA_ENCRYPTION_KEYS"},
+		},
+		{
+			name:  "disabled default gateway needs no webhook secret",
+			env:   with(map[string]string{"GATEWAY_ENABLED": "false", "GATEWAY_WEBHOOK_SECRET": ""}),
+			check: func(t *testing.T, cfg *Config) { assert.False(t, cfg.DefaultGateway.Enabled) },
+		},
+		{
+			name:    "invalid values",
+			env:     with(map[string]string{"SHUTDOWN_TIMEOUT": "soon", "LOG_FORMAT": "xml", "BUS_BACKEND": "nats", "BUS_ENCODING": "avro", "GATEWAY_ENABLED": "maybe"}),
+			invalid: []string{`SHUTDOWN_TIMEOUT "soon"`, `LOG_FORMAT "xml", expected json, text`, `BUS_BACKEND "nats", expected kafka, redis, memory`, `BUS_ENCODING "avro", expected json, protobuf`, `GATEWAY_ENABLED "maybe"`},
+		},
+		{
+			name: "invalid security values do not leak secrets",
+			env: with(map[string]string{
+				"JWT_PUBLIC_KEY":             "not a key",
+				"API_KEYS":                   "billing:s3cret,ops",
+				"DATA_ENCRYPTION_KEYS":       "k1:" + key1 + ",k2:AQEBAQEBAQEBAQEBAQEBAQ==",
+				"DATA_ENCRYPTION_ACTIVE_KEY": "k3",
+			}),
+			invalid: []string{
+				`JWT_PUBLIC_KEY, expected a PEM encoded RSA public key`,
+				`API_KEYS entry "ops", expected <id>:<secret>[:<scope>|<scope>]`,
+				`DATA_ENCRYPTION_KEYS entry "k2", expected <keyID>:<base64 32 byte key>`,
+				`DATA_ENCRYPTION_ACTIVE_KEY "k3", not in DATA_ENCRYPTION_KEYS`,
+			},
+		},
+		{
+			name: "invalid limits and thresholds",
+			env: with(map[string]string{
+				"RATE_LIMIT_DEPOSIT":             "0/1m",
+				"WITHDRAWAL_VELOCITY_MAX":        "0",
+				"RISK_AMOUNT_FACTOR":             "1",
+				"RISK_COUNTRY_MISMATCH":          "block",
+				"RISK_PAYEE_COOLING":             "-1h",
+				"WITHDRAWAL_APPROVAL_THRESHOLDS": "USD:100,GBP",
+				"GATEWAY_SCORE_DECAY":            "0",
+				"GATEWAY_SCORE_ERROR_WEIGHTS":    "timeout=-1",
+				"OTEL_TRACES_EXPORTER":           "jaeger",
+			}),
+			invalid: []string{
+				`WITHDRAWAL_VELOCITY_MAX "0"`,
+				`RATE_LIMIT_DEPOSIT "0/1m", expected <limit>/<period>[,<burst>]`,
+				`RISK_AMOUNT_FACTOR "1"`,
+				`RISK_COUNTRY_MISMATCH "block", expected allow, review, deny`,
+				`RISK_PAYEE_COOLING "-1h"`,
+				`WITHDRAWAL_APPROVAL_THRESHOLDS entry "GBP", expected <currency>:<amount>`,
+				`GATEWAY_SCORE_DECAY "0"`,
+				`GATEWAY_SCORE_ERROR_WEIGHTS entry "timeout=-1", expected <error type>=<weight>`,
+				`OTEL_TRACES_EXPORTER "jaeger", expected otlp, stdout, none`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range keys {
+				t.Setenv(key, tt.env[key])
+			}
+
+			cfg, err := FromEnv()
+			if tt.missing != nil || tt.invalid != nil {
+				var cfgErr *Error
+				require.ErrorAs(t, err, &cfgErr)
+				assert.Equal(t, tt.missing, cfgErr.Missing)
+				assert.Equal(t, tt.invalid, cfgErr.Invalid)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	database "payment-gateway/db/db"
+	"payment-gateway/internal/models"
+	"sync"
+	"time"
+)
+
+// memoryDB stands in for Postgres. It keeps the transactions and the ledger like
+// CreateTransaction does, a transaction is posted once per order and type. The
+// embedded interfaces are nil, the flows under test do not reach them.
+type memoryDB struct {
+	database.IAdminDB
+	database.IRiskDB
+
+	gateways map[int]database.Gateway
+	mappings []database.GatewayCountry
+
+	mu           sync.Mutex
+	transactions []database.Transaction
+	ledger       map[string]float64 // Balance by user and currency
+	postings     int                // CreateTransaction calls, duplicates included
+}
+
+func newMemoryDB() *memoryDB {
+	return &memoryDB{
+		gateways: map[int]database.Gateway{
+			1: {ID: 1, Name: "STRIPE", Enabled: true},
+			7: {ID: 7, Name: "DEFAULT_GATEWAY", Enabled: true},
+			8: {ID: 8, Name: "RETIRED_GATEWAY"},
+		},
+		mappings: []database.GatewayCountry{
+			{GatewayID: 1, GatewayName: "STRIPE", CountryID: 3},
+			{GatewayID: 7, GatewayName: "DEFAULT_GATEWAY", CountryID: 1, Enabled: true},
+			{GatewayID: 7, GatewayName: "DEFAULT_GATEWAY", CountryID: 3, Enabled: true},
+			{GatewayID: 8, GatewayName: "RETIRED_GATEWAY", CountryID: 3, Enabled: true},
+		},
+		ledger: make(map[string]float64),
+	}
+}
+
+func ledgerKey(userID int, currency string) string {
+	return fmt.Sprintf("%d:%s", userID, currency)
+}
+
+// credit adds to the balance of a user
+func (m *memoryDB) credit(userID int, currency string, amount float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ledger[ledgerKey(userID, currency)] += amount
+}
+
+func (m *memoryDB) balance(userID int, currency string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ledger[ledgerKey(userID, currency)]
+}
+
+// transactionsOf returns the posted transactions of an order
+func (m *memoryDB) transactionsOf(orderID string) []database.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []database.Transaction
+	for _, transaction := range m.transactions {
+		if transaction.OrderID == orderID {
+			result = append(result, transaction)
+		}
+	}
+	return result
+}
+
+func (m *memoryDB) postingCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.postings
+}
+
+func (m *memoryDB) Ping(ctx context.Context) error { return nil }
+func (m *memoryDB) Close() error                   { return nil }
+
+func (m *memoryDB) CheckUserBalance(ctx context.Context, userID int, currency string, amount float64, holdID int64) (bool, float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	balance, ok := m.ledger[ledgerKey(userID, currency)]
+	if !ok {
+		return false, 0, fmt.Errorf("no balance found for user %d in currency %s", userID, currency)
+	}
+	return balance >= amount, balance, nil
+}
+
+func (m *memoryDB) GetSupportedGatewaysByCountries(ctx context.Context, countryID string) ([]models.Gateway, error) {
+	return nil, nil
+}
+
+func (m *memoryDB) GetGateway(ctx context.Context, gatewayID int) (database.Gateway, error) {
+	gateway, ok := m.gateways[gatewayID]
+	if !ok {
+		return database.Gateway{}, database.ErrNotFound
+	}
+	return gateway, nil
+}
+
+func (m *memoryDB) GetGatewayCountry(ctx context.Context, gatewayID, countryID int) (database.GatewayCountry, error) {
+	for _, mapping := range m.mappings {
+		if mapping.GatewayID == gatewayID && mapping.CountryID == countryID {
+			return mapping, nil
+		}
+	}
+	return database.GatewayCountry{}, database.ErrNotFound
+}
+
+func (m *memoryDB) CreateTransaction(ctx context.Context, transaction database.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.postings++
+	for _, posted := range m.transactions {
+		if posted.OrderID == transaction.OrderID && posted.Type == transaction.Type {
+			return nil
+		}
+	}
+	transaction.ID = len(m.transactions) + 1
+	transaction.CreatedAt = time.Now()
+	m.transactions = append(m.transactions, transaction)
+	m.ledger[ledgerKey(transaction.UserID, transaction.Currency)] += transaction.Amount
+	return nil
+}
+
+func (m *memoryDB) GetRoutingRules(ctx context.Context) ([]models.RoutingRule, error) {
+	return nil, nil
+}
+
+func (m *memoryDB) GetUserSegment(ctx context.Context, userID string) (string, error) {
+	return "", nil
+}
+
+// GetGatewayCapabilitiesByCountry lists the default gateway in USD, country 3 only
+func (m *memoryDB) GetGatewayCapabilitiesByCountry(ctx context.Context, countryID string) ([]models.GatewayCapability, error) {
+	if countryID != "3" {
+		return nil, nil
+	}
+	return []models.GatewayCapability{{
+		GatewayID: "7", CountryID: "3", Currency: "USD", MinAmount: 100,
+		SupportsDeposit: true, SupportsWithdrawal: true, PayoutMethods: []string{"standard"},
+	}}, nil
+}
+
+// The risk rules see users without history
+
+func (m *memoryDB) CountTransactions(ctx context.Context, userID, txType string, since time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryDB) GetTransactionStats(ctx context.Context, userID, txType, currency string, since time.Time) (models.TransactionStats, error) {
+	return models.TransactionStats{}, nil
+}
+
+func (m *memoryDB) GetUserCountry(ctx context.Context, userID string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,162 @@
+package e2e
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"payment-gateway/internal/psp/defaultgateway"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeposit(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string // The cents pick the simulator scenario
+		status   string
+		postings int     // Deliveries of the final webhook reaching the ledger, duplicates are skipped
+		balance  float64 // Ledger balance once every webhook is consumed
+	}{
+		{name: "success", amount: "5000", status: "success", postings: 1, balance: 50},
+		{name: "declined", amount: "5001", status: "failed"},
+		{name: "delayed", amount: "5002", status: "success", postings: 1, balance: 50.02},
+		{name: "duplicate webhook", amount: "5003", status: "success", postings: 1, balance: 50.03},
+		{name: "out of order", amount: "5004", status: "success", postings: 1, balance: 50.04},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			code, data := h.post("/deposit", map[string]string{
+				"amount": tt.amount, "currency": "USD", "user_id": "1",
+				"gateway_id": "7", "gateway_name": "DEFAULT_GATEWAY", "country_id": "3",
+			})
+			require.Equal(t, http.StatusOK, code)
+			orderID, _ := data["order_id"].(string)
+			require.NotEmpty(t, orderID)
+
+			key := fmt.Sprintf("deposit:userid:1:orderid:%s", orderID)
+			require.Eventually(t, func() bool {
+				return h.status(key) == tt.status && h.db.postingCount() == tt.postings
+			}, 2*time.Second, 5*time.Millisecond, "status %q", h.status(key))
+
+			if tt.postings == 0 {
+				assert.Empty(t, h.db.transactionsOf(orderID))
+				return
+			}
+			transactions := h.db.transactionsOf(orderID)
+			require.Len(t, transactions, 1)
+			assert.Equal(t, "credit", transactions[0].Type)
+			assert.Equal(t, tt.balance, h.db.balance(1, "usd"))
+		})
+	}
+}
+
+func TestWithdrawal(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		code    int
+		balance float64 // Ledger balance once every webhook is consumed
+	}{
+		{name: "paid", amount: 2500, code: http.StatusOK, balance: 75},
+		{name: "insufficient balance", amount: 50000, code: http.StatusBadRequest, balance: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			h.db.credit(2, "usd", 100)
+
+			code, data := h.post("/withdrawal", map[string]interface{}{
+				"amount": tt.amount, "currency": "USD", "description": "Payout", "method": "standard",
+				"user_id": "2", "gateway_id": "7", "gateway_name": "DEFAULT_GATEWAY", "country_id": "3",
+				"bank_details": map[string]string{
+					"country": "US", "currency": "usd", "account_number": "000123456789", "routing_number": "110000000",
+					"account_holder_name": "Jane Doe", "account_holder_type": "individual",
+				},
+			})
+			require.Equal(t, tt.code, code)
+			if tt.code != http.StatusOK {
+				// Refused before reaching the gateway, no payout and no webhook
+				time.Sleep(20 * time.Millisecond)
+				assert.Zero(t, h.db.postingCount())
+				assert.Equal(t, tt.balance, h.db.balance(2, "usd"))
+				return
+			}
+
+			payoutID, _ := data["orderid"].(string)
+			require.NotEmpty(t, payoutID)
+			key := fmt.Sprintf("withdrawal:userid:2:payoutid:%s", payoutID)
+			require.Eventually(t, func() bool {
+				return h.status(key) == "completed" && h.db.postingCount() == 1
+			}, 2*time.Second, 5*time.Millisecond, "status %q", h.status(key))
+			transactions := h.db.transactionsOf(payoutID)
+			require.Len(t, transactions, 1)
+			assert.Equal(t, "debit", transactions[0].Type)
+			assert.Equal(t, tt.balance, h.db.balance(2, "usd"))
+		})
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	payload := []byte(`{"id":"pi_forged","type":"payment_intent.succeeded","amount":100000,"currency":"usd",` +
+		`"data":{"metadata":{"user_id":"1","gateway_id":"7","country_id":"3"}}}`)
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{name: "unsigned"},
+		{name: "signed with another secret", signature: defaultgateway.Sign(payload, "whsec_other", time.Now())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			req, err := http.NewRequest(http.MethodPost, h.url+"/webhook/default-gateway", bytes.NewReader(payload))
+			require.NoError(t, err)
+			if tt.signature != "" {
+				req.Header.Set(defaultgateway.SignatureHeader, tt.signature)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			time.Sleep(20 * time.Millisecond)
+			assert.Zero(t, h.db.postingCount())
+		})
+	}
+}
+
+func TestDepositGatewayChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		gatewayID   string
+		gatewayName string
+		countryID   string
+		code        int
+	}{
+		{name: "supported", gatewayID: "7", gatewayName: "DEFAULT_GATEWAY", code: http.StatusOK},
+		{name: "name of another gateway", gatewayID: "7", gatewayName: "STRIPE", code: http.StatusBadRequest},
+		{name: "unknown gateway", gatewayID: "99", gatewayName: "DEFAULT_GATEWAY", code: http.StatusBadRequest},
+		{name: "invalid gateway ID", gatewayID: "seven", gatewayName: "DEFAULT_GATEWAY", code: http.StatusBadRequest},
+		{name: "no capabilities in the country", gatewayID: "7", gatewayName: "DEFAULT_GATEWAY", countryID: "1", code: http.StatusBadRequest},
+		{name: "disabled in the country", gatewayID: "1", gatewayName: "STRIPE", code: http.StatusBadRequest},
+		{name: "disabled globally", gatewayID: "8", gatewayName: "RETIRED_GATEWAY", code: http.StatusBadRequest},
+		{name: "not mapped to the country", gatewayID: "7", gatewayName: "DEFAULT_GATEWAY", countryID: "4", code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			if tt.countryID == "" {
+				tt.countryID = "3"
+			}
+			code, _ := h.post("/deposit", map[string]string{
+				"amount": "5000", "currency": "USD", "user_id": "1",
+				"gateway_id": tt.gatewayID, "gateway_name": tt.gatewayName, "country_id": tt.countryID,
+			})
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
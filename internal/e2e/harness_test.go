@@ -0,0 +1,142 @@
+// Package e2e runs the deposit and withdrawal flows end to end: the API calls the
+// default gateway simulator, whose signed webhooks go through the bus as payment events
+// to the consumer posting the ledger. Postgres, Redis and the bus are in-process stand-ins.
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-gateway/db"
+	"payment-gateway/db/redis"
+	"payment-gateway/internal/api"
+	"payment-gateway/internal/auth"
+	"payment-gateway/internal/beneficiary"
+	"payment-gateway/internal/bus"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/health"
+	"payment-gateway/internal/psp"
+	"payment-gateway/internal/psp/defaultgateway"
+	"payment-gateway/internal/psp/defaultgateway/simulator"
+	"payment-gateway/internal/ratelimit"
+	"payment-gateway/internal/risk"
+	"payment-gateway/internal/vault"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	apiKeyID      = "e2e"
+	apiKeySecret  = "e2e-secret"
+	webhookSecret = "whsec_e2e"
+)
+
+// harness is the service wired through SetupRouter with the default gateway
+type harness struct {
+	t     *testing.T
+	url   string
+	db    *memoryDB
+	redis *miniredis.Miniredis
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	redisServer := miniredis.RunT(t)
+	redisClient, err := redis.Init(config.Redis{Addr: redisServer.Addr()}, config.DefaultGatewayScore())
+	require.NoError(t, err)
+	t.Cleanup(func() { redisClient.Close() })
+
+	store := newMemoryDB()
+	database := &db.DB{DB: store, Admin: store, Risk: store, Redis: redisClient}
+
+	b := bus.NewMemory()
+	t.Cleanup(func() { b.Close() })
+
+	// The service and the simulator call each other, the service address is known before it starts
+	server := httptest.NewUnstartedServer(nil)
+	t.Cleanup(server.Close)
+	sim, err := simulator.New(simulator.Config{
+		WebhookURL:    "http://" + server.Listener.Addr().String() + "/webhook/default-gateway",
+		WebhookSecret: webhookSecret,
+		Scenario:      simulator.Success,
+		Delay:         time.Millisecond,
+		LongDelay:     10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	t.Cleanup(sim.Close)
+	gateway := httptest.NewServer(sim.Handler())
+	t.Cleanup(gateway.Close)
+
+	cfg := &config.Config{
+		DefaultGateway: config.DefaultGateway{
+			Enabled:       true,
+			URL:           gateway.URL,
+			WebhookSecret: webhookSecret,
+			Timeout:       5 * time.Second,
+		},
+		RateLimit: config.RateLimit{WithdrawalVelocity: config.Velocity{Max: 5, Window: time.Hour}},
+		Risk:      config.DefaultRisk(),
+	}
+	// Raw bank details are new payees, pay them without the cooling period review
+	cfg.Risk.PayeeCooling = 0
+	// Protobuf, the JSON encoding is covered by the events tests
+	encoder, err := events.NewEncoder(events.EncodingProtobuf)
+	require.NoError(t, err)
+	gateways := psp.Init([]psp.IPSP{defaultgateway.Init(cfg.DefaultGateway, events.NewPublisher(b, encoder))})
+	go events.Consume(b, database)
+
+	tokens := vault.New(nil, nil)
+	router := api.SetupRouter(
+		gateways,
+		database,
+		auth.New(nil, auth.NewAPIKeyVerifier([]auth.APIKey{{ID: apiKeyID, Secret: apiKeySecret, Scopes: []string{auth.ScopeInternal}}}, redisClient)),
+		ratelimit.New(redisClient, ratelimit.DefaultPolicies(), false),
+		tokens,
+		beneficiary.New(nil, tokens),
+		risk.NewQueue(store, nil),
+		health.New(time.Second),
+		cfg,
+	)
+	server.Config.Handler = router
+	server.Start()
+
+	return &harness{t: t, url: server.URL, db: store, redis: redisServer}
+}
+
+// post sends a request signed with the internal API key and decodes the data of the answer
+func (h *harness) post(path string, body interface{}) (int, map[string]interface{}) {
+	h.t.Helper()
+	payload, err := json.Marshal(body)
+	require.NoError(h.t, err)
+	req, err := http.NewRequest(http.MethodPost, h.url+path, bytes.NewReader(payload))
+	require.NoError(h.t, err)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(auth.HeaderAPIKey, apiKeyID)
+	req.Header.Set(auth.HeaderTimestamp, timestamp)
+	req.Header.Set(auth.HeaderSignature, auth.Sign(apiKeySecret, timestamp, http.MethodPost, path, payload))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(h.t, err)
+	defer resp.Body.Close()
+	var res struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(h.t, json.NewDecoder(resp.Body).Decode(&res))
+	}
+	return resp.StatusCode, res.Data
+}
+
+// status returns the status stored in Redis under key
+func (h *harness) status(key string) string {
+	if !h.redis.Exists(key) {
+		return ""
+	}
+	return h.redis.HGet(key, "status")
+}
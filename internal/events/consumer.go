@@ -0,0 +1,326 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"payment-gateway/db"
+	database "payment-gateway/db/db"
+	"payment-gateway/internal/bus"
+	"payment-gateway/internal/metrics"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/risk"
+	"strconv"
+	"time"
+)
+
+// Consume handles the payment events until the bus consumers stop
+func Consume(b bus.Bus, db *db.DB) error {
+	return b.Subscribe(Topic, Handler(db))
+}
+
+// Handler decodes the messages of Topic for Handle, whatever their encoding. Events
+// that do not decode or validate are permanent failures, the others are retried.
+func Handler(db *db.DB) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		e, err := Decode(msg)
+		if err == nil {
+			err = e.Validate()
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "invalid payment event", "topic", msg.Topic, "offset", msg.Offset, "error", err)
+			return bus.Permanent(err)
+		}
+		slog.InfoContext(ctx, "payment event received", "gateway", e.Gateway, "event_id", e.EventID, "event_type", e.Type, "offset", msg.Offset)
+
+		err = Handle(ctx, e, db)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to process payment event", "gateway", e.Gateway, "event_id", e.EventID, "error", err)
+		}
+		return err
+	}
+}
+
+// Handle updates the payment status and the ledger for e. Events already handled and
+// events behind the stored status are skipped: gateways resend their events and do
+// not deliver them in order.
+func Handle(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	handle, ok := handlers[e.Type]
+	if !ok {
+		return bus.Permanent(fmt.Errorf("unknown event type %q", e.Type))
+	}
+
+	if err := handle(ctx, e, db); err != nil {
+		return err
+	}
+	// Until this is recorded a redelivery of e is handled again, setStatus lets it through
+	if err := db.Redis.StatusHandled(ctx, statusKey(e), eventKey(e)); err != nil {
+		slog.WarnContext(ctx, "failed to record handled payment event", "event_id", e.EventID, "error", err)
+	}
+	return nil
+}
+
+// handlers handle the events of each type
+var handlers = map[models.PaymentEventType]func(context.Context, models.PaymentEvent, *db.DB) error{
+	// Deposit handlers
+	models.DepositCreated:   handleDepositCreated,
+	models.DepositSucceeded: handleDepositSucceeded,
+	models.DepositFailed:    handleDepositFailed,
+
+	// Withdrawal handlers
+	models.PayoutCreated:  handlePayoutCreated,
+	models.PayoutPaid:     handlePayoutPaid,
+	models.PayoutFailed:   handlePayoutFailed,
+	models.PayoutCanceled: handlePayoutCanceled,
+}
+
+// ----- Deposit Event Handlers -----
+
+// handleDepositCreated records a deposit the gateway accepted
+func handleDepositCreated(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	if ok, err := setStatus(ctx, e, db, "pending", nil); !ok || err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "payment pending", "order_id", e.OrderID)
+	return nil
+}
+
+// handleDepositSucceeded records a successful deposit and credits the user
+func handleDepositSucceeded(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	metadata, err := validateMetadata(e.Metadata)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid event metadata", "event_id", e.EventID, "error", err)
+		return err
+	}
+
+	if ok, err := setStatus(ctx, e, db, "success", nil); !ok || err != nil {
+		return err
+	}
+	recordOutcome(ctx, e, db, models.GatewayOutcome{Success: true})
+
+	err = db.DB.CreateTransaction(ctx, transaction(e, metadata, "credit", float64(e.Amount)/100))
+	metrics.LedgerPosting("credit", err)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to store deposit transaction", "error", err)
+		return fmt.Errorf("failed to store deposit transaction data in db: %v", err)
+	}
+
+	metrics.RecordPayment(e.Gateway, "deposit", metrics.OutcomeSucceeded)
+	slog.InfoContext(ctx, "payment succeeded", "order_id", e.OrderID, "amount", e.Amount, "currency", e.Currency)
+	return nil
+}
+
+// handleDepositFailed records a declined deposit
+func handleDepositFailed(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	if _, err := validateMetadata(e.Metadata); err != nil {
+		slog.ErrorContext(ctx, "invalid event metadata", "event_id", e.EventID, "error", err)
+		return err
+	}
+
+	if ok, err := setStatus(ctx, e, db, "failed", nil); !ok || err != nil {
+		return err
+	}
+	recordOutcome(ctx, e, db, models.GatewayOutcome{ErrorType: e.FailureCode})
+
+	metrics.RecordPayment(e.Gateway, "deposit", metrics.OutcomeFailed)
+	slog.InfoContext(ctx, "payment failed", "order_id", e.OrderID, "amount", e.Amount, "currency", e.Currency, "failure_code", e.FailureCode)
+	return nil
+}
+
+// ----- Withdrawal Event Handlers -----
+
+// handlePayoutCreated records a payout the gateway accepted
+func handlePayoutCreated(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	data := map[string]interface{}{
+		"created_at": time.Now().Unix(),
+		"amount":     e.Amount,
+		"currency":   e.Currency,
+	}
+	if ok, err := setStatus(ctx, e, db, "created", data); !ok || err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "payout created", "order_id", e.OrderID, "amount", e.Amount, "currency", e.Currency)
+	return nil
+}
+
+// handlePayoutPaid records a paid payout and debits the user
+func handlePayoutPaid(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	metadata, err := validateMetadata(e.Metadata)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid event metadata", "event_id", e.EventID, "error", err)
+		return err
+	}
+
+	data := map[string]interface{}{
+		"completed_at": time.Now().Unix(),
+	}
+	if ok, err := setStatus(ctx, e, db, "completed", data); !ok || err != nil {
+		return err
+	}
+	recordOutcome(ctx, e, db, models.GatewayOutcome{Success: true})
+
+	err = db.DB.CreateTransaction(ctx, transaction(e, metadata, "debit", -float64(e.Amount)/100))
+	metrics.LedgerPosting("debit", err)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to store withdrawal transaction", "error", err)
+		return fmt.Errorf("failed to store withdrawal transaction data in db: %v", err)
+	}
+	if err := releaseHold(ctx, e, db); err != nil {
+		return err
+	}
+
+	metrics.RecordPayment(e.Gateway, "withdrawal", metrics.OutcomeSucceeded)
+	slog.InfoContext(ctx, "payout paid", "order_id", e.OrderID, "amount", e.Amount, "currency", e.Currency)
+	return nil
+}
+
+// handlePayoutFailed records a payout the gateway could not pay
+func handlePayoutFailed(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	if _, err := validateMetadata(e.Metadata); err != nil {
+		slog.ErrorContext(ctx, "invalid event metadata", "event_id", e.EventID, "error", err)
+		return err
+	}
+
+	data := map[string]interface{}{
+		"failed_at":    time.Now().Unix(),
+		"failure_code": e.FailureCode,
+	}
+	if ok, err := setStatus(ctx, e, db, "failed", data); !ok || err != nil {
+		return err
+	}
+	recordOutcome(ctx, e, db, models.GatewayOutcome{ErrorType: e.FailureCode})
+	if err := releaseHold(ctx, e, db); err != nil {
+		return err
+	}
+
+	metrics.RecordPayment(e.Gateway, "withdrawal", metrics.OutcomeFailed)
+	slog.InfoContext(ctx, "payout failed", "order_id", e.OrderID, "amount", e.Amount, "currency", e.Currency, "failure_code", e.FailureCode)
+	return nil
+}
+
+// handlePayoutCanceled records a payout canceled before it was paid
+func handlePayoutCanceled(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	data := map[string]interface{}{
+		"canceled_at": time.Now().Unix(),
+	}
+	if ok, err := setStatus(ctx, e, db, "canceled", data); !ok || err != nil {
+		return err
+	}
+	if err := releaseHold(ctx, e, db); err != nil {
+		return err
+	}
+
+	metrics.RecordPayment(e.Gateway, "withdrawal", metrics.OutcomeCanceled)
+	slog.InfoContext(ctx, "payout canceled", "order_id", e.OrderID, "amount", e.Amount, "currency", e.Currency)
+	return nil
+}
+
+// statusRanks orders the statuses a payment moves through, final statuses share the
+// highest rank
+var statusRanks = map[string]int{
+	"pending":   1,
+	"created":   1,
+	"success":   2,
+	"failed":    2,
+	"completed": 2,
+	"canceled":  2,
+}
+
+// setStatus stores status and data under the Redis key of the deposit or withdrawal
+// status. It returns false when e is a duplicate or behind the stored status, the
+// caller then skips it.
+func setStatus(ctx context.Context, e models.PaymentEvent, db *db.DB, status string, data map[string]interface{}) (bool, error) {
+	values := map[string]interface{}{"status": status}
+	for field, value := range data {
+		values[field] = value
+	}
+	key := statusKey(e)
+	ok, err := db.Redis.SetStatus(ctx, key, eventKey(e), statusRanks[status], values)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to store payment status in redis", "key", key, "error", err)
+		return false, fmt.Errorf("failed to store data in redis: %v", err)
+	}
+	if !ok {
+		slog.InfoContext(ctx, "payment event skipped, already handled or behind the stored status",
+			"event_id", e.EventID, "event_type", e.Type, "order_id", e.OrderID)
+	}
+	return ok, nil
+}
+
+// statusKey is the Redis key of the deposit or withdrawal status e updates
+func statusKey(e models.PaymentEvent) string {
+	if e.Type.Deposit() {
+		return fmt.Sprintf("deposit:userid:%s:orderid:%s", e.Metadata["user_id"], e.OrderID)
+	}
+	return fmt.Sprintf("withdrawal:userid:%s:payoutid:%s", e.Metadata["user_id"], e.OrderID)
+}
+
+// eventKey identifies e among the events of its payment. The default gateway names
+// every event of a payment after it, so the ID alone is not unique.
+func eventKey(e models.PaymentEvent) string {
+	return e.EventID + ":" + string(e.Type)
+}
+
+// recordOutcome feeds the gateway score. A failure only leaves the score behind, so
+// it is logged rather than retried.
+func recordOutcome(ctx context.Context, e models.PaymentEvent, db *db.DB, outcome models.GatewayOutcome) {
+	if err := db.Redis.RecordGatewayOutcome(ctx, e.Metadata["country_id"], e.Metadata["gateway_id"], outcome); err != nil {
+		slog.WarnContext(ctx, "failed to record gateway outcome", "gateway", e.Gateway, "event_id", e.EventID, "error", err)
+	}
+}
+
+// releaseHold releases the funds held for a payout approved from review once the
+// debit or the failure accounts for them. A hold that is not the user's in the
+// payout currency stays held.
+func releaseHold(ctx context.Context, e models.PaymentEvent, db *db.DB) error {
+	value, ok := e.Metadata["hold_id"]
+	if !ok {
+		return nil
+	}
+	holdID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return bus.Permanent(fmt.Errorf("invalid hold_id format: %v", err))
+	}
+	err = db.Risk.ReleaseHold(ctx, holdID, e.Metadata["user_id"], e.Currency)
+	if errors.Is(err, risk.ErrHoldNotFound) {
+		slog.ErrorContext(ctx, "payout hold not held for its user, left held", "hold_id", holdID,
+			"user_id", e.Metadata["user_id"], "currency", e.Currency)
+		return nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to release held funds", "hold_id", holdID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// transaction is the ledger posting of e
+func transaction(e models.PaymentEvent, metadata map[string]int, kind string, amount float64) database.Transaction {
+	return database.Transaction{
+		OrderID:   e.OrderID,
+		Amount:    amount,
+		Status:    "success",
+		Type:      kind,
+		GatewayID: metadata["gateway_id"],
+		CountryID: metadata["country_id"],
+		UserID:    metadata["user_id"],
+		Currency:  e.Currency,
+	}
+}
+
+// validateMetadata converts the IDs the service sets on every payment to integers,
+// malformed IDs are permanent failures
+func validateMetadata(metadata map[string]string) (map[string]int, error) {
+	ids := make(map[string]int)
+	for _, name := range []string{"gateway_id", "country_id", "user_id"} {
+		id, err := strconv.Atoi(metadata[name])
+		if err != nil {
+			return ids, bus.Permanent(fmt.Errorf("invalid %s format: %v", name, err))
+		}
+		ids[name] = id
+	}
+	return ids, nil
+}
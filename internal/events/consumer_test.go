@@ -0,0 +1,384 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"payment-gateway/db"
+	database "payment-gateway/db/db"
+	"payment-gateway/internal/bus"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/risk"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDB implements IDB interface for testing, and the holds of IRiskDB
+type MockDB struct {
+	mock.Mock
+	database.IRiskDB
+}
+
+func (m *MockDB) ReleaseHold(ctx context.Context, holdID int64, userID, currency string) error {
+	args := m.Called(holdID, userID, currency)
+	return args.Error(0)
+}
+
+func (m *MockDB) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockDB) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockDB) CheckUserBalance(ctx context.Context, userID int, currency string, amount float64, holdID int64) (bool, float64, error) {
+	args := m.Called(userID, currency, amount)
+	return args.Bool(0), args.Get(1).(float64), args.Error(2)
+}
+
+func (m *MockDB) GetSupportedGatewaysByCountries(ctx context.Context, countryID string) ([]models.Gateway, error) {
+	args := m.Called(countryID)
+	return args.Get(0).([]models.Gateway), args.Error(1)
+}
+
+func (m *MockDB) GetGateway(ctx context.Context, gatewayID int) (database.Gateway, error) {
+	args := m.Called(gatewayID)
+	return args.Get(0).(database.Gateway), args.Error(1)
+}
+
+func (m *MockDB) GetGatewayCountry(ctx context.Context, gatewayID, countryID int) (database.GatewayCountry, error) {
+	args := m.Called(gatewayID, countryID)
+	return args.Get(0).(database.GatewayCountry), args.Error(1)
+}
+
+func (m *MockDB) CreateTransaction(ctx context.Context, transaction database.Transaction) error {
+	args := m.Called(transaction)
+	return args.Error(0)
+}
+
+func (m *MockDB) GetRoutingRules(ctx context.Context) ([]models.RoutingRule, error) {
+	args := m.Called()
+	return args.Get(0).([]models.RoutingRule), args.Error(1)
+}
+
+func (m *MockDB) GetUserSegment(ctx context.Context, userID string) (string, error) {
+	args := m.Called(userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockDB) GetGatewayCapabilitiesByCountry(ctx context.Context, countryID string) ([]models.GatewayCapability, error) {
+	args := m.Called(countryID)
+	return args.Get(0).([]models.GatewayCapability), args.Error(1)
+}
+
+// MockRedis implements IRedis interface for testing
+type MockRedis struct {
+	mock.Mock
+}
+
+func (m *MockRedis) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockRedis) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockRedis) UseOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, key, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRedis) SaveGatewaysByCountry(ctx context.Context, countryID string, gateways []models.Gateway) error {
+	args := m.Called(ctx, countryID, gateways)
+	return args.Error(0)
+}
+
+func (m *MockRedis) GetGatewaysByCountry(ctx context.Context, countryID string) ([]models.Gateway, error) {
+	args := m.Called(ctx, countryID)
+	return args.Get(0).([]models.Gateway), args.Error(1)
+}
+
+func (m *MockRedis) RecordGatewayOutcome(ctx context.Context, countryID string, gatewayID string, outcome models.GatewayOutcome) error {
+	args := m.Called(ctx, countryID, gatewayID, outcome)
+	return args.Error(0)
+}
+
+func (m *MockRedis) RecordGatewayLatency(ctx context.Context, countryID string, gatewayID string, latency time.Duration) error {
+	args := m.Called(ctx, countryID, gatewayID, latency)
+	return args.Error(0)
+}
+
+func (m *MockRedis) InvalidateGatewaysByCountry(ctx context.Context, countryIDs ...string) error {
+	args := m.Called(ctx, countryIDs)
+	return args.Error(0)
+}
+
+func (m *MockRedis) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, time.Duration, error) {
+	args := m.Called(ctx, key, rate, burst)
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
+func (m *MockRedis) ReserveSlot(ctx context.Context, key, member string, max int64, window time.Duration) (bool, time.Duration, error) {
+	args := m.Called(ctx, key, member, max, window)
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
+func (m *MockRedis) ReleaseSlot(ctx context.Context, key, member string) error {
+	args := m.Called(ctx, key, member)
+	return args.Error(0)
+}
+
+func (m *MockRedis) HSet(ctx context.Context, key string, values map[string]interface{}) error {
+	args := m.Called(ctx, key, values)
+	return args.Error(0)
+}
+
+func (m *MockRedis) CreateStatus(ctx context.Context, key, status string, values map[string]interface{}) error {
+	args := m.Called(ctx, key, status, values)
+	return args.Error(0)
+}
+
+func (m *MockRedis) SetStatus(ctx context.Context, key, event string, rank int, values map[string]interface{}) (bool, error) {
+	args := m.Called(ctx, key, event, rank, values)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRedis) StatusHandled(ctx context.Context, key, event string) error {
+	args := m.Called(ctx, key, event)
+	return args.Error(0)
+}
+
+func TestHandle(t *testing.T) {
+	metadata := map[string]string{"user_id": "1", "gateway_id": "7", "country_id": "2"}
+	deposit := func(eventType models.PaymentEventType, metadata map[string]string) models.PaymentEvent {
+		return models.PaymentEvent{Version: 1, Gateway: "STRIPE", EventID: "evt_123", Type: eventType,
+			OrderID: "pi_123", Amount: 1000, Currency: "usd", Metadata: metadata}
+	}
+	payout := func(eventType models.PaymentEventType, metadata map[string]string) models.PaymentEvent {
+		return models.PaymentEvent{Version: 1, Gateway: "DEFAULT_GATEWAY", EventID: "evt_456", Type: eventType,
+			OrderID: "po_123", Amount: 5000, Currency: "usd", FailureCode: "account_closed", Metadata: metadata}
+	}
+	status := func(status, timestamp string) interface{} {
+		return mock.MatchedBy(func(data map[string]interface{}) bool {
+			at, ok := data[timestamp].(int64)
+			return data["status"] == status && ok && at <= time.Now().Unix()
+		})
+	}
+
+	tests := []struct {
+		name          string
+		event         models.PaymentEvent
+		setupMocks    func(*MockDB, *MockRedis)
+		expectedError string
+		permanent     bool // Redelivery cannot fix the error
+	}{
+		{
+			name:  "deposit created",
+			event: deposit(models.DepositCreated, map[string]string{"user_id": "1"}),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "deposit:userid:1:orderid:pi_123", mock.Anything, mock.Anything, map[string]interface{}{"status": "pending"}).Return(true, nil)
+			},
+		},
+		{
+			name:  "deposit succeeded",
+			event: deposit(models.DepositSucceeded, metadata),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "deposit:userid:1:orderid:pi_123", "evt_123:"+string(models.DepositSucceeded), 2,
+					map[string]interface{}{"status": "success"}).Return(true, nil)
+				redis.On("RecordGatewayOutcome", mock.Anything, "2", "7", models.GatewayOutcome{Success: true}).Return(nil)
+				db.On("CreateTransaction", database.Transaction{OrderID: "pi_123", Amount: 10, Type: "credit", Status: "success",
+					UserID: 1, GatewayID: 7, CountryID: 2, Currency: "usd"}).Return(nil)
+			},
+		},
+		{
+			name:  "duplicate deposit succeeded is skipped",
+			event: deposit(models.DepositSucceeded, metadata),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "deposit:userid:1:orderid:pi_123", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+			},
+		},
+		{
+			name:  "deposit succeeded, gateway outcome failure is only logged",
+			event: deposit(models.DepositSucceeded, metadata),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "deposit:userid:1:orderid:pi_123", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+				redis.On("RecordGatewayOutcome", mock.Anything, "2", "7", models.GatewayOutcome{Success: true}).Return(errors.New("redis error"))
+				db.On("CreateTransaction", mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:          "deposit succeeded with invalid metadata",
+			event:         deposit(models.DepositSucceeded, map[string]string{"user_id": "1", "gateway_id": "not-a-number"}),
+			setupMocks:    func(db *MockDB, redis *MockRedis) {},
+			expectedError: "invalid gateway_id format: strconv.Atoi: parsing \"not-a-number\": invalid syntax",
+			permanent:     true,
+		},
+		{
+			name:  "deposit succeeded, redis failure",
+			event: deposit(models.DepositSucceeded, metadata),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "deposit:userid:1:orderid:pi_123", mock.Anything, mock.Anything, mock.Anything).Return(false, errors.New("redis error"))
+			},
+			expectedError: "failed to store data in redis: redis error",
+		},
+		{
+			name:  "deposit succeeded, db failure",
+			event: deposit(models.DepositSucceeded, metadata),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "deposit:userid:1:orderid:pi_123", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+				redis.On("RecordGatewayOutcome", mock.Anything, "2", "7", models.GatewayOutcome{Success: true}).Return(nil)
+				db.On("CreateTransaction", mock.Anything).Return(errors.New("db error"))
+			},
+			expectedError: "failed to store deposit transaction data in db: db error",
+		},
+		{
+			name:  "deposit failed",
+			event: deposit(models.DepositFailed, metadata),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "deposit:userid:1:orderid:pi_123", mock.Anything, mock.Anything, map[string]interface{}{"status": "failed"}).Return(true, nil)
+				redis.On("RecordGatewayOutcome", mock.Anything, "2", "7", models.GatewayOutcome{}).Return(nil)
+			},
+		},
+		{
+			name:          "deposit failed with missing metadata",
+			event:         deposit(models.DepositFailed, map[string]string{"user_id": "1"}),
+			setupMocks:    func(db *MockDB, redis *MockRedis) {},
+			expectedError: "invalid gateway_id format",
+			permanent:     true,
+		},
+		{
+			name:  "payout created",
+			event: payout(models.PayoutCreated, map[string]string{"user_id": "1"}),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "withdrawal:userid:1:payoutid:po_123", mock.Anything, mock.Anything, status("created", "created_at")).Return(true, nil)
+			},
+		},
+		{
+			name:  "payout created after it was paid is skipped",
+			event: payout(models.PayoutCreated, map[string]string{"user_id": "1"}),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "withdrawal:userid:1:payoutid:po_123", "evt_456:"+string(models.PayoutCreated), 1,
+					status("created", "created_at")).Return(false, nil)
+			},
+		},
+		{
+			name:  "payout paid",
+			event: payout(models.PayoutPaid, metadata),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "withdrawal:userid:1:payoutid:po_123", mock.Anything, mock.Anything, status("completed", "completed_at")).Return(true, nil)
+				redis.On("RecordGatewayOutcome", mock.Anything, "2", "7", models.GatewayOutcome{Success: true}).Return(nil)
+				db.On("CreateTransaction", database.Transaction{OrderID: "po_123", Amount: -50, Type: "debit", Status: "success",
+					UserID: 1, GatewayID: 7, CountryID: 2, Currency: "usd"}).Return(nil)
+			},
+		},
+		{
+			name:  "approved payout paid releases its hold",
+			event: payout(models.PayoutPaid, map[string]string{"user_id": "1", "gateway_id": "7", "country_id": "2", "hold_id": "9"}),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "withdrawal:userid:1:payoutid:po_123", mock.Anything, mock.Anything, status("completed", "completed_at")).Return(true, nil)
+				redis.On("RecordGatewayOutcome", mock.Anything, "2", "7", models.GatewayOutcome{Success: true}).Return(nil)
+				db.On("CreateTransaction", mock.Anything).Return(nil)
+				db.On("ReleaseHold", int64(9), "1", "usd").Return(nil)
+			},
+		},
+		{
+			name:  "approved payout canceled releases its hold",
+			event: payout(models.PayoutCanceled, map[string]string{"user_id": "1", "hold_id": "9"}),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "withdrawal:userid:1:payoutid:po_123", mock.Anything, mock.Anything, status("canceled", "canceled_at")).Return(true, nil)
+				db.On("ReleaseHold", int64(9), "1", "usd").Return(errors.New("db error"))
+			},
+			expectedError: "db error",
+		},
+		{
+			name:  "payout failed leaves a hold of another user held",
+			event: payout(models.PayoutFailed, map[string]string{"user_id": "1", "gateway_id": "7", "country_id": "2", "hold_id": "9"}),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "withdrawal:userid:1:payoutid:po_123", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+				redis.On("RecordGatewayOutcome", mock.Anything, "2", "7", models.GatewayOutcome{ErrorType: "account_closed"}).Return(nil)
+				db.On("ReleaseHold", int64(9), "1", "usd").Return(risk.ErrHoldNotFound)
+			},
+		},
+		{
+			name:          "payout paid with missing metadata",
+			event:         payout(models.PayoutPaid, map[string]string{}),
+			setupMocks:    func(db *MockDB, redis *MockRedis) {},
+			expectedError: "invalid gateway_id format",
+			permanent:     true,
+		},
+		{
+			name:  "payout failed",
+			event: payout(models.PayoutFailed, metadata),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "withdrawal:userid:1:payoutid:po_123", mock.Anything, mock.Anything, mock.MatchedBy(func(data map[string]interface{}) bool {
+					return data["status"] == "failed" && data["failure_code"] == "account_closed"
+				})).Return(true, nil)
+				redis.On("RecordGatewayOutcome", mock.Anything, "2", "7", models.GatewayOutcome{ErrorType: "account_closed"}).Return(nil)
+			},
+		},
+		{
+			name:  "payout canceled",
+			event: payout(models.PayoutCanceled, map[string]string{"user_id": "1"}),
+			setupMocks: func(db *MockDB, redis *MockRedis) {
+				redis.On("SetStatus", mock.Anything, "withdrawal:userid:1:payoutid:po_123", mock.Anything, mock.Anything, status("canceled", "canceled_at")).Return(true, nil)
+			},
+		},
+		{
+			name:          "unknown type",
+			event:         deposit("refund.created", metadata),
+			setupMocks:    func(db *MockDB, redis *MockRedis) {},
+			expectedError: "unknown event type \"refund.created\"",
+			permanent:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDB)
+			mockRedis := new(MockRedis)
+			tt.setupMocks(mockDB, mockRedis)
+			if tt.expectedError == "" {
+				mockRedis.On("StatusHandled", mock.Anything, mock.Anything, eventKey(tt.event)).Return(nil)
+			}
+
+			err := Handle(context.Background(), tt.event, &db.DB{DB: mockDB, Risk: mockDB, Redis: mockRedis})
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				assert.Equal(t, tt.permanent, bus.IsPermanent(err))
+			} else {
+				assert.NoError(t, err)
+			}
+			mockDB.AssertExpectations(t)
+			mockRedis.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expectedError string
+	}{
+		{name: "invalid JSON", value: `{invalid json}`, expectedError: "invalid character 'i'"},
+		{name: "newer version", value: `{"version":2,"gateway":"STRIPE","type":"deposit.created","order_id":"pi_123"}`, expectedError: "unsupported event version 2"},
+		{name: "missing order", value: `{"version":1,"gateway":"STRIPE","type":"deposit.created"}`, expectedError: "missing order ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Rejected before reaching Redis or the ledger
+			err := Handler(&db.DB{DB: new(MockDB), Redis: new(MockRedis)})(context.Background(), bus.Message{Topic: Topic, Value: []byte(tt.value)})
+			assert.ErrorContains(t, err, tt.expectedError)
+			assert.True(t, bus.IsPermanent(err))
+		})
+	}
+}
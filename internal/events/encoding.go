@@ -0,0 +1,143 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"payment-gateway/internal/bus"
+	"payment-gateway/internal/events/paymentspb"
+	"payment-gateway/internal/models"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=payment-gateway ../../proto/payments/v1/payment_event.proto
+
+// Encodings selected by BUS_ENCODING
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+// Headers describing how a payment event is encoded
+const (
+	HeaderContentType = "content-type"
+	HeaderSchema      = "schema" // Full name of the Protobuf message
+)
+
+// Content types of the encodings
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Encoder encodes the payment events a Publisher publishes
+type Encoder interface {
+	Encode(e models.PaymentEvent) ([]byte, error)
+	// Headers describe the encoding to the consumers
+	Headers() map[string]string
+}
+
+// NewEncoder returns the encoder of an encoding
+func NewEncoder(encoding string) (Encoder, error) {
+	switch encoding {
+	case EncodingJSON:
+		return jsonEncoder{}, nil
+	case EncodingProtobuf:
+		return protobufEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
+
+// jsonEncoder encodes the models.PaymentEvent JSON
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(e models.PaymentEvent) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (jsonEncoder) Headers() map[string]string {
+	return map[string]string{HeaderContentType: ContentTypeJSON}
+}
+
+// protobufEncoder encodes the payments.v1.PaymentEvent message of proto/payments/v1
+type protobufEncoder struct{}
+
+func (protobufEncoder) Encode(e models.PaymentEvent) ([]byte, error) {
+	return proto.Marshal(toProto(e))
+}
+
+func (protobufEncoder) Headers() map[string]string {
+	return map[string]string{
+		HeaderContentType: ContentTypeProtobuf,
+		HeaderSchema:      string((&paymentspb.PaymentEvent{}).ProtoReflect().Descriptor().FullName()),
+	}
+}
+
+// Decode decodes a payment event by the content type of msg. Messages without one
+// were published as JSON before the encodings were introduced.
+func Decode(msg bus.Message) (models.PaymentEvent, error) {
+	var e models.PaymentEvent
+	switch contentType := msg.Headers[HeaderContentType]; contentType {
+	case "", ContentTypeJSON:
+		if err := json.Unmarshal(msg.Value, &e); err != nil {
+			return e, fmt.Errorf("failed to decode JSON event: %v", err)
+		}
+	case ContentTypeProtobuf:
+		var pb paymentspb.PaymentEvent
+		if err := proto.Unmarshal(msg.Value, &pb); err != nil {
+			return e, fmt.Errorf("failed to decode Protobuf event: %v", err)
+		}
+		e = fromProto(&pb)
+	default:
+		return e, fmt.Errorf("unsupported content type %q", contentType)
+	}
+	return e, nil
+}
+
+// protoTypes maps the event types to their Protobuf enum values
+var protoTypes = map[models.PaymentEventType]paymentspb.PaymentEventType{
+	models.DepositCreated:   paymentspb.PaymentEventType_PAYMENT_EVENT_TYPE_DEPOSIT_CREATED,
+	models.DepositSucceeded: paymentspb.PaymentEventType_PAYMENT_EVENT_TYPE_DEPOSIT_SUCCEEDED,
+	models.DepositFailed:    paymentspb.PaymentEventType_PAYMENT_EVENT_TYPE_DEPOSIT_FAILED,
+	models.PayoutCreated:    paymentspb.PaymentEventType_PAYMENT_EVENT_TYPE_PAYOUT_CREATED,
+	models.PayoutPaid:       paymentspb.PaymentEventType_PAYMENT_EVENT_TYPE_PAYOUT_PAID,
+	models.PayoutFailed:     paymentspb.PaymentEventType_PAYMENT_EVENT_TYPE_PAYOUT_FAILED,
+	models.PayoutCanceled:   paymentspb.PaymentEventType_PAYMENT_EVENT_TYPE_PAYOUT_CANCELED,
+}
+
+func toProto(e models.PaymentEvent) *paymentspb.PaymentEvent {
+	return &paymentspb.PaymentEvent{
+		Version:     int32(e.Version),
+		Gateway:     e.Gateway,
+		EventId:     e.EventID,
+		Type:        protoTypes[e.Type],
+		OrderId:     e.OrderID,
+		Amount:      e.Amount,
+		Currency:    e.Currency,
+		FailureCode: e.FailureCode,
+		Metadata:    e.Metadata,
+		OccurredAt:  timestamppb.New(e.OccurredAt),
+	}
+}
+
+// fromProto converts a decoded message, an unknown type is left empty for Validate
+func fromProto(pb *paymentspb.PaymentEvent) models.PaymentEvent {
+	e := models.PaymentEvent{
+		Version:     int(pb.GetVersion()),
+		Gateway:     pb.GetGateway(),
+		EventID:     pb.GetEventId(),
+		OrderID:     pb.GetOrderId(),
+		Amount:      pb.GetAmount(),
+		Currency:    pb.GetCurrency(),
+		FailureCode: pb.GetFailureCode(),
+		Metadata:    pb.GetMetadata(),
+		OccurredAt:  pb.GetOccurredAt().AsTime(),
+	}
+	for t, value := range protoTypes {
+		if value == pb.GetType() {
+			e.Type = t
+		}
+	}
+	return e
+}
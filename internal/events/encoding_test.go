@@ -0,0 +1,134 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"payment-gateway/internal/bus"
+	"payment-gateway/internal/events/paymentspb"
+	"payment-gateway/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var update = flag.Bool("update", false, "register the current Protobuf schema in testdata once it is compatible")
+
+const registeredSchema = "testdata/payments.v1.PaymentEvent.json"
+
+func TestEncoding(t *testing.T) {
+	for _, encoding := range []string{EncodingJSON, EncodingProtobuf} {
+		encoder, err := NewEncoder(encoding)
+		require.NoError(t, err)
+		for _, eventType := range models.PaymentEventTypes {
+			t.Run(encoding+" "+string(eventType), func(t *testing.T) {
+				e := models.PaymentEvent{Version: 1, Gateway: "STRIPE", EventID: "evt_123", Type: eventType, OrderID: "pi_123",
+					Amount: 1000, Currency: "usd", FailureCode: "card_declined", Metadata: map[string]string{"user_id": "1"},
+					OccurredAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)}
+				value, err := encoder.Encode(e)
+				require.NoError(t, err)
+
+				decoded, err := Decode(bus.Message{Value: value, Headers: encoder.Headers()})
+				require.NoError(t, err)
+				assert.Equal(t, e, decoded)
+			})
+		}
+	}
+
+	_, err := NewEncoder("avro")
+	assert.EqualError(t, err, `unknown encoding "avro"`)
+	_, err = Decode(bus.Message{Value: []byte("{}"), Headers: map[string]string{HeaderContentType: "application/avro"}})
+	assert.EqualError(t, err, `unsupported content type "application/avro"`)
+}
+
+// schema is the part of a Protobuf schema its consumers depend on
+type schema struct {
+	Message string                      `json:"message"`
+	Fields  []field                     `json:"fields"`
+	Enums   map[string]map[string]int32 `json:"enums"` // Value numbers by name, by enum
+}
+
+type field struct {
+	Name        string `json:"name"`
+	Number      int32  `json:"number"`
+	Kind        string `json:"kind"`
+	Cardinality string `json:"cardinality"`
+	Type        string `json:"type,omitempty"` // Full name of enum and message fields, map<key, value> of maps
+}
+
+func describe(message protoreflect.MessageDescriptor) schema {
+	s := schema{Message: string(message.FullName()), Enums: make(map[string]map[string]int32)}
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		f := field{Name: string(fd.Name()), Number: int32(fd.Number()), Kind: fd.Kind().String(), Cardinality: fd.Cardinality().String()}
+		switch {
+		case fd.Enum() != nil:
+			f.Type = string(fd.Enum().FullName())
+			values := make(map[string]int32)
+			for j := 0; j < fd.Enum().Values().Len(); j++ {
+				values[string(fd.Enum().Values().Get(j).Name())] = int32(fd.Enum().Values().Get(j).Number())
+			}
+			s.Enums[f.Type] = values
+		case fd.IsMap():
+			f.Type = fmt.Sprintf("map<%s, %s>", fd.MapKey().Kind(), fd.MapValue().Kind())
+		case fd.Message() != nil:
+			f.Type = string(fd.Message().FullName())
+		}
+		s.Fields = append(s.Fields, f)
+	}
+	return s
+}
+
+// TestSchemaCompatibility checks the Protobuf schema against the registered one:
+// fields and enum values may be added, the registered ones must keep their number,
+// name and type or be reserved. Register a compatible change with -update.
+func TestSchemaCompatibility(t *testing.T) {
+	message := (&paymentspb.PaymentEvent{}).ProtoReflect().Descriptor()
+	current := describe(message)
+
+	data, err := os.ReadFile(registeredSchema)
+	require.NoError(t, err)
+	var registered schema
+	require.NoError(t, json.Unmarshal(data, &registered))
+	require.Equal(t, registered.Message, current.Message)
+
+	for _, f := range registered.Fields {
+		fd := message.Fields().ByNumber(protoreflect.FieldNumber(f.Number))
+		if fd == nil {
+			assert.True(t, message.ReservedRanges().Has(protoreflect.FieldNumber(f.Number)), "field %d %s removed without reserving its number", f.Number, f.Name)
+			assert.True(t, message.ReservedNames().Has(protoreflect.Name(f.Name)), "field %d %s removed without reserving its name", f.Number, f.Name)
+			continue
+		}
+		for _, c := range current.Fields {
+			if c.Number == f.Number {
+				assert.Equal(t, f, c, "field %d changed", f.Number)
+			}
+		}
+	}
+	for name, values := range registered.Enums {
+		enum := message.ParentFile().Enums().ByName(protoreflect.FullName(name).Name())
+		require.NotNil(t, enum, "enum %s removed", name)
+		for valueName, number := range values {
+			if enum.Values().ByNumber(protoreflect.EnumNumber(number)) == nil {
+				assert.True(t, enum.ReservedRanges().Has(protoreflect.EnumNumber(number)), "value %d %s removed without reserving its number", number, valueName)
+				continue
+			}
+			assert.Equal(t, number, current.Enums[name][valueName], "value %s renumbered or renamed", valueName)
+		}
+	}
+
+	if *update && !t.Failed() {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		require.NoError(t, encoder.Encode(current))
+		require.NoError(t, os.WriteFile(registeredSchema, buf.Bytes(), 0o644))
+	}
+}
@@ -0,0 +1,47 @@
+// Package events carries the payment lifecycle events. The gateways normalise their
+// webhooks into models.PaymentEvent and publish them on Topic, where one consumer
+// updates the payment statuses and posts the ledger whatever the gateway. The events
+// are encoded as JSON or as the Protobuf messages of proto/payments/v1.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-gateway/internal/bus"
+	"payment-gateway/internal/logging"
+	"payment-gateway/internal/models"
+)
+
+// Topic is the topic of the payment events of every gateway
+const Topic = "payments.events"
+
+// ErrUnhandled is returned by the gateways for webhooks without a payment event,
+// such as event types the service does not track
+var ErrUnhandled = errors.New("unhandled event")
+
+// Publisher publishes the payment events with the configured encoder
+type Publisher struct {
+	bus     bus.Bus
+	encoder Encoder
+}
+
+// NewPublisher returns a publisher encoding the events with encoder
+func NewPublisher(b bus.Bus, encoder Encoder) *Publisher {
+	return &Publisher{bus: b, encoder: encoder}
+}
+
+// Publish publishes e at the current schema version. Events are keyed by order so
+// the events of a payment keep their order.
+func (p *Publisher) Publish(ctx context.Context, e models.PaymentEvent) error {
+	e.Version = models.PaymentEventVersion
+	if err := e.Validate(); err != nil {
+		return fmt.Errorf("invalid payment event: %w", err)
+	}
+	value, err := p.encoder.Encode(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode payment event: %v", err)
+	}
+	ctx = logging.WithOrderID(ctx, e.OrderID)
+	return bus.Publish(ctx, p.bus, bus.Message{Topic: Topic, Key: e.OrderID, Value: value, Headers: p.encoder.Headers()})
+}
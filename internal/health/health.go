@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// Statuses of a check and of the whole report
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"    // A non-critical check failed, the service still takes traffic
+	StatusUnavailable = "unavailable" // A critical check failed
+)
+
+// Check probes one dependency
+type Check struct {
+	Name     string
+	Critical bool // A failing critical check fails readiness
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status    string `json:"status" example:"ok"`
+	Error     string `json:"error,omitempty" example:"dial tcp 127.0.0.1:5432: connect: connection refused"`
+	LatencyMS int64  `json:"latency_ms" example:"2"`
+	Critical  bool   `json:"critical" example:"true"`
+}
+
+// Report is the per-dependency readiness report
+type Report struct {
+	Status string            `json:"status" example:"ok"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Ready reports whether every critical check passed
+func (r Report) Ready() bool {
+	return r.Status != StatusUnavailable
+}
+
+// Checker runs the readiness checks
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []Check
+	timeout time.Duration
+}
+
+// New creates a checker that gives each check at most timeout
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers checks
+func (c *Checker) Add(checks ...Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, checks...)
+}
+
+// Report runs the checks concurrently
+func (c *Checker) Report(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]Check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, check := range checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+		if result.Status == StatusOK {
+			continue
+		}
+		if check.Critical {
+			report.Status = StatusUnavailable
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// run runs one check within the timeout, a check that ignores its context is abandoned
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", c.timeout)
+	}
+
+	result := Result{Status: StatusOK, LatencyMS: time.Since(start).Milliseconds(), Critical: check.Critical}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Breaker checks that a circuit breaker is not open. It is not critical: an open
+// breaker is shared by every instance, taking them out of rotation would not help.
+func Breaker(name string, state func() gobreaker.State) Check {
+	return Check{
+		Name: "circuit_breaker_" + name,
+		Run: func(ctx context.Context) error {
+			if s := state(); s != gobreaker.StateClosed {
+				return errors.New("circuit breaker is " + s.String())
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,68 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_Report(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	hang := func(ctx context.Context) error { time.Sleep(time.Second); return nil }
+
+	tests := []struct {
+		name   string
+		checks []Check
+		status string
+		failed map[string]string
+	}{
+		{
+			name:   "all checks pass",
+			checks: []Check{{Name: "postgres", Critical: true, Run: ok}, Breaker("stripe", func() gobreaker.State { return gobreaker.StateClosed })},
+			status: StatusOK,
+		},
+		{
+			name:   "open breaker degrades without failing readiness",
+			checks: []Check{{Name: "postgres", Critical: true, Run: ok}, Breaker("stripe", func() gobreaker.State { return gobreaker.StateOpen })},
+			status: StatusDegraded,
+			failed: map[string]string{"circuit_breaker_stripe": "circuit breaker is open"},
+		},
+		{
+			name:   "critical dependency down",
+			checks: []Check{{Name: "postgres", Critical: true, Run: down}, {Name: "redis", Critical: true, Run: ok}},
+			status: StatusUnavailable,
+			failed: map[string]string{"postgres": "connection refused"},
+		},
+		{
+			name:   "hanging check times out",
+			checks: []Check{{Name: "kafka", Critical: true, Run: hang}},
+			status: StatusUnavailable,
+			failed: map[string]string{"kafka": "check timed out after 50ms"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := New(50 * time.Millisecond)
+			checker.Add(tt.checks...)
+
+			report := checker.Report(context.Background())
+			assert.Equal(t, tt.status, report.Status)
+			assert.Equal(t, tt.status != StatusUnavailable, report.Ready())
+			assert.Len(t, report.Checks, len(tt.checks))
+			for name, result := range report.Checks {
+				if message, ok := tt.failed[name]; ok {
+					assert.Equal(t, StatusUnavailable, result.Status)
+					assert.Equal(t, message, result.Error)
+				} else {
+					assert.Equal(t, StatusOK, result.Status)
+				}
+			}
+		})
+	}
+}
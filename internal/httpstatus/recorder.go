@@ -0,0 +1,38 @@
+// Package httpstatus records the status code of HTTP responses for the middlewares
+// that log, measure or count requests by outcome.
+package httpstatus
+
+import "net/http"
+
+// Recorder captures the status code written by a handler. Handlers that write a
+// body without calling WriteHeader answer 200.
+type Recorder struct {
+	http.ResponseWriter
+	Status int
+
+	wroteHeader bool
+}
+
+// NewRecorder wraps w
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+// WriteHeader records the first status written, as net/http ignores the others
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.Status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Success reports whether the handler answered 2xx
+func (r *Recorder) Success() bool {
+	return r.Status >= 200 && r.Status < 300
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
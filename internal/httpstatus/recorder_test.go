@@ -0,0 +1,55 @@
+package httpstatus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected int
+		success  bool
+	}{
+		{
+			name:     "body without status",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) },
+			expected: http.StatusOK,
+			success:  true,
+		},
+		{
+			name:     "accepted",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) },
+			expected: http.StatusAccepted,
+			success:  true,
+		},
+		{
+			name:     "error",
+			handler:  func(w http.ResponseWriter, r *http.Request) { http.Error(w, "Bad Request", http.StatusBadRequest) },
+			expected: http.StatusBadRequest,
+		},
+		{
+			name: "second status is ignored",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.WriteHeader(http.StatusOK)
+			},
+			expected: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			recorder := NewRecorder(w)
+			tt.handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tt.expected, recorder.Status)
+			assert.Equal(t, tt.success, recorder.Success())
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
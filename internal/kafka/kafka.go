@@ -1,77 +1,178 @@
 package kafka
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"payment-gateway/internal/bus"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/metrics"
 	"time"
 
 	"github.com/IBM/sarama"
 )
 
-// Kafka represents the Kafka client configuration
+// Kafka is the bus backed by the Kafka brokers
 type Kafka struct {
+	*bus.Consumers
+	client   sarama.Client
 	producer sarama.SyncProducer
 	consumer sarama.Consumer
 	brokers  []string
 	config   *sarama.Config
+
+	offsets sarama.OffsetManager // Commits the consumed offsets of the consumer group
 }
 
+var _ bus.Bus = (*Kafka)(nil)
+
+// consumerGroup names the group the consumed offsets are committed for
+const consumerGroup = "payment-gateway"
+
 // Init initializes a new Kafka client
-func Init(brokers []string) (*Kafka, error) {
+func Init(cfg config.Kafka) (*Kafka, error) {
+	brokers := cfg.Brokers
 	config := sarama.NewConfig()
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 5
 	config.Producer.Return.Successes = true
 	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest // Where a topic without committed offsets starts
+
+	// The producer and the consumer share one client, it also serves the metadata checks
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
 
 	// Initialize producer
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	producer, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create producer: %v", err)
 	}
 
 	// Initialize consumer
-	consumer, err := sarama.NewConsumer(brokers, config)
+	consumer, err := sarama.NewConsumerFromClient(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create consumer: %v", err)
 	}
 
+	offsets, err := sarama.NewOffsetManagerFromClient(consumerGroup, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offset manager: %v", err)
+	}
+
 	return &Kafka{
-		producer: producer,
-		consumer: consumer,
-		brokers:  brokers,
-		config:   config,
+		Consumers: bus.NewConsumers(),
+		client:    client,
+		producer:  producer,
+		consumer:  consumer,
+		offsets:   offsets,
+		brokers:   brokers,
+		config:    config,
 	}, nil
 }
 
-// PublishData publishes a message to the specified topic
-func (k *Kafka) PublishData(topic string, key string, value interface{}) error {
+// Publish sends a message to partition 0 of its topic, creating the topic if needed
+func (k *Kafka) Publish(ctx context.Context, msg bus.Message) error {
 	// Check if topic exists, create if it doesn't
-	err := k.ensureTopicExists(topic)
+	err := k.ensureTopicExists(msg.Topic)
 	if err != nil {
 		return fmt.Errorf("failed to ensure topic exists: %v", err)
 	}
 
-	// Convert value to JSON
-	msgValue, err := json.Marshal(value)
+	partition, offset, err := k.producer.SendMessage(producerMessage(msg))
 	if err != nil {
-		return fmt.Errorf("failed to marshal value: %v", err)
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+
+	slog.DebugContext(ctx, "kafka message sent", "topic", msg.Topic, "partition", partition, "offset", offset)
+	return nil
+}
+
+// Subscribe reads partition 0 of topic from the committed offset of the consumer group
+// until StopConsumers is called. The message being handled is finished and its offset
+// marked before the consumer stops.
+func (k *Kafka) Subscribe(topic string, handle bus.Handler) error {
+	ctx, ok := k.Begin()
+	if !ok {
+		return nil
 	}
+	defer k.End(topic)
 
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Key:   sarama.StringEncoder(key),
-		Value: sarama.ByteEncoder(msgValue),
+	// Subscribing to a missing topic fails, create it like the producer does
+	if err := k.ensureTopicExists(topic); err != nil {
+		return fmt.Errorf("failed to ensure topic exists: %v", err)
 	}
+	offsets, err := k.offsets.ManagePartition(topic, 0)
+	if err != nil {
+		return fmt.Errorf("failed to manage partition offsets: %v", err)
+	}
+	defer offsets.Close()
 
-	partition, offset, err := k.producer.SendMessage(msg)
+	// Resume after the last committed message, or from the newest offset on first start
+	offset, _ := offsets.NextOffset()
+	partitionConsumer, err := k.consumer.ConsumePartition(topic, 0, offset)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+		return fmt.Errorf("failed to create partition consumer: %v", err)
 	}
+	defer partitionConsumer.Close()
+	k.Subscribed(topic)
 
-	log.Printf("Message sent to partition %d at offset %d\n", partition, offset)
-	return nil
+	for {
+		// Prefer stopping over a message that arrived at the same time
+		if ctx.Err() != nil {
+			return nil
+		}
+		select {
+		case msg := <-partitionConsumer.Messages():
+			metrics.SetConsumerLag(topic, msg.Partition, partitionConsumer.HighWaterMarkOffset()-msg.Offset-1)
+			// A message left unmarked is consumed again on restart
+			if err := bus.Process(ctx, k, consumedMessage(msg), handle); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			offsets.MarkOffset(msg.Offset+1, "")
+
+		case err := <-partitionConsumer.Errors():
+			return fmt.Errorf("consumer error: %v", err)
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// producerMessage converts a bus message to a sarama message
+func producerMessage(msg bus.Message) *sarama.ProducerMessage {
+	produced := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Key:   sarama.StringEncoder(msg.Key),
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	for key, value := range msg.Headers {
+		produced.Headers = append(produced.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	return produced
+}
+
+// consumedMessage converts a consumed sarama message to a bus message
+func consumedMessage(msg *sarama.ConsumerMessage) bus.Message {
+	consumed := bus.Message{
+		Topic:   msg.Topic,
+		Key:     string(msg.Key),
+		Value:   msg.Value,
+		Headers: make(map[string]string, len(msg.Headers)),
+		Offset:  msg.Offset,
+	}
+	for _, header := range msg.Headers {
+		if header != nil {
+			consumed.Headers[string(header.Key)] = string(header.Value)
+		}
+	}
+	return consumed
 }
 
 // ensureTopicExists checks if a topic exists and creates it if it doesn't
@@ -101,13 +202,44 @@ func (k *Kafka) ensureTopicExists(topic string) error {
 	return nil
 }
 
-// Close closes the Kafka producer and consumer
+// StopConsumers stops the consumers once they finish the message in flight and
+// commits their offsets. It returns early with the error of ctx if the consumers
+// do not stop in time.
+func (k *Kafka) StopConsumers(ctx context.Context) error {
+	if err := k.Consumers.StopConsumers(ctx); err != nil {
+		return err
+	}
+	k.offsets.Commit()
+	return nil
+}
+
+// Close stops the consumers without waiting for them, commits the consumed offsets
+// and closes the producer, the consumer and the client. Call StopConsumers first to
+// let the consumers finish their message.
 func (k *Kafka) Close() error {
+	k.Cancel()
+	if err := k.offsets.Close(); err != nil {
+		return fmt.Errorf("failed to close offset manager: %v", err)
+	}
 	if err := k.producer.Close(); err != nil {
 		return fmt.Errorf("failed to close producer: %v", err)
 	}
 	if err := k.consumer.Close(); err != nil {
 		return fmt.Errorf("failed to close consumer: %v", err)
 	}
+	if err := k.client.Close(); err != nil {
+		return fmt.Errorf("failed to close client: %v", err)
+	}
+	return nil
+}
+
+// Ping refreshes the cluster metadata to check that the brokers are reachable
+func (k *Kafka) Ping(ctx context.Context) error {
+	if err := k.client.RefreshMetadata(); err != nil {
+		return fmt.Errorf("failed to refresh metadata: %v", err)
+	}
+	if len(k.client.Brokers()) == 0 {
+		return fmt.Errorf("no brokers available")
+	}
 	return nil
 }
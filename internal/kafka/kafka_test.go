@@ -0,0 +1,30 @@
+package kafka
+
+import (
+	"payment-gateway/internal/bus"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageConversion(t *testing.T) {
+	msg := bus.Message{
+		Topic:   "gateway.stripe",
+		Key:     "evt_1",
+		Value:   []byte(`{"id":"evt_1"}`),
+		Headers: map[string]string{"X-Request-ID": "req-1", "traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}
+	produced := producerMessage(msg)
+
+	// The broker hands the record to the consumer
+	consumed := &sarama.ConsumerMessage{Topic: produced.Topic, Offset: 7}
+	consumed.Key, _ = produced.Key.Encode()
+	consumed.Value, _ = produced.Value.Encode()
+	for i := range produced.Headers {
+		consumed.Headers = append(consumed.Headers, &produced.Headers[i])
+	}
+
+	msg.Offset = 7
+	assert.Equal(t, msg, consumedMessage(consumed))
+}
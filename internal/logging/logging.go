@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"payment-gateway/internal/config"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header names carrying the correlation IDs over HTTP and Kafka
+const (
+	HeaderRequestID = "X-Request-ID"
+	HeaderOrderID   = "X-Order-ID"
+)
+
+// Setup installs a JSON (or text) logger on stderr as the slog default. The standard
+// log package is routed through it too.
+func Setup(cfg config.Log) {
+	slog.SetDefault(New(os.Stderr, ParseLevel(cfg.Level), cfg.Format))
+}
+
+// New creates a logger that adds the correlation IDs of the context and redacts secrets
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	if strings.EqualFold(format, "text") {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel parses a level name, defaulting to info
+func ParseLevel(value string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	orderIDKey
+)
+
+// WithRequestID returns a context carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID of the context, or an empty string
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithOrderID returns a context carrying the gateway order or payout ID
+func WithOrderID(ctx context.Context, orderID string) context.Context {
+	if orderID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, orderIDKey, orderID)
+}
+
+// OrderID returns the order ID of the context, or an empty string
+func OrderID(ctx context.Context) string {
+	id, _ := ctx.Value(orderIDKey).(string)
+	return id
+}
+
+// contextHandler adds the correlation IDs and the trace of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if id := OrderID(ctx); id != "" {
+		record.AddAttrs(slog.String("order_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
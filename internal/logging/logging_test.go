@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"payment-gateway/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger_Redaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []any
+		want    map[string]interface{}
+		missing []string
+	}{
+		{
+			name: "secret keys are redacted",
+			args: []any{"client_secret", "pi_1_secret_2", "stripe_secret_key", "sk_test_abc", "amount", 5000},
+			want: map[string]interface{}{"client_secret": Redacted, "stripe_secret_key": Redacted, "amount": float64(5000)},
+		},
+		{
+			name:    "bank details inside structs are redacted",
+			args:    []any{"request", models.BankAccountDetails{AccountHolderName: "John Doe", AccountNumber: "1234567890", RoutingNumber: "110000000", Country: "US"}},
+			missing: []string{"John Doe", "1234567890", "110000000"},
+		},
+		{
+			name:    "client secrets inside errors are redacted",
+			args:    []any{"error", errors.New("confirm failed for pi_3Ab_secret_XyZ")},
+			want:    map[string]interface{}{"error": "confirm failed for " + Redacted},
+			missing: []string{"pi_3Ab_secret_XyZ"},
+		},
+		{
+			name: "nested maps are redacted",
+			args: []any{"payload", map[string]interface{}{"entity": map[string]interface{}{"iban": "GB29NWBK60161331926819", "id": "pay_1"}}},
+			want: map[string]interface{}{"payload": map[string]interface{}{"entity": map[string]interface{}{"iban": Redacted, "id": "pay_1"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(&buf, slog.LevelInfo, "json").Info("test", tt.args...)
+
+			var record map[string]interface{}
+			assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			for key, value := range tt.want {
+				assert.Equal(t, value, record[key])
+			}
+			for _, value := range tt.missing {
+				assert.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestLogger_ContextIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo, "json")
+
+	ctx := WithOrderID(WithRequestID(context.Background(), "req-1"), "pi_1")
+	logger.DebugContext(ctx, "hidden below the level")
+	logger.InfoContext(ctx, "deposit created")
+
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "pi_1", record["order_id"])
+	assert.Equal(t, "deposit created", record["msg"])
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		reuse  bool
+	}{
+		{name: "caller ID is kept", header: "abc-123", reuse: true},
+		{name: "missing ID is generated", header: ""},
+		{name: "malformed ID is replaced", header: "bad id\nwith newline", reuse: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(HeaderRequestID, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.NotEmpty(t, seen)
+			assert.Equal(t, seen, rec.Header().Get(HeaderRequestID))
+			if tt.reuse {
+				assert.Equal(t, tt.header, seen)
+			} else {
+				assert.NotEqual(t, tt.header, seen)
+			}
+		})
+	}
+}
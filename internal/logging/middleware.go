@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"payment-gateway/internal/httpstatus"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// validRequestID bounds the request IDs accepted from callers
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Middleware tags each request with an ID, taken from X-Request-ID when the caller
+// sends a well-formed one, echoes it in the response and logs the request when done.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(HeaderRequestID)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+		ctx := WithRequestID(r.Context(), requestID)
+
+		recorder := httpstatus.NewRecorder(w)
+		start := time.Now()
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if recorder.Status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.Status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute and field names whose value is never logged
+var sensitiveKeys = map[string]bool{
+	"authorization":       true,
+	"signature":           true,
+	"x-signature":         true,
+	"stripe-signature":    true,
+	"api_key":             true,
+	"apikey":              true,
+	"account_number":      true,
+	"accountnumber":       true,
+	"routing_number":      true,
+	"routingnumber":       true,
+	"iban":                true,
+	"sort_code":           true,
+	"ifsc":                true,
+	"ifsc_code":           true,
+	"swift":               true,
+	"swift_code":          true,
+	"bic":                 true,
+	"account_holder_name": true,
+	"bank_details":        true,
+	"bank_account":        true,
+}
+
+// sensitiveParts redact any key containing them, such as client_secret or access_token
+var sensitiveParts = []string{"secret", "password", "token"}
+
+// clientSecret matches Stripe client secrets and secret keys inside free text
+var clientSecret = regexp.MustCompile(`\b(?:(?:pi|seti|po)_[A-Za-z0-9]+_secret_[A-Za-z0-9]+|(?:sk|rk)_(?:live|test)_[A-Za-z0-9]+)`)
+
+// IsSensitive reports whether values under the key are redacted
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, part := range sensitiveParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr is the ReplaceAttr hook of the handlers
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	attr.Value = redactValue(attr.Value)
+	return attr
+}
+
+func redactValue(value slog.Value) slog.Value {
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.StringValue(RedactString(value.String()))
+	case slog.KindAny:
+		v := value.Any()
+		if err, ok := v.(error); ok {
+			return slog.StringValue(RedactString(err.Error()))
+		}
+		if s, ok := v.(fmt.Stringer); ok {
+			return slog.StringValue(RedactString(s.String()))
+		}
+		// Structs, maps and slices are walked through their JSON form
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return slog.StringValue(RedactString(fmt.Sprint(v)))
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return slog.StringValue(RedactString(string(raw)))
+		}
+		return slog.AnyValue(Redact(decoded))
+	}
+	return value
+}
+
+// Redact returns a copy of a decoded JSON value with the sensitive fields replaced
+func Redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if IsSensitive(key) {
+				redacted[key] = Redacted
+			} else {
+				redacted[key] = Redact(value)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, value := range v {
+			redacted[i] = Redact(value)
+		}
+		return redacted
+	case string:
+		return RedactString(v)
+	}
+	return v
+}
+
+// RedactString masks client secrets and API keys embedded in text
+func RedactString(s string) string {
+	return clientSecret.ReplaceAllString(s, Redacted)
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"net/http"
+	"payment-gateway/internal/httpstatus"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sony/gobreaker"
+)
+
+const namespace = "payment_gateway"
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	payments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_total",
+		Help:      "Deposits and withdrawals by gateway and outcome.",
+	}, []string{"gateway", "type", "outcome"})
+
+	pspCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "psp_call_duration_seconds",
+		Help:      "Latency of calls to the payment service providers.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"gateway", "operation", "outcome"})
+
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	}, []string{"breaker"})
+
+	kafkaPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_messages_published_total",
+		Help:      "Kafka messages published by topic and outcome.",
+	}, []string{"topic", "outcome"})
+
+	kafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_messages_consumed_total",
+		Help:      "Kafka messages consumed by topic and outcome.",
+	}, []string{"topic", "outcome"})
+
+	kafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_lag",
+		Help:      "Messages between the last consumed offset and the high water mark.",
+	}, []string{"topic", "partition"})
+
+	ledgerPostings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ledger_postings_total",
+		Help:      "Transactions posted to the ledger by type and outcome.",
+	}, []string{"type", "outcome"})
+)
+
+// Payment outcomes
+const (
+	OutcomeCreated   = "created"   // Accepted by the gateway
+	OutcomeRejected  = "rejected"  // The gateway call failed
+	OutcomeSucceeded = "succeeded" // Confirmed by a webhook
+	OutcomeFailed    = "failed"    // Failure reported by a webhook
+	OutcomeCanceled  = "canceled"  // Cancellation reported by a webhook
+)
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records the latency of each request under its route template
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		recorder := httpstatus.NewRecorder(w)
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+		httpRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(recorder.Status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RecordPayment counts a deposit or withdrawal outcome
+func RecordPayment(gateway, txType, outcome string) {
+	payments.WithLabelValues(gateway, txType, outcome).Inc()
+}
+
+// ObservePSPCall records the latency and outcome of a gateway call started at start
+func ObservePSPCall(gateway, operation string, start time.Time, err error) {
+	pspCallDuration.WithLabelValues(gateway, operation, outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// SetBreakerState exports the state of a circuit breaker
+func SetBreakerState(name string, state gobreaker.State) {
+	circuitBreakerState.WithLabelValues(name).Set(float64(state))
+}
+
+// KafkaPublished counts a published message
+func KafkaPublished(topic string, err error) {
+	kafkaPublished.WithLabelValues(topic, outcome(err)).Inc()
+}
+
+// KafkaConsumed counts a consumed message
+func KafkaConsumed(topic string, err error) {
+	kafkaConsumed.WithLabelValues(topic, outcome(err)).Inc()
+}
+
+// SetConsumerLag exports the lag of a partition consumer
+func SetConsumerLag(topic string, partition int32, lag int64) {
+	if lag < 0 {
+		lag = 0
+	}
+	kafkaConsumerLag.WithLabelValues(topic, strconv.Itoa(int(partition))).Set(float64(lag))
+}
+
+// LedgerPosting counts a transaction posted to the ledger
+func LedgerPosting(txType string, err error) {
+	ledgerPostings.WithLabelValues(txType, outcome(err)).Inc()
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_RouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/gateways/{countryID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/gateways/1", "/gateways/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Both requests land in one series, the country ID is not a label
+	assert.Equal(t, 1, testutil.CollectAndCount(httpRequestDuration))
+	histogram := httpRequestDuration.WithLabelValues("/gateways/{countryID}", http.MethodGet, "404")
+	assert.Equal(t, 1, testutil.CollectAndCount(histogram.(prometheus.Collector)))
+}
+
+func TestCounters(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func()
+		value  func() float64
+		want   float64
+	}{
+		{
+			name:   "payments by gateway and outcome",
+			record: func() { RecordPayment("STRIPE", "deposit", OutcomeSucceeded) },
+			value: func() float64 {
+				return testutil.ToFloat64(payments.WithLabelValues("STRIPE", "deposit", OutcomeSucceeded))
+			},
+			want: 1,
+		},
+		{
+			name:   "failed ledger postings",
+			record: func() { LedgerPosting("debit", errors.New("failed to update ledger")) },
+			value:  func() float64 { return testutil.ToFloat64(ledgerPostings.WithLabelValues("debit", "error")) },
+			want:   1,
+		},
+		{
+			name:   "open breaker",
+			record: func() { SetBreakerState("StripePaymentIntent", gobreaker.StateOpen) },
+			value:  func() float64 { return testutil.ToFloat64(circuitBreakerState.WithLabelValues("StripePaymentIntent")) },
+			want:   2,
+		},
+		{
+			name:   "negative lag is clamped",
+			record: func() { SetConsumerLag("gateway.stripe", 0, -1) },
+			value:  func() float64 { return testutil.ToFloat64(kafkaConsumerLag.WithLabelValues("gateway.stripe", "0")) },
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record()
+			assert.Equal(t, tt.want, tt.value())
+		})
+	}
+}
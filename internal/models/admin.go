@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// GatewayRequest creates or updates a gateway
+type GatewayRequest struct {
+	Name                string `json:"name" validate:"required" example:"RAZORPAY"`                          // Gateway name, must match the registered PSP
+	DataFormatSupported string `json:"data_format_supported" validate:"required" example:"application/json"` // Payload format used by the gateway
+	Enabled             *bool  `json:"enabled,omitempty" example:"true"`                                     // Defaults to true on creation
+}
+
+// CountryRequest creates or updates a country
+type CountryRequest struct {
+	Name     string `json:"name" validate:"required" example:"India"`         // Country name
+	Code     string `json:"code" validate:"required,len=2" example:"IN"`      // 2-letter ISO country code
+	Currency string `json:"currency" validate:"required,len=3" example:"INR"` // 3-letter ISO currency code
+}
+
+// StatusRequest enables or disables a gateway or a gateway to country mapping
+type StatusRequest struct {
+	Enabled *bool `json:"enabled" validate:"required" example:"false"`
+}
+
+// Gateway registry statuses
+const (
+	GatewayEnabled = "enabled" // Takes new payments
+	GatewayPaused  = "paused"  // Out of rotation, its webhooks are still handled
+)
+
+// GatewayState is the registry status of a gateway, keyed by the PSP name
+type GatewayState struct {
+	Name      string    `json:"name" example:"STRIPE"`
+	Status    string    `json:"status" example:"paused"`
+	Reason    string    `json:"reason,omitempty" example:"elevated decline rate"`
+	UpdatedBy string    `json:"updated_by,omitempty" example:"ops@example.com"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// GatewayStateRequest pauses or enables a gateway
+type GatewayStateRequest struct {
+	Status string `json:"status" validate:"required,oneof=enabled paused" example:"paused"`
+	Reason string `json:"reason,omitempty" validate:"max=255" example:"elevated decline rate"`
+}
+
+// ValidateAdminRequest validates the admin request structs
+func ValidateAdminRequest(req interface{}) error {
+	validate := validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,32 +1,43 @@
 package models
 
-import "github.com/go-playground/validator/v10"
+import (
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+)
 
 // BankAccountDetails contains the information needed to create a bank account
 type BankAccountDetails struct {
 	AccountHolderName string `json:"account_holder_name" validate:"required" example:"John Doe"`                            // Name of the account holder
-	AccountNumber     string `json:"account_number" validate:"required" example:"1234567890"`                               // Bank account number
-	RoutingNumber     string `json:"routing_number" validate:"required,len=9" example:"110000614"`                          // Bank routing number (ACH), typically 9 digits in the US
-	Country           string `json:"country" validate:"required" example:"US"`                                        // Two-letter country code (e.g., "US")
+	AccountNumber     string `json:"account_number" validate:"required" example:"1234567890"`                               // Bank account number, or IBAN in IBAN countries
+	RoutingNumber     string `json:"routing_number" example:"110000000"`                                                    // ABA routing number (US), sort code (GB) or IFSC (IN)
+	Country           string `json:"country" validate:"required" example:"US"`                                              // Two-letter country code (e.g., "US")
 	Currency          string `json:"currency" validate:"required,len=3" example:"usd"`                                      // Three-letter currency code (e.g., "usd")
 	AccountHolderType string `json:"account_holder_type" validate:"required,oneof=individual company" example:"individual"` // "individual" or "company"
 }
 
 // CustomWithdrawalRequest represents a withdrawal request payload
 type CustomWithdrawalRequest struct {
-	Amount              int64              `json:"amount" validate:"required,gt=0" example:"5000"`                       // Amount in cents
-	Currency            string             `json:"currency" validate:"required,len=3" example:"usd"`                     // 3-letter ISO code (e.g., "usd")
-	Description         string             `json:"description" validate:"required" example:"Monthly payout"`             // Description of the payout
-	BankDetails         BankAccountDetails `json:"bank_details" validate:"required"`                                     // Custom bank details
-	Method              string             `json:"method" validate:"required,oneof=standard instant" example:"standard"` // "standard" or "instant" (default: "standard")
-	StatementDescriptor string             `json:"statement_descriptor" validate:"max=22" example:"EXINITY PAYOUT"`      // Text on recipient's statement (max 22 chars)
-	Metadata            map[string]string  `json:"metadata" example:"country_id:3,currency:USD,gateway_id:7"`            // Optional additional data
-	UserID              string             `json:"user_id" validate:"required" example:"1"`                              // User ID making the withdrawal
-	GatewayName         string             `json:"gateway_name" validate:"required" example:"DEFAULT_GATEWAY"`           // Name of the gateway
-	GatewayID           string             `json:"gateway_id" validate:"required" example:"7"`                           // ID of the gateway
-	CountryID           string             `json:"country_id" validate:"required" example:"US"`                    // 2-letter ISO country code
+	Amount              int64               `json:"amount" validate:"required,gt=0" example:"5000"`                                                                                     // Amount in cents
+	Currency            string              `json:"currency" validate:"required,len=3" example:"usd"`                                                                                   // 3-letter ISO code (e.g., "usd")
+	Description         string              `json:"description" validate:"required" example:"Monthly payout"`                                                                           // Description of the payout
+	BankDetails         *BankAccountDetails `json:"bank_details,omitempty" validate:"required_without_all=BankDetailsToken BeneficiaryID,excluded_with=BankDetailsToken BeneficiaryID"` // Raw bank details, unless bank_details_token or beneficiary_id is set
+	BankDetailsToken    string              `json:"bank_details_token,omitempty" validate:"excluded_with=BeneficiaryID" example:"btok_3q2-7wEAAAAAAAAAAAAAAA"`                          // Vault token from POST /vault/bank-details
+	BeneficiaryID       int64               `json:"beneficiary_id,omitempty" example:"12"`                                                                                              // Verified beneficiary from POST /beneficiaries
+	Method              string              `json:"method" validate:"required,oneof=standard instant" example:"standard"`                                                               // "standard" or "instant" (default: "standard")
+	StatementDescriptor string              `json:"statement_descriptor" validate:"max=22" example:"EXINITY PAYOUT"`                                                                    // Text on recipient's statement (max 22 chars)
+	Metadata            map[string]string   `json:"metadata" example:"invoice:inv-42,team:payroll"`                                                                                     // Optional additional data, user_id, gateway_id, country_id, hold_id and bank_account are reserved
+	UserID              string              `json:"user_id" validate:"required" example:"1"`                                                                                            // User ID, taken from the token for end users
+	GatewayName         string              `json:"gateway_name" validate:"required" example:"DEFAULT_GATEWAY"`                                                                         // Name of the gateway
+	GatewayID           string              `json:"gateway_id" validate:"required" example:"7"`                                                                                         // ID of the gateway
+	CountryID           string              `json:"country_id" validate:"required" example:"US"`                                                                                        // 2-letter ISO country code
+	HoldID              int64               `json:"-"`                                                                                                                                  // Funds held for an approved review, released once the payout settles
 }
 
+// ReservedMetadataKeys are the payout metadata keys set by the service, the payment
+// event consumer trusts them to post the ledger and release held funds
+var ReservedMetadataKeys = []string{"user_id", "gateway_id", "country_id", "hold_id", "bank_account"}
+
 // ValidateCustomWithdrawalRequest validates the CustomWithdrawalRequest struct
 func ValidateCustomWithdrawalRequest(req CustomWithdrawalRequest) error {
 	validate := validator.New()
@@ -34,5 +45,13 @@ func ValidateCustomWithdrawalRequest(req CustomWithdrawalRequest) error {
 	if err != nil {
 		return err
 	}
+	for _, key := range ReservedMetadataKeys {
+		if _, ok := req.Metadata[key]; ok {
+			return fmt.Errorf("metadata key %s is reserved", key)
+		}
+	}
+	if req.BankDetails != nil {
+		return CheckBankAccount(*req.BankDetails)
+	}
 	return nil
 }
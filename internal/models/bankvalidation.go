@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+var (
+	digitsRe   = regexp.MustCompile(`^[0-9]+$`)
+	ifscRe     = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+	ibanRe     = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	sortCodeRe = regexp.MustCompile(`^[0-9]{2}-?[0-9]{2}-?[0-9]{2}$`)
+)
+
+// ibanLengths lists the IBAN length of the countries that require an IBAN as account number
+var ibanLengths = map[string]int{
+	"AE": 23, "AT": 20, "BE": 16, "CH": 21, "DE": 22, "DK": 18, "ES": 24, "FI": 18,
+	"FR": 27, "GR": 27, "IE": 22, "IT": 27, "LU": 20, "NL": 18, "NO": 15, "PL": 28,
+	"PT": 25, "SA": 24, "SE": 24,
+}
+
+// CheckBankAccount validates the account and routing numbers against the rules of
+// the account's country: ABA routing numbers in the US, sort codes in the UK, IFSC
+// codes in India and IBANs in IBAN countries. Other countries only need an account number.
+func CheckBankAccount(details BankAccountDetails) error {
+	country := strings.ToUpper(details.Country)
+	account := normalizeAccount(details.AccountNumber)
+	routing := strings.ToUpper(strings.TrimSpace(details.RoutingNumber))
+
+	switch {
+	case country == "US":
+		if !validABA(routing) {
+			return fmt.Errorf("routing_number must be a valid 9 digit ABA routing number")
+		}
+		if !digitsRe.MatchString(account) || len(account) < 4 || len(account) > 17 {
+			return fmt.Errorf("account_number must be 4 to 17 digits")
+		}
+	case country == "GB":
+		// UK accounts are paid either by IBAN or by sort code and account number
+		if strings.HasPrefix(account, "GB") {
+			return checkIBAN(account, "GB", 22)
+		}
+		if !sortCodeRe.MatchString(routing) {
+			return fmt.Errorf("routing_number must be a 6 digit sort code")
+		}
+		if !digitsRe.MatchString(account) || len(account) != 8 {
+			return fmt.Errorf("account_number must be 8 digits")
+		}
+	case country == "IN":
+		if !ifscRe.MatchString(routing) {
+			return fmt.Errorf("routing_number must be a valid IFSC code")
+		}
+		if !digitsRe.MatchString(account) || len(account) < 9 || len(account) > 18 {
+			return fmt.Errorf("account_number must be 9 to 18 digits")
+		}
+	case ibanLengths[country] > 0:
+		return checkIBAN(account, country, ibanLengths[country])
+	default:
+		if account == "" {
+			return fmt.Errorf("account_number is required")
+		}
+	}
+	return nil
+}
+
+// normalizeAccount strips the spaces and dashes users type in account numbers and IBANs
+func normalizeAccount(account string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(account))
+}
+
+// validABA checks the length and checksum of a US ABA routing number
+func validABA(routing string) bool {
+	if len(routing) != 9 || !digitsRe.MatchString(routing) {
+		return false
+	}
+	weights := []int{3, 7, 1}
+	sum := 0
+	for i, c := range routing {
+		sum += int(c-'0') * weights[i%3]
+	}
+	return sum%10 == 0
+}
+
+// checkIBAN checks the country, length and mod-97 checksum of an IBAN
+func checkIBAN(iban, country string, length int) error {
+	if !ibanRe.MatchString(iban) || !strings.HasPrefix(iban, country) || len(iban) != length {
+		return fmt.Errorf("account_number must be a %d character %s IBAN", length, country)
+	}
+
+	// Move the country code and check digits to the end and turn letters into numbers
+	var numeric strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			fmt.Fprintf(&numeric, "%d", c-'A'+10)
+		} else {
+			numeric.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	if !ok || new(big.Int).Mod(n, big.NewInt(97)).Int64() != 1 {
+		return fmt.Errorf("account_number has an invalid IBAN checksum")
+	}
+	return nil
+}
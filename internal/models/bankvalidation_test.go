@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBankAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		details BankAccountDetails
+		wantErr bool
+	}{
+		{name: "US valid ABA", details: BankAccountDetails{Country: "US", AccountNumber: "000123456789", RoutingNumber: "110000000"}},
+		{name: "US bad ABA checksum", details: BankAccountDetails{Country: "US", AccountNumber: "000123456789", RoutingNumber: "110000614"}, wantErr: true},
+		{name: "US short routing number", details: BankAccountDetails{Country: "US", AccountNumber: "000123456789", RoutingNumber: "11000000"}, wantErr: true},
+		{name: "GB sort code", details: BankAccountDetails{Country: "GB", AccountNumber: "31926819", RoutingNumber: "60-16-13"}},
+		{name: "GB bad sort code", details: BankAccountDetails{Country: "GB", AccountNumber: "31926819", RoutingNumber: "6016"}, wantErr: true},
+		{name: "GB IBAN", details: BankAccountDetails{Country: "GB", AccountNumber: "GB29 NWBK 6016 1331 9268 19"}},
+		{name: "GB IBAN bad checksum", details: BankAccountDetails{Country: "GB", AccountNumber: "GB28NWBK60161331926819"}, wantErr: true},
+		{name: "IN IFSC", details: BankAccountDetails{Country: "IN", AccountNumber: "1234567890", RoutingNumber: "HDFC0000001"}},
+		{name: "IN bad IFSC", details: BankAccountDetails{Country: "IN", AccountNumber: "1234567890", RoutingNumber: "HDFC1000001"}, wantErr: true},
+		{name: "AE IBAN", details: BankAccountDetails{Country: "AE", AccountNumber: "AE070331234567890123456"}},
+		{name: "AE IBAN wrong country", details: BankAccountDetails{Country: "AE", AccountNumber: "GB29NWBK60161331926819"}, wantErr: true},
+		{name: "DE IBAN bad checksum", details: BankAccountDetails{Country: "DE", AccountNumber: "DE89370400440532013001"}, wantErr: true},
+		{name: "other country needs an account number only", details: BankAccountDetails{Country: "BR", AccountNumber: "12345"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckBankAccount(tt.details)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Beneficiary verification states
+const (
+	BeneficiaryPending  = "pending"  // Saved, not yet usable for withdrawals
+	BeneficiaryVerified = "verified" // Usable for withdrawals
+	BeneficiaryRejected = "rejected" // Failed verification
+)
+
+// Beneficiary is a saved payout bank account of a user. The bank details are
+// held in the vault; only the token and non-sensitive fields are stored here.
+type Beneficiary struct {
+	ID                int64      `json:"id" example:"12"`
+	UserID            string     `json:"user_id" example:"1"`
+	Nickname          string     `json:"nickname" example:"Salary account"`
+	Country           string     `json:"country" example:"GB"`
+	Currency          string     `json:"currency" example:"gbp"`
+	AccountHolderType string     `json:"account_holder_type" example:"individual"`
+	Last4             string     `json:"last4" example:"6819"`
+	BankDetailsToken  string     `json:"-"`
+	Status            string     `json:"status" example:"verified"` // pending, verified or rejected
+	StatusReason      string     `json:"status_reason,omitempty" example:"name mismatch"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty" example:"2025-01-01T10:00:00Z"`
+	CreatedAt         time.Time  `json:"created_at" example:"2025-01-01T10:00:00Z"`
+}
+
+// BeneficiaryRequest saves a new beneficiary
+type BeneficiaryRequest struct {
+	UserID      string             `json:"user_id" validate:"required" example:"1"`             // Taken from the token for end users, required for internal callers
+	Nickname    string             `json:"nickname" validate:"max=64" example:"Salary account"` // Optional label shown to the user
+	BankDetails BankAccountDetails `json:"bank_details" validate:"required"`                    // Bank details, validated against the rules of their country
+}
+
+// ValidateBeneficiaryRequest validates the BeneficiaryRequest struct
+func ValidateBeneficiaryRequest(req BeneficiaryRequest) error {
+	validate := validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		return err
+	}
+	return CheckBankAccount(req.BankDetails)
+}
+
+// BeneficiaryVerificationRequest records the outcome of a beneficiary verification
+type BeneficiaryVerificationRequest struct {
+	Status string `json:"status" validate:"required,oneof=verified rejected" example:"verified"` // verified or rejected
+	Reason string `json:"reason" validate:"required_if=Status rejected" example:"name mismatch"` // Required when rejecting
+}
+
+// ValidateBeneficiaryVerificationRequest validates the BeneficiaryVerificationRequest struct
+func ValidateBeneficiaryVerificationRequest(req BeneficiaryVerificationRequest) error {
+	validate := validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GatewayCapability describes what a gateway supports in a country for one currency
+type GatewayCapability struct {
+	GatewayID          string   `json:"gateway_id" example:"3"`
+	CountryID          string   `json:"country_id" example:"2"`
+	Currency           string   `json:"currency" example:"AED"`
+	MinAmount          int64    `json:"min_amount" example:"100"`                  // Inclusive, smallest currency unit
+	MaxAmount          int64    `json:"max_amount,omitempty" example:"10000000"`   // Inclusive, 0 for no upper limit
+	SupportsDeposit    bool     `json:"supports_deposit" example:"true"`           // Deposits can be accepted
+	SupportsWithdrawal bool     `json:"supports_withdrawal" example:"true"`        // Payouts can be made
+	PayoutMethods      []string `json:"payout_methods" example:"standard,instant"` // Supported payout methods
+	DataFormat         string   `json:"data_format" example:"JSON"`                // Data format spoken by the gateway
+}
+
+// CapabilityRequest is the part of a deposit or withdrawal checked against the catalogue.
+// Empty fields are not checked.
+type CapabilityRequest struct {
+	Type     string // deposit or withdrawal
+	Currency string
+	Amount   int64 // Smallest currency unit, 0 when unknown
+	Method   string
+}
+
+// Supports returns nil when the capability can handle the request, or the reason it cannot
+func (c GatewayCapability) Supports(req CapabilityRequest) error {
+	if req.Currency != "" && !strings.EqualFold(c.Currency, req.Currency) {
+		return fmt.Errorf("currency %s not supported", req.Currency)
+	}
+	switch req.Type {
+	case "deposit":
+		if !c.SupportsDeposit {
+			return fmt.Errorf("deposits not supported in %s", c.Currency)
+		}
+	case "withdrawal":
+		if !c.SupportsWithdrawal {
+			return fmt.Errorf("withdrawals not supported in %s", c.Currency)
+		}
+	}
+	if req.Amount > 0 && req.Amount < c.MinAmount {
+		return fmt.Errorf("amount %d below minimum %d %s", req.Amount, c.MinAmount, c.Currency)
+	}
+	if req.Amount > 0 && c.MaxAmount > 0 && req.Amount > c.MaxAmount {
+		return fmt.Errorf("amount %d above maximum %d %s", req.Amount, c.MaxAmount, c.Currency)
+	}
+	if req.Method != "" {
+		supported := false
+		for _, method := range c.PayoutMethods {
+			if method == req.Method {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("payout method %s not supported in %s", req.Method, c.Currency)
+		}
+	}
+	return nil
+}
+
+// CheckCapabilities returns nil when any of a gateway's capabilities supports the request.
+// A gateway without catalogue entries in the country is not supported there.
+func CheckCapabilities(capabilities []GatewayCapability, req CapabilityRequest) error {
+	if len(capabilities) == 0 {
+		return fmt.Errorf("not supported in this country")
+	}
+
+	var reason error
+	for _, capability := range capabilities {
+		err := capability.Supports(req)
+		if err == nil {
+			return nil
+		}
+		// Prefer the reason from the entry for the requested currency
+		if reason == nil || strings.EqualFold(capability.Currency, req.Currency) {
+			reason = err
+		}
+	}
+	return reason
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCapabilities(t *testing.T) {
+	capabilities := []GatewayCapability{
+		{GatewayID: "3", CountryID: "2", Currency: "AED", MinAmount: 100, MaxAmount: 10000000,
+			SupportsDeposit: true, SupportsWithdrawal: true, PayoutMethods: []string{"standard"}},
+		{GatewayID: "3", CountryID: "2", Currency: "USD", MinAmount: 100,
+			SupportsDeposit: true},
+	}
+
+	tests := []struct {
+		name          string
+		capabilities  []GatewayCapability
+		req           CapabilityRequest
+		expectedError string
+	}{
+		{
+			name:         "supported deposit",
+			capabilities: capabilities,
+			req:          CapabilityRequest{Type: "deposit", Currency: "aed", Amount: 5000},
+		},
+		{
+			name:          "no catalogue entries is unsupported",
+			capabilities:  nil,
+			req:           CapabilityRequest{Type: "withdrawal", Currency: "EUR", Amount: 1},
+			expectedError: "not supported in this country",
+		},
+		{
+			name:          "unsupported currency",
+			capabilities:  capabilities,
+			req:           CapabilityRequest{Type: "deposit", Currency: "EUR"},
+			expectedError: "currency EUR not supported",
+		},
+		{
+			name:          "amount above maximum",
+			capabilities:  capabilities,
+			req:           CapabilityRequest{Type: "deposit", Currency: "AED", Amount: 20000000},
+			expectedError: "amount 20000000 above maximum 10000000 AED",
+		},
+		{
+			name:          "withdrawals not supported in currency",
+			capabilities:  capabilities,
+			req:           CapabilityRequest{Type: "withdrawal", Currency: "USD", Amount: 5000},
+			expectedError: "withdrawals not supported in USD",
+		},
+		{
+			name:          "unsupported payout method",
+			capabilities:  capabilities,
+			req:           CapabilityRequest{Type: "withdrawal", Currency: "AED", Amount: 5000, Method: "instant"},
+			expectedError: "payout method instant not supported in AED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCapabilities(tt.capabilities, tt.req)
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError)
+			}
+		})
+	}
+}
@@ -16,7 +16,7 @@ type APIResponse struct {
 // DepositRequest represents a deposit request payload
 type DepositRequest struct {
 	Amount      string `json:"amount" validate:"required" example:"100"`          // Deposit amount
-	UserID      string `json:"user_id" validate:"required" example:"1"`           // Unique user identifier
+	UserID      string `json:"user_id" validate:"required" example:"1"`           // User ID, taken from the token for end users
 	Currency    string `json:"currency" validate:"required" example:"USD"`        // Currency code
 	GatewayID   string `json:"gateway_id" validate:"required" example:"1"`        // Payment gateway ID
 	GatewayName string `json:"gateway_name" validate:"required" example:"STRIPE"` // Payment gateway name
@@ -35,9 +35,25 @@ func ValidateDepositRequest(req DepositRequest) error {
 
 // Gateway struct for response
 type Gateway struct {
-	ID    string `json:"id" validate:"required" example:"1"`        // Unique gateway identifier
-	Name  string `json:"name" validate:"required" example:"STRIPE"` // Name of the gateway
-	Score int
+	ID           string  `json:"id" validate:"required" example:"1"`        // Unique gateway identifier
+	Name         string  `json:"name" validate:"required" example:"STRIPE"` // Name of the gateway
+	Score        float64 `json:"score" example:"0.92"`                      // Ranking score derived from the success ratio
+	SuccessRatio float64 `json:"success_ratio" example:"0.95"`              // Decayed success ratio over the scoring window
+	SampleSize   int64   `json:"sample_size" example:"120"`                 // Number of outcomes in the scoring window
+
+	Capabilities []GatewayCapability `json:"capabilities,omitempty"` // What the gateway supports in the country
+}
+
+// Error types reported for gateway failures that are not caused by the payer
+const (
+	GatewayErrorTimeout     = "timeout"
+	GatewayErrorUnavailable = "gateway_unavailable"
+)
+
+// GatewayOutcome is a single payment result used to score a gateway
+type GatewayOutcome struct {
+	Success   bool   // Whether the payment or payout succeeded
+	ErrorType string // Gateway failure or decline code, empty on success
 }
 
 // ValidateGateway validates the Gateway struct
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// PaymentEventVersion is the version of the PaymentEvent schema, raised on changes
+// consumers of an older version cannot read
+const PaymentEventVersion = 1
+
+// PaymentEventType is the payment lifecycle step a PaymentEvent reports
+type PaymentEventType string
+
+// Payment event types
+const (
+	DepositCreated   PaymentEventType = "deposit.created"
+	DepositSucceeded PaymentEventType = "deposit.succeeded"
+	DepositFailed    PaymentEventType = "deposit.failed"
+	PayoutCreated    PaymentEventType = "payout.created"
+	PayoutPaid       PaymentEventType = "payout.paid"
+	PayoutFailed     PaymentEventType = "payout.failed"
+	PayoutCanceled   PaymentEventType = "payout.canceled"
+)
+
+// PaymentEventTypes lists the known event types
+var PaymentEventTypes = []PaymentEventType{
+	DepositCreated, DepositSucceeded, DepositFailed,
+	PayoutCreated, PayoutPaid, PayoutFailed, PayoutCanceled,
+}
+
+// PaymentEvent is a gateway webhook normalised by its gateway, whatever the gateway's
+// own event format. It is the only event of the payment events topic.
+type PaymentEvent struct {
+	Version     int               `json:"version" example:"1"`                                    // PaymentEventVersion of the producer
+	Gateway     string            `json:"gateway" example:"STRIPE"`                               // Gateway name
+	EventID     string            `json:"event_id" example:"evt_1NG8Du2eZvKYlo2CUI79vXWy"`        // The gateway's event ID
+	Type        PaymentEventType  `json:"type" example:"deposit.succeeded"`                       // Lifecycle step
+	OrderID     string            `json:"order_id" example:"pi_3NG8Du2eZvKYlo2C0Yq1gT1h"`         // Payment intent or payout ID
+	Amount      int64             `json:"amount" example:"5000"`                                  // Smallest currency unit
+	Currency    string            `json:"currency" example:"usd"`                                 // 3-letter ISO currency code
+	FailureCode string            `json:"failure_code,omitempty" example:"card_declined"`         // Decline or error code of failed events
+	Metadata    map[string]string `json:"metadata" example:"country_id:3,gateway_id:7,user_id:1"` // Set on the payment by the service
+	OccurredAt  time.Time         `json:"occurred_at" example:"2025-01-01T10:00:00Z"`             // When the gateway raised the event
+}
+
+// Deposit tells whether the event is about a deposit rather than a payout
+func (t PaymentEventType) Deposit() bool {
+	switch t {
+	case DepositCreated, DepositSucceeded, DepositFailed:
+		return true
+	}
+	return false
+}
+
+// Validate checks that the event can be handled by a consumer of this version
+func (e PaymentEvent) Validate() error {
+	if e.Version < 1 || e.Version > PaymentEventVersion {
+		return fmt.Errorf("unsupported event version %d", e.Version)
+	}
+	switch {
+	case !slices.Contains(PaymentEventTypes, e.Type):
+		return fmt.Errorf("unknown event type %q", e.Type)
+	case e.Gateway == "":
+		return fmt.Errorf("missing gateway")
+	case e.OrderID == "":
+		return fmt.Errorf("missing order ID")
+	}
+	return nil
+}
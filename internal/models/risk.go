@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Risk decisions, from least to most severe
+const (
+	RiskAllow  = "allow"  // The transaction goes ahead
+	RiskReview = "review" // The transaction is parked until an admin approves it
+	RiskDeny   = "deny"   // The transaction is refused
+)
+
+// Review states
+const (
+	ReviewPending    = "pending"    // Waiting for an admin
+	ReviewProcessing = "processing" // Approved, the transaction is being sent to the gateway
+	ReviewApproved   = "approved"   // Approved and sent to the gateway
+	ReviewRejected   = "rejected"   // Rejected by an admin
+	ReviewFailed     = "failed"     // Approved but the gateway call failed
+)
+
+// RiskRequest describes a deposit or withdrawal to score
+type RiskRequest struct {
+	Type         string     // deposit or withdrawal
+	UserID       string     // User making the transaction
+	CountryID    string     // Country the transaction is made in
+	Currency     string     // Currency code
+	Amount       int64      // Smallest currency unit
+	GatewayID    string     // Requested gateway
+	PayeeAddedAt *time.Time // When the payout bank account was saved, now for raw bank details
+	At           time.Time  // Defaults to now
+}
+
+// RiskSignal is the verdict of one risk rule
+type RiskSignal struct {
+	Rule     string `json:"rule" example:"amount_anomaly"`
+	Decision string `json:"decision" example:"review"` // allow, review or deny
+	Reason   string `json:"reason,omitempty" example:"amount 500000 is more than 5x the average of 12 past deposits"`
+}
+
+// RiskAssessment is the combined verdict of every rule, the most severe decision wins
+type RiskAssessment struct {
+	Decision string       `json:"decision" example:"review"`
+	Signals  []RiskSignal `json:"signals"` // Signals of the rules that did not allow the transaction
+}
+
+// TransactionStats summarises the past transactions of a user, amounts in the smallest currency unit
+type TransactionStats struct {
+	Count   int64
+	Average float64
+	Max     float64
+}
+
+// Review is a transaction parked for manual approval. The request is stored
+// encrypted because withdrawals carry bank details.
+type Review struct {
+	ID          int64           `json:"id" example:"7"`
+	Type        string          `json:"type" example:"withdrawal"` // deposit or withdrawal
+	UserID      string          `json:"user_id" example:"1"`
+	CountryID   string          `json:"country_id" example:"2"`
+	Currency    string          `json:"currency" example:"AED"`
+	Amount      int64           `json:"amount" example:"500000"` // Smallest currency unit
+	GatewayID   string          `json:"gateway_id" example:"3"`
+	Signals     []RiskSignal    `json:"signals"`
+	Payload     string          `json:"-"`
+	Status      string          `json:"status" example:"pending"`              // pending, processing, approved, rejected or failed
+	RequestedBy string          `json:"requested_by" example:"1"`              // Caller that made the request, may not approve it
+	HoldID      int64           `json:"-"`                                     // Funds held while a withdrawal is pending
+	Result      json.RawMessage `json:"result,omitempty" swaggertype:"object"` // Gateway response once approved
+	DecidedBy   string          `json:"decided_by,omitempty" example:"ops-admin"`
+	Reason      string          `json:"reason,omitempty" example:"confirmed with the user by phone"`
+	CreatedAt   time.Time       `json:"created_at" example:"2025-01-01T10:00:00Z"`
+	DecidedAt   *time.Time      `json:"decided_at,omitempty" example:"2025-01-01T10:30:00Z"`
+}
+
+// ReviewDecisionRequest approves or rejects a parked transaction
+type ReviewDecisionRequest struct {
+	Reason string `json:"reason" validate:"max=500" example:"confirmed with the user by phone"` // Required when rejecting
+}
+
+// ValidateReviewDecisionRequest validates the ReviewDecisionRequest struct
+func ValidateReviewDecisionRequest(req ReviewDecisionRequest) error {
+	validate := validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Routing rule actions
+const (
+	RoutingActionRoute   = "route"   // Only the rule's gateway may be used
+	RoutingActionPrefer  = "prefer"  // The rule's gateway is ranked first, others remain as fallback
+	RoutingActionExclude = "exclude" // The rule's gateway is removed from the candidates
+)
+
+// RoutingRule is a declarative gateway selection rule stored in Postgres.
+// Empty or nil conditions match any request.
+type RoutingRule struct {
+	ID              int    `json:"id" example:"1"`
+	Name            string `json:"name" example:"large INR deposits via razorpay"`
+	Priority        int    `json:"priority" example:"10"`  // Lower values are evaluated first
+	Action          string `json:"action" example:"route"` // route, prefer or exclude
+	GatewayID       int    `json:"gateway_id" example:"2"` // Gateway the action applies to
+	TransactionType string `json:"transaction_type,omitempty" example:"deposit"`
+	CountryID       string `json:"country_id,omitempty" example:"1"`
+	Currency        string `json:"currency,omitempty" example:"INR"`
+	MinAmount       *int64 `json:"min_amount,omitempty" example:"1000001"` // Inclusive, smallest currency unit
+	MaxAmount       *int64 `json:"max_amount,omitempty" example:"5000000"` // Inclusive, smallest currency unit
+	UserSegment     string `json:"user_segment,omitempty" example:"vip"`
+	Method          string `json:"method,omitempty" example:"instant"`
+	StartHour       *int   `json:"start_hour,omitempty" example:"9"` // UTC hour the rule becomes active
+	EndHour         *int   `json:"end_hour,omitempty" example:"17"`  // UTC hour the rule stops being active
+	Enabled         bool   `json:"enabled" example:"true"`
+}
+
+// RoutingRequest describes a deposit or withdrawal to route, real or hypothetical
+type RoutingRequest struct {
+	Type        string    `json:"type" validate:"required,oneof=deposit withdrawal" example:"deposit"` // deposit or withdrawal
+	CountryID   string    `json:"country_id" validate:"required" example:"1"`                          // Country ID
+	Currency    string    `json:"currency" example:"INR"`                                              // Currency code
+	Amount      int64     `json:"amount" validate:"gte=0" example:"1500000"`                           // Amount in the smallest currency unit
+	UserID      string    `json:"user_id" example:"1"`                                                 // User ID, used to look up the segment
+	UserSegment string    `json:"user_segment" example:"vip"`                                          // Overrides the stored user segment
+	Method      string    `json:"method" example:"instant"`                                            // Payout method
+	At          time.Time `json:"at" example:"2025-01-01T10:00:00Z"`                                   // Evaluation time, defaults to now
+}
+
+// ValidateRoutingRequest validates the RoutingRequest struct
+func ValidateRoutingRequest(req RoutingRequest) error {
+	validate := validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RuleEvaluation explains why a single rule did or did not match
+type RuleEvaluation struct {
+	RuleID  int    `json:"rule_id" example:"1"`
+	Name    string `json:"name" example:"large INR deposits via razorpay"`
+	Matched bool   `json:"matched" example:"false"`
+	Reason  string `json:"reason" example:"amount 5000 below minimum 1000001"`
+}
+
+// RoutingDecision is the outcome of evaluating the routing rules for a request
+type RoutingDecision struct {
+	Gateways    []Gateway        `json:"gateways"`               // Candidate gateways after the matched rule was applied
+	MatchedRule *RoutingRule     `json:"matched_rule,omitempty"` // First rule that matched, nil when score ranking alone applies
+	Trace       []RuleEvaluation `json:"trace"`                  // Every rule evaluated, in priority order
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// VaultEntry is a tokenized, encrypted record held by the vault
+type VaultEntry struct {
+	Token      string    `json:"token" example:"btok_3q2-7wEAAAAAAAAAAAAAAA"` // Opaque token handed to the client
+	UserID     string    `json:"user_id" example:"1"`                         // Owner of the record
+	Ciphertext string    `json:"-"`                                           // Envelope encrypted payload
+	KeyID      string    `json:"-"`                                           // Key-encryption key that wrapped the data key
+	Last4      string    `json:"last4" example:"7890"`                        // Last four characters of the account number
+	CreatedAt  time.Time `json:"created_at" example:"2025-01-01T10:00:00Z"`
+}
+
+// TokenizeBankDetailsRequest stores bank details in the vault
+type TokenizeBankDetailsRequest struct {
+	UserID      string             `json:"user_id" validate:"required" example:"1"` // Taken from the token for end users, required for internal callers
+	BankDetails BankAccountDetails `json:"bank_details" validate:"required"`        // Bank details to tokenize
+}
+
+// ValidateTokenizeBankDetailsRequest validates the TokenizeBankDetailsRequest struct
+func ValidateTokenizeBankDetailsRequest(req TokenizeBankDetailsRequest) error {
+	validate := validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		return err
+	}
+	return CheckBankAccount(req.BankDetails)
+}
@@ -7,9 +7,10 @@ type Data struct {
 
 // DefaultGatewayEvent represents the event structure for default gateway webhooks
 type DefaultGatewayEvent struct {
-	ID       string `json:"id" example:"fb848efc-2ea4-4de9-bece-d0e640ceb1ad"` // Unique event identifier
-	Amount   int64  `json:"amount" example:"5000"`                             // Amount in cents
-	Currency string `json:"currency" example:"usd"`                            // 3-letter ISO currency code
-	Type     string `json:"type" example:"payment_intent.created"`             // Event type
-	Data     Data   `json:"data"`                                              // Additional event data
+	ID          string `json:"id" example:"fb848efc-2ea4-4de9-bece-d0e640ceb1ad"` // Unique event identifier
+	Amount      int64  `json:"amount" example:"5000"`                             // Amount in cents
+	Currency    string `json:"currency" example:"usd"`                            // 3-letter ISO currency code
+	Type        string `json:"type" example:"payment_intent.created"`             // Event type
+	FailureCode string `json:"failure_code,omitempty" example:"card_declined"`    // Decline or error code on failure events
+	Data        Data   `json:"data"`                                              // Additional event data
 }
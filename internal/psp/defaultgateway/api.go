@@ -0,0 +1,58 @@
+package defaultgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PaymentRequest is the body of POST /v1/deposits and POST /v1/payouts on the gateway API
+type PaymentRequest struct {
+	Amount   int64             `json:"amount"`
+	Currency string            `json:"currency"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// PaymentResponse is the gateway's answer to a PaymentRequest
+type PaymentResponse struct {
+	ID           string `json:"id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// create posts a payment to the gateway API. A retried request with the same
+// idempotency key returns the payment created by the first attempt.
+func (s *DefaultGatewayClient) create(ctx context.Context, path, idempotencyKey string, req PaymentRequest) (PaymentResponse, error) {
+	var res PaymentResponse
+	body, err := json.Marshal(req)
+	if err != nil {
+		return res, fmt.Errorf("failed to encode request: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return res, fmt.Errorf("failed to build request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if s.secretKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.secretKey)
+	}
+	if idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.http.Do(httpReq)
+	if err != nil {
+		return res, fmt.Errorf("failed to call default gateway: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return res, fmt.Errorf("default gateway answered %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return res, fmt.Errorf("failed to decode default gateway response: %v", err)
+	}
+	return res, nil
+}
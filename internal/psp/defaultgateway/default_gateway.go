@@ -1,9 +1,11 @@
 package defaultgateway
 
 import (
-	"os"
-	"payment-gateway/db"
-	"payment-gateway/internal/kafka"
+	"net/http"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/tracing"
+	"time"
 
 	stripe "github.com/stripe/stripe-go/v81"
 )
@@ -12,34 +14,33 @@ import (
 type DefaultGatewayClient struct {
 	secretKey string
 	accountID string
-	kafka     *kafka.Kafka
+	publisher *events.Publisher
+	timeout   time.Duration
+	baseURL   string // The gateway API, payments are stubbed without it
+	http      *http.Client
 }
 
 // Init initializes the Stripe client with API key from environment
-func Init(k *kafka.Kafka, db *db.DB) *DefaultGatewayClient {
-	secretKey := os.Getenv("GATEWAY_SECRET_KEY")
-	accountID := os.Getenv("GATEWAY_ACCOUNT_ID")
+func Init(cfg config.DefaultGateway, publisher *events.Publisher) *DefaultGatewayClient {
+	secretKey := cfg.SecretKey
+	accountID := cfg.AccountID
 
-	// if secretKey == "" || accountID == "" {
-	// 	panic(fmt.Sprintf("missing Stripe secret key: %s or account ID: %s", secretKey, accountID))
-	// }
-
-	stripe.Key = secretKey
+	// The key is optional, without one the gateway shares the Stripe key
+	if secretKey != "" {
+		stripe.Key = secretKey
+	}
 
 	client := &DefaultGatewayClient{
 		secretKey: secretKey,
 		accountID: accountID,
-		kafka:     k,
+		publisher: publisher,
+		timeout:   cfg.Timeout,
+		baseURL:   cfg.URL,
+		http:      &http.Client{Transport: tracing.Transport(http.DefaultTransport)},
 	}
-	go client.kafka.ConsumeDefaultGatewayWebhook(client.GetTopic(), db, client.HandleWebhook)
-
 	return client
 }
 
 func (s *DefaultGatewayClient) GetName() string {
 	return "DEFAULT_GATEWAY"
 }
-
-func (s *DefaultGatewayClient) GetTopic() string {
-	return "gateway.default-gateway"
-}
@@ -1,16 +1,18 @@
 package defaultgateway
 
 import (
+	"context"
 	"fmt"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp"
 	"strconv"
 
 	"github.com/google/uuid"
-	"github.com/stripe/stripe-go"
+	"github.com/stripe/stripe-go/v81"
 )
 
 // Deposit creates a payment intent in Stripe for accepting money
-func (s *DefaultGatewayClient) Deposit(req models.DepositRequest) (string, string, error) {
+func (s *DefaultGatewayClient) Deposit(ctx context.Context, req models.DepositRequest) (string, string, error) {
 	amount, err := strconv.ParseInt(req.Amount, 10, 64)
 	if err != nil {
 		panic(fmt.Sprintf("invalid amount: %s", req.Amount))
@@ -27,6 +29,20 @@ func (s *DefaultGatewayClient) Deposit(req models.DepositRequest) (string, strin
 		"country_id":   req.CountryID,
 	}
 
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if s.baseURL != "" {
+		ctx, cancel := psp.WithTimeout(ctx, s.timeout)
+		defer cancel()
+		res, err := s.create(ctx, "/v1/deposits",
+			psp.IdempotencyKey(ctx, "deposit", req.UserID, req.GatewayID, req.CountryID, req.Currency, req.Amount), PaymentRequest{Amount: amount, Currency: req.Currency, Metadata: params.Metadata})
+		if err != nil {
+			return "", "", err
+		}
+		return res.ID, res.ClientSecret, nil
+	}
+
 	intentID := uuid.New().String()
 	intentClientSecret := uuid.New().String()
 
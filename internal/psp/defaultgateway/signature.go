@@ -0,0 +1,68 @@
+package defaultgateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the webhook signature, "t=<unix seconds>,v1=<hex HMAC-SHA256>"
+// of "<t>.<body>" keyed by the webhook secret
+const SignatureHeader = "X-Gateway-Signature"
+
+// SignatureTolerance is how old a signed webhook may be, older ones are replays
+const SignatureTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned by VerifySignature
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature header value of payload at t
+func Sign(payload []byte, secret string, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + signature(payload, secret, timestamp)
+}
+
+// VerifySignature checks header against payload and rejects signatures older than
+// SignatureTolerance
+func VerifySignature(payload []byte, header, secret string, now time.Time) error {
+	if secret == "" {
+		return fmt.Errorf("%w: no webhook secret configured", ErrInvalidSignature)
+	}
+	var timestamp, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			sig = value
+		}
+	}
+	if timestamp == "" || sig == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", ErrInvalidSignature)
+	}
+	if now.Sub(time.Unix(unix, 0)) > SignatureTolerance {
+		return fmt.Errorf("%w: timestamp too old", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(payload, secret, timestamp))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signature(payload []byte, secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
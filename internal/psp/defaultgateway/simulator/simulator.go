@@ -0,0 +1,266 @@
+// Package simulator is a local stand-in for the default gateway API. It accepts
+// deposits and payouts and delivers their signed webhooks back to the service,
+// following a scenario picked by the amount.
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp/defaultgateway"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scenario is the sequence of webhooks a payment goes through
+type Scenario string
+
+const (
+	Success    Scenario = "success"      // created, then succeeded or paid
+	Decline    Scenario = "decline"      // created, then failed
+	Delayed    Scenario = "delayed"      // created, then succeeded after LongDelay
+	Duplicate  Scenario = "duplicate"    // created, then the final event twice
+	OutOfOrder Scenario = "out_of_order" // the final event before created
+)
+
+// scenarioByCents picks a scenario by the last two digits of the amount, the other
+// amounts follow Config.Scenario
+var scenarioByCents = map[int64]Scenario{
+	1: Decline,
+	2: Delayed,
+	3: Duplicate,
+	4: OutOfOrder,
+}
+
+// Config configures the simulator
+type Config struct {
+	WebhookURL    string        // e.g. http://localhost:8080/webhook/default-gateway
+	WebhookSecret string        // Signs the webhooks, they are sent unsigned without it
+	Scenario      Scenario      // For amounts that do not pick one
+	Delay         time.Duration // Before each webhook
+	LongDelay     time.Duration // Before the final webhook of the delayed scenario
+	Retries       int           // Extra delivery attempts of a webhook answered with an error
+}
+
+// Simulator serves the gateway API
+type Simulator struct {
+	cfg     Config
+	client  *http.Client
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending sync.WaitGroup
+
+	mu   sync.Mutex
+	seen map[string]defaultgateway.PaymentResponse // By path and Idempotency-Key
+}
+
+// step is a webhook delivered after delay
+type step struct {
+	delay time.Duration
+	event models.DefaultGatewayEvent
+}
+
+// New validates cfg and returns a simulator
+func New(cfg Config) (*Simulator, error) {
+	if cfg.WebhookURL == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	if !valid(cfg.Scenario) {
+		return nil, fmt.Errorf("unknown scenario %q, expected %s", cfg.Scenario, strings.Join(Scenarios(), ", "))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Simulator{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		ctx:    ctx,
+		cancel: cancel,
+		seen:   make(map[string]defaultgateway.PaymentResponse),
+	}, nil
+}
+
+// Scenarios lists the scenario names
+func Scenarios() []string {
+	names := []string{string(Success)}
+	for _, scenario := range scenarioByCents {
+		names = append(names, string(scenario))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handler serves POST /v1/deposits and POST /v1/payouts
+func (s *Simulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/deposits", func(w http.ResponseWriter, r *http.Request) {
+		s.create(w, r, "payment_intent")
+	})
+	mux.HandleFunc("POST /v1/payouts", func(w http.ResponseWriter, r *http.Request) {
+		s.create(w, r, "payout")
+	})
+	return mux
+}
+
+// Close stops the pending deliveries and waits for them
+func (s *Simulator) Close() {
+	s.cancel()
+	s.pending.Wait()
+}
+
+// create answers with the payment ID and schedules its webhooks. A repeated
+// Idempotency-Key gets the first answer and no new webhooks.
+func (s *Simulator) create(w http.ResponseWriter, r *http.Request, object string) {
+	var req defaultgateway.PaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Amount <= 0 || req.Currency == "" {
+		http.Error(w, "Bad Request: amount and currency are required", http.StatusBadRequest)
+		return
+	}
+
+	key := r.Header.Get("Idempotency-Key")
+	s.mu.Lock()
+	res, replayed := s.seen[r.URL.Path+":"+key]
+	if !replayed {
+		res = defaultgateway.PaymentResponse{ID: uuid.New().String()}
+		if object == "payment_intent" {
+			res.ClientSecret = uuid.New().String()
+		}
+		if key != "" {
+			s.seen[r.URL.Path+":"+key] = res
+		}
+	}
+	s.mu.Unlock()
+
+	if !replayed {
+		scenario := s.scenario(req.Amount)
+		slog.InfoContext(r.Context(), "simulated payment created", "object", object, "id", res.ID, "scenario", scenario)
+		s.schedule(s.steps(object, scenario, res.ID, req))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// scenario picks the scenario of amount
+func (s *Simulator) scenario(amount int64) Scenario {
+	if scenario, ok := scenarioByCents[amount%100]; ok {
+		return scenario
+	}
+	return s.cfg.Scenario
+}
+
+// steps returns the webhooks of a payment in delivery order
+func (s *Simulator) steps(object string, scenario Scenario, id string, req defaultgateway.PaymentRequest) []step {
+	event := func(suffix, failureCode string) models.DefaultGatewayEvent {
+		return models.DefaultGatewayEvent{
+			ID:          id,
+			Amount:      req.Amount,
+			Currency:    strings.ToLower(req.Currency),
+			Type:        object + "." + suffix,
+			FailureCode: failureCode,
+			Data:        models.Data{Metadata: req.Metadata},
+		}
+	}
+	created := event("created", "")
+	final := event("succeeded", "")
+	switch {
+	case object == "payout" && scenario == Decline:
+		final = event("failed", "account_closed")
+	case object == "payout":
+		final = event("paid", "")
+	case scenario == Decline:
+		final = event("payment_failed", "card_declined")
+	}
+
+	switch scenario {
+	case Delayed:
+		return []step{{s.cfg.Delay, created}, {s.cfg.LongDelay, final}}
+	case Duplicate:
+		return []step{{s.cfg.Delay, created}, {s.cfg.Delay, final}, {s.cfg.Delay, final}}
+	case OutOfOrder:
+		return []step{{s.cfg.Delay, final}, {s.cfg.Delay, created}}
+	default:
+		return []step{{s.cfg.Delay, created}, {s.cfg.Delay, final}}
+	}
+}
+
+// schedule delivers the steps one after the other in the background
+func (s *Simulator) schedule(steps []step) {
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		for _, step := range steps {
+			select {
+			case <-time.After(step.delay):
+			case <-s.ctx.Done():
+				return
+			}
+			if err := s.deliver(step.event); err != nil {
+				slog.Error("webhook delivery failed", "id", step.event.ID, "type", step.event.Type, "error", err)
+			}
+		}
+	}()
+}
+
+// deliver posts a signed webhook, retrying with a growing pause while the service
+// answers with an error
+func (s *Simulator) deliver(event models.DefaultGatewayEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	for attempt := 0; ; attempt++ {
+		err = s.post(payload)
+		if err == nil {
+			slog.Info("webhook delivered", "id", event.ID, "type", event.Type)
+			return nil
+		}
+		if attempt >= s.cfg.Retries {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(attempt+1) * time.Second):
+		case <-s.ctx.Done():
+			return err
+		}
+	}
+}
+
+func (s *Simulator) post(payload []byte) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.WebhookSecret != "" {
+		req.Header.Set(defaultgateway.SignatureHeader, defaultgateway.Sign(payload, s.cfg.WebhookSecret, time.Now()))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func valid(scenario Scenario) bool {
+	for _, name := range Scenarios() {
+		if string(scenario) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp/defaultgateway"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator(t *testing.T) {
+	const secret = "whsec_simulator"
+	events := make(chan models.DefaultGatewayEvent, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		if err := defaultgateway.VerifySignature(payload, r.Header.Get(defaultgateway.SignatureHeader), secret, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var event models.DefaultGatewayEvent
+		json.Unmarshal(payload, &event)
+		events <- event
+	}))
+	defer receiver.Close()
+
+	sim, err := New(Config{WebhookURL: receiver.URL, WebhookSecret: secret, Scenario: Success, Delay: time.Millisecond, LongDelay: 5 * time.Millisecond})
+	require.NoError(t, err)
+	defer sim.Close()
+	gateway := httptest.NewServer(sim.Handler())
+	defer gateway.Close()
+
+	tests := []struct {
+		name   string
+		path   string
+		amount int64
+		types  []string
+	}{
+		{name: "deposit success", path: "/v1/deposits", amount: 5000, types: []string{"payment_intent.created", "payment_intent.succeeded"}},
+		{name: "deposit decline", path: "/v1/deposits", amount: 5001, types: []string{"payment_intent.created", "payment_intent.payment_failed"}},
+		{name: "deposit delayed", path: "/v1/deposits", amount: 5002, types: []string{"payment_intent.created", "payment_intent.succeeded"}},
+		{name: "payout duplicate", path: "/v1/payouts", amount: 5003, types: []string{"payout.created", "payout.paid", "payout.paid"}},
+		{name: "payout out of order", path: "/v1/payouts", amount: 5004, types: []string{"payout.paid", "payout.created"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := create(t, gateway.URL+tt.path, tt.name, defaultgateway.PaymentRequest{
+				Amount: tt.amount, Currency: "USD", Metadata: map[string]string{"user_id": "1"},
+			})
+			var types []string
+			for range tt.types {
+				select {
+				case event := <-events:
+					assert.Equal(t, res.ID, event.ID)
+					assert.Equal(t, "usd", event.Currency)
+					assert.Equal(t, "1", event.Data.Metadata["user_id"])
+					types = append(types, event.Type)
+				case <-time.After(time.Second):
+					t.Fatalf("webhooks %v, expected %v", types, tt.types)
+				}
+			}
+			assert.Equal(t, tt.types, types)
+
+			// A retried request gets the same payment and no new webhooks
+			assert.Equal(t, res, create(t, gateway.URL+tt.path, tt.name, defaultgateway.PaymentRequest{Amount: tt.amount, Currency: "USD"}))
+			select {
+			case event := <-events:
+				t.Fatalf("unexpected webhook %s", event.Type)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func create(t *testing.T, url, idempotencyKey string, body defaultgateway.PaymentRequest) defaultgateway.PaymentResponse {
+	payload, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var res defaultgateway.PaymentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	require.NotEmpty(t, res.ID)
+	return res
+}
@@ -2,232 +2,65 @@ package defaultgateway
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"payment-gateway/db"
-	database "payment-gateway/db/db"
-	"payment-gateway/db/redis"
+	"log/slog"
+	"payment-gateway/internal/events"
 	"payment-gateway/internal/models"
 	"time"
 )
 
-func (s *DefaultGatewayClient) PublishWebhookToKafka(ev any) error {
-	e := ev.(*models.DefaultGatewayEvent)
-	err := s.kafka.PublishData(s.GetTopic(), e.ID, e)
-	if err != nil {
-		log.Printf("Failed to publish data: %v", err)
-	}
-	return nil
-}
-
-// HandleWebhook processes incoming Stripe webhook events
-func (s *DefaultGatewayClient) HandleWebhook(ev any, db *db.DB) error {
-	e := ev.(*models.DefaultGatewayEvent)
-
-	switch e.Type {
-	// Deposit handlers
-	case "payment_intent.succeeded":
-		return s.handlePaymentIntentSucceeded(e, db)
-	case "payment_intent.payment_failed":
-		return s.handlePaymentIntentFailed(e, db)
-	case "payment_intent.created":
-		return s.handlePaymentIntentCreated(e, db)
-
-	// Withdrawal handlers
-	case "payout.created":
-		return s.handlePayoutCreated(e, db.Redis)
-	case "payout.paid":
-		return s.handlePayoutCompleted(e, db)
-	case "payout.failed":
-		return s.handlePayoutFailed(e, db)
-	case "payout.canceled":
-		return s.handlePayoutCancelled(e, db.Redis)
-
-	default:
-		log.Printf("Unhandled event type: %s", e.Type)
-	}
-
-	return nil
-}
-
-// handlePaymentIntentSucceeded handles successful payments
-func (s *DefaultGatewayClient) handlePaymentIntentSucceeded(e *models.DefaultGatewayEvent, db *db.DB) error {
-	metadata, err := validateMetadata(e.Data.Metadata)
-	if err != nil {
-		log.Printf("❌ Error converting gateway_id to int: %v", err)
-		return fmt.Errorf("invalid gateway_id format: %v", err)
-	}
-
-	data := map[string]interface{}{
-		"status": "success",
-	}
-
-	key := fmt.Sprintf("deposit:userid:%s:orderid:%s", e.Data.Metadata["user_id"], e.ID)
-	if err := db.Redis.HSet(context.TODO(), key, data); err != nil {
-		log.Println("Error storing data in redis:", err.Error())
-		return fmt.Errorf("failed to store data in redis: %v", err.Error())
-	}
-
-	db.Redis.IncrementGatewayScore(context.Background(),
-		e.Data.Metadata["country_id"],
-		e.Data.Metadata["gateway_id"])
-
-	err = db.DB.CreateTransaction(database.Transaction{
-		OrderID:   e.ID,
-		Amount:    float64(e.Amount) / 100,
-		Status:    "success",
-		Type:      "credit",
-		GatewayID: metadata["gateway_id"],
-		CountryID: metadata["country_id"],
-		UserID:    metadata["user_id"],
-		Currency:  e.Currency,
-	})
-	if err != nil {
-		log.Println("Error storing deposit transaction data in db:", err.Error())
-		return fmt.Errorf("failed to store deposit transaction data in db: %v", err.Error())
-	}
-	log.Printf("✅ Payment successful: Amount: %d", e.Amount)
-	return nil
-}
-
-// handlePaymentIntentSucceeded handles successful payments
-func (s *DefaultGatewayClient) handlePaymentIntentFailed(e *models.DefaultGatewayEvent, db *db.DB) error {
-
-	data := map[string]interface{}{
-		"status": "failed",
-	}
-
-	key := fmt.Sprintf("deposit:userid:%s:orderid:%s", e.Data.Metadata["user_id"], e.ID)
-	if err := db.Redis.HSet(context.TODO(), key, data); err != nil {
-		log.Println("Error storing data in redis:", err.Error())
-		return fmt.Errorf("failed to store data in redis: %v", err.Error())
-	}
-
-	db.Redis.DecrementGatewayScore(context.Background(),
-		e.Data.Metadata["country_id"],
-		e.Data.Metadata["gateway_id"])
-
-	log.Printf("❌ Payment failed: Amount: %d", e.Amount)
-	return nil
-}
-
-// handlePaymentIntentSucceeded handles successful payments
-func (s *DefaultGatewayClient) handlePaymentIntentCreated(e *models.DefaultGatewayEvent, db *db.DB) error {
-	data := map[string]interface{}{
-		"status": "pending",
-	}
-
-	key := fmt.Sprintf("deposit:userid:%s:orderid:%s", e.Data.Metadata["user_id"], e.ID)
-	if err := db.Redis.HSet(context.TODO(), key, data); err != nil {
-		log.Println("Error storing data in redis:", err.Error())
-		return fmt.Errorf("failed to store data in redis: %v", err.Error())
-	}
-
-	db.Redis.DecrementGatewayScore(context.Background(),
-		e.Data.Metadata["country_id"],
-		e.Data.Metadata["gateway_id"])
-
-	log.Printf("Payment Intent Pending ID: %s", e.ID)
-	return nil
-}
-
-// handlePayoutCreated handles newly created payouts
-func (s *DefaultGatewayClient) handlePayoutCreated(e *models.DefaultGatewayEvent, redisClient redis.IRedis) error {
-
-	data := map[string]interface{}{
-		"status":     "created",
-		"created_at": time.Now().Unix(),
-		"amount":     e.Amount,
-		"currency":   string(e.Currency),
-	}
-
-	key := fmt.Sprintf("withdrawal:userid:%s:payoutid:%s", e.Data.Metadata["user_id"], e.ID)
-	if err := redisClient.HSet(context.TODO(), key, data); err != nil {
-		log.Println("Error storing withdrawal data in redis:", err.Error())
-		return fmt.Errorf("failed to store withdrawal data in redis: %v", err.Error())
-	}
-
-	log.Printf("✅ Payout created: ID: %s, Amount: %d %s", e.ID, e.Amount, e.Currency)
-	return nil
+// eventTypes maps the default gateway events to payment events
+var eventTypes = map[string]models.PaymentEventType{
+	"payment_intent.created":        models.DepositCreated,
+	"payment_intent.succeeded":      models.DepositSucceeded,
+	"payment_intent.payment_failed": models.DepositFailed,
+	"payout.created":                models.PayoutCreated,
+	"payout.paid":                   models.PayoutPaid,
+	"payout.failed":                 models.PayoutFailed,
+	"payout.canceled":               models.PayoutCanceled,
 }
 
-// handlePayoutCompleted handles newly created payouts
-func (s *DefaultGatewayClient) handlePayoutCompleted(e *models.DefaultGatewayEvent, db *db.DB) error {
-	// Convert string metadata to integers
-	metadata, err := validateMetadata(e.Data.Metadata)
-	if err != nil {
-		log.Printf("❌ Error converting gateway_id to int: %v", err)
-		return fmt.Errorf("invalid gateway_id format: %v", err)
-	}
-
-	data := map[string]interface{}{
-		"status":       "completed",
-		"completed_at": time.Now().Unix(),
-	}
-
-	key := fmt.Sprintf("withdrawal:userid:%s:payoutid:%s", e.Data.Metadata["user_id"], e.ID)
-	if err := db.Redis.HSet(context.TODO(), key, data); err != nil {
-		log.Println("Error storing withdrawal data in redis:", err.Error())
-		return fmt.Errorf("failed to store withdrawal data in redis: %v", err.Error())
+// PublishWebhook publishes the payment event of a verified webhook payload. A failed
+// publish is returned so the webhook is answered with an error and redelivered.
+func (s *DefaultGatewayClient) PublishWebhook(ctx context.Context, payload []byte) error {
+	e, err := s.paymentEvent(payload, time.Now())
+	if errors.Is(err, events.ErrUnhandled) {
+		slog.WarnContext(ctx, "unhandled default gateway event", "error", err)
+		return nil
 	}
-
-	err = db.DB.CreateTransaction(database.Transaction{
-		OrderID:   e.ID,
-		Amount:    -float64(e.Amount) / 100,
-		Status:    "success",
-		Type:      "debit",
-		GatewayID: metadata["gateway_id"],
-		CountryID: metadata["country_id"],
-		UserID:    metadata["user_id"],
-		Currency:  e.Currency,
-	})
 	if err != nil {
-		log.Println("Error storing withdrawal transaction data in db:", err.Error())
-		return fmt.Errorf("failed to store withdrawal transaction data in db: %v", err.Error())
-	}
-
-	log.Printf("✅ Payout succcess: ID: %s, Amount: %d %s", e.ID, e.Amount, e.Currency)
-	return nil
-}
-
-// handlePayoutFailed handles newly created payouts
-func (s *DefaultGatewayClient) handlePayoutFailed(e *models.DefaultGatewayEvent, db *db.DB) error {
-
-	_, err := validateMetadata(e.Data.Metadata)
-	if err != nil {
-		log.Printf("❌ Error converting gateway_id to int: %v", err)
-		return fmt.Errorf("invalid gateway_id format: %v", err)
-	}
-
-	data := map[string]interface{}{
-		"status":    "failed",
-		"failed_at": time.Now().Unix(),
+		return err
 	}
-
-	key := fmt.Sprintf("withdrawal:userid:%s:payoutid:%s", e.Data.Metadata["user_id"], e.ID)
-	if err := db.Redis.HSet(context.TODO(), key, data); err != nil {
-		log.Println("Error storing withdrawal data in redis:", err.Error())
-		return fmt.Errorf("failed to store withdrawal data in redis: %v", err.Error())
+	if err := s.publisher.Publish(ctx, e); err != nil {
+		return fmt.Errorf("failed to publish default gateway event %s: %w", e.EventID, err)
 	}
-
-	log.Printf("✅ Payout Failed: ID: %s, Amount: %d %s", e.ID, e.Amount, e.Currency)
 	return nil
 }
 
-// handlePayoutCancelled handles newly created payouts
-func (s *DefaultGatewayClient) handlePayoutCancelled(e *models.DefaultGatewayEvent, redisClient redis.IRedis) error {
-
-	data := map[string]interface{}{
-		"status":       "cancelled",
-		"cancelled_at": time.Now().Unix(),
-	}
-
-	key := fmt.Sprintf("withdrawal:userid:%s:payoutid:%s", e.Data.Metadata["user_id"], e.ID)
-	if err := redisClient.HSet(context.TODO(), key, data); err != nil {
-		log.Println("Error storing withdrawal data in redis:", err.Error())
-		return fmt.Errorf("failed to store withdrawal data in redis: %v", err.Error())
-	}
-
-	log.Printf("✅ Payout Cancelled: ID: %s, Amount: %d %s", e.ID, e.Amount, e.Currency)
-	return nil
+// paymentEvent normalises a default gateway event received at now, the gateway's
+// events carry no timestamp
+func (s *DefaultGatewayClient) paymentEvent(payload []byte, now time.Time) (models.PaymentEvent, error) {
+	var ev models.DefaultGatewayEvent
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return models.PaymentEvent{}, fmt.Errorf("failed to parse default gateway event: %v", err)
+	}
+	eventType, ok := eventTypes[ev.Type]
+	if !ok {
+		return models.PaymentEvent{}, fmt.Errorf("%w %s of type %s", events.ErrUnhandled, ev.ID, ev.Type)
+	}
+
+	// The gateway names its events after the payment or payout
+	return models.PaymentEvent{
+		Gateway:     s.GetName(),
+		EventID:     ev.ID,
+		Type:        eventType,
+		OrderID:     ev.ID,
+		Amount:      ev.Amount,
+		Currency:    ev.Currency,
+		FailureCode: ev.FailureCode,
+		Metadata:    ev.Data.Metadata,
+		OccurredAt:  now.UTC(),
+	}, nil
 }
@@ -1,18 +1,21 @@
 package defaultgateway
 
 import (
+	"context"
 	"fmt"
 	"payment-gateway/db"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp"
+	"payment-gateway/internal/risk"
 	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
-	"github.com/stripe/stripe-go"
+	"github.com/stripe/stripe-go/v81"
 )
 
 // Withdrawal handles the full process of creating a bank account and making a payout
-func (s *DefaultGatewayClient) Withdrawal(req models.CustomWithdrawalRequest, db *db.DB) (string, error) {
+func (s *DefaultGatewayClient) Withdrawal(ctx context.Context, req models.CustomWithdrawalRequest, db *db.DB) (string, error) {
 	userID, err := s.parseUserID(req.UserID)
 	if err != nil {
 		return "", err
@@ -23,12 +26,23 @@ func (s *DefaultGatewayClient) Withdrawal(req models.CustomWithdrawalRequest, db
 		return "", err
 	}
 
-	if err := s.checkBalance(db, userID, req.Currency, amount); err != nil {
+	ctx, cancel := psp.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if err := s.checkBalance(ctx, db, userID, req.Currency, amount, req.HoldID); err != nil {
 		return "", err
 	}
 
-	// payoutParams := s.buildPayoutParams(req)
-	payoutID := uuid.New().String() // Placeholder; replace with actual payout creation if needed
+	if s.baseURL != "" {
+		params := s.buildPayoutParams(req)
+		res, err := s.create(ctx, "/v1/payouts",
+			psp.IdempotencyKey(ctx, "withdrawal", req.UserID, req.GatewayID, req.CountryID, req.Currency,
+				strconv.FormatInt(req.Amount, 10), strconv.FormatInt(req.HoldID, 10)), PaymentRequest{Amount: req.Amount, Currency: *params.Currency, Metadata: params.Metadata})
+		if err != nil {
+			return "", err
+		}
+		return res.ID, nil
+	}
+	payoutID := uuid.New().String() // Placeholder without a gateway API
 
 	return payoutID, nil
 }
@@ -51,15 +65,16 @@ func (s *DefaultGatewayClient) convertAmount(amount int64) (float64, error) {
 	return float64(amount) / 100, nil
 }
 
-// checkBalance verifies if the user has sufficient funds
-func (s *DefaultGatewayClient) checkBalance(db *db.DB, userID int, currency string, amount float64) error {
-	hasEnough, currentBalance, err := db.DB.CheckUserBalance(userID, strings.ToLower(currency), amount)
+// checkBalance verifies if the user has sufficient funds, counting the funds held
+// for the withdrawal itself
+func (s *DefaultGatewayClient) checkBalance(ctx context.Context, db *db.DB, userID int, currency string, amount float64, holdID int64) error {
+	hasEnough, currentBalance, err := db.DB.CheckUserBalance(ctx, userID, strings.ToLower(currency), amount, holdID)
 	if err != nil {
 		return err
 	}
 	if !hasEnough {
-		return fmt.Errorf("insufficient balance: current balance %.2f %s, requested %.2f %s",
-			currentBalance, currency, amount, currency)
+		return fmt.Errorf("%w: current balance %.2f %s, requested %.2f %s",
+			risk.ErrInsufficientFunds, currentBalance, currency, amount, currency)
 	}
 	return nil
 }
@@ -73,7 +88,7 @@ func (s *DefaultGatewayClient) buildPayoutParams(req models.CustomWithdrawalRequ
 
 	// Set optional parameters
 	s.setOptionalPayoutParams(params, req)
-	s.addPayoutMetadata(params, req)
+	params.Metadata = psp.PayoutMetadata(req)
 
 	return params
 }
@@ -96,23 +111,3 @@ func (s *DefaultGatewayClient) setOptionalPayoutParams(params *stripe.PayoutPara
 		params.StatementDescriptor = stripe.String(statementDescriptor)
 	}
 }
-
-// addPayoutMetadata adds metadata to payout parameters
-func (s *DefaultGatewayClient) addPayoutMetadata(params *stripe.PayoutParams, req models.CustomWithdrawalRequest) {
-	metadata := make(map[string]string)
-
-	// Copy existing metadata
-	if req.Metadata != nil {
-		for k, v := range req.Metadata {
-			metadata[k] = v
-		}
-	}
-
-	// Add required metadata
-	metadata["user_id"] = req.UserID
-	metadata["bank_account_holder"] = req.BankDetails.AccountHolderName
-	metadata["gateway_id"] = req.GatewayID
-	metadata["country_id"] = req.CountryID
-
-	params.Metadata = metadata
-}
@@ -1,15 +1,79 @@
 package psp
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"payment-gateway/db"
+	"payment-gateway/internal/auth"
+	"payment-gateway/internal/logging"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/services"
+	"strconv"
+	"time"
 )
 
+// IPSP is a payment gateway. Deposit and Withdrawal stop when ctx is done and bound
+// the gateway call by the gateway's timeout.
 type IPSP interface {
-	Deposit(reqBody models.DepositRequest) (string, string, error)
+	Deposit(ctx context.Context, reqBody models.DepositRequest) (string, string, error)
 	// GetPaymentInfo(orderID, amountInPaisa, currency string) interface{}
-	Withdrawal(req models.CustomWithdrawalRequest, db *db.DB) (string, error)
+	Withdrawal(ctx context.Context, req models.CustomWithdrawalRequest, db *db.DB) (string, error)
 	GetName() string
-	HandleWebhook(event any, db *db.DB) error
-	PublishWebhookToKafka(ev any) error
+	// PublishWebhook normalises a verified webhook payload into a models.PaymentEvent
+	// and publishes it on the payment events topic
+	PublishWebhook(ctx context.Context, payload []byte) error
+}
+
+// WithTimeout bounds a gateway call, a zero timeout leaves ctx as is
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// IdempotencyKey returns the gateway idempotency key of an operation. The caller
+// controls X-Request-ID, so the request ID is scoped to the authenticated caller, the
+// user and the order: a retry of the same order with the same request ID after a
+// timeout gets the object created by the first attempt, the same request ID sent by
+// another caller, for another user or another order never does. The key is empty
+// without a request ID.
+func IdempotencyKey(ctx context.Context, operation, userID string, order ...string) string {
+	requestID := logging.RequestID(ctx)
+	if requestID == "" {
+		return ""
+	}
+	identity, _ := auth.FromContext(ctx)
+	hash := sha256.New()
+	for _, part := range append([]string{identity.Method, identity.Subject, userID, requestID}, order...) {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return operation + ":" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// PayoutMetadata returns the metadata the gateways attach to a payout: the caller's
+// metadata and the IDs the payment event consumer needs to post the ledger. Only
+// the masked account number, metadata is visible in the gateway dashboard. The
+// caller's metadata never sets the reserved keys.
+func PayoutMetadata(req models.CustomWithdrawalRequest) map[string]string {
+	metadata := make(map[string]string, len(req.Metadata)+4)
+	for k, v := range req.Metadata {
+		metadata[k] = v
+	}
+	for _, key := range models.ReservedMetadataKeys {
+		delete(metadata, key)
+	}
+	metadata["user_id"] = req.UserID
+	metadata["gateway_id"] = req.GatewayID
+	metadata["country_id"] = req.CountryID
+	if req.BankDetails != nil {
+		metadata["bank_account"] = services.MaskAccountNumber(req.BankDetails.AccountNumber)
+	}
+	// The consumer releases the hold of an approved withdrawal once the payout settles
+	if req.HoldID != 0 {
+		metadata["hold_id"] = strconv.FormatInt(req.HoldID, 10)
+	}
+	return metadata
 }
@@ -1,29 +1,130 @@
 package psp
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"payment-gateway/internal/models"
+	"sort"
 	"sync"
+	"time"
 )
 
+var (
+	// ErrNotFound is returned for a gateway that is not registered
+	ErrNotFound = errors.New("not found")
+	// ErrPaused is returned by Available for a gateway taken out of rotation
+	ErrPaused = errors.New("paused")
+)
+
+// PSP is the registry of the gateways configured at startup. Their state follows the
+// enabled flag of the gateways table: a paused gateway still serves its webhooks but
+// takes no new payments.
 type PSP struct {
 	mu       sync.RWMutex
 	gateways map[string]IPSP
+	states   map[string]models.GatewayState // Kept for unregistered gateways too
 }
 
 func Init(psp []IPSP) *PSP {
-	p := make(map[string]IPSP)
+	p := &PSP{gateways: make(map[string]IPSP), states: make(map[string]models.GatewayState)}
 	for _, v := range psp {
-		p[v.GetName()] = v
+		p.gateways[v.GetName()] = v
+	}
+	return p
+}
+
+// Register adds a gateway, it keeps the state recorded for its name
+func (p *PSP) Register(gateway IPSP) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.gateways[gateway.GetName()]; ok {
+		return fmt.Errorf("gateway %s already registered", gateway.GetName())
 	}
-	return &PSP{gateways: p}
+	p.gateways[gateway.GetName()] = gateway
+	return nil
 }
 
+// Get returns a registered gateway whatever its state, for its webhooks
 func (p *PSP) Get(name string) (IPSP, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	res, ok := p.gateways[name]
 	if !ok {
-		return nil, fmt.Errorf("gateway %s not found", name)
+		return nil, fmt.Errorf("gateway %s %w", name, ErrNotFound)
 	}
 	return res, nil
 }
+
+// Available returns a registered gateway that takes new payments
+func (p *PSP) Available(name string) (IPSP, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	res, ok := p.gateways[name]
+	if !ok {
+		return nil, fmt.Errorf("gateway %s %w", name, ErrNotFound)
+	}
+	if p.state(name).Status == models.GatewayPaused {
+		return nil, fmt.Errorf("gateway %s is %w", name, ErrPaused)
+	}
+	return res, nil
+}
+
+// List returns the state of every registered gateway, sorted by name
+func (p *PSP) List() []models.GatewayState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	states := make([]models.GatewayState, 0, len(p.gateways))
+	for name := range p.gateways {
+		states = append(states, p.state(name))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// SetState records the state of a gateway
+func (p *PSP) SetState(state models.GatewayState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states[state.Name] = state
+}
+
+// Load replaces the recorded states, gateways without one are enabled
+func (p *PSP) Load(states []models.GatewayState) {
+	loaded := make(map[string]models.GatewayState, len(states))
+	for _, state := range states {
+		loaded[state.Name] = state
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states = loaded
+}
+
+// Watch loads the states every interval until ctx is done, so a gateway paused on
+// another instance is paused here too
+func (p *PSP) Watch(ctx context.Context, interval time.Duration, load func(context.Context) ([]models.GatewayState, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			states, err := load(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "error loading gateway states", "error", err)
+				continue
+			}
+			p.Load(states)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// state returns the recorded state of a gateway, enabled by default
+func (p *PSP) state(name string) models.GatewayState {
+	if state, ok := p.states[name]; ok {
+		return state
+	}
+	return models.GatewayState{Name: name, Status: models.GatewayEnabled}
+}
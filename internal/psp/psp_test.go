@@ -0,0 +1,112 @@
+package psp
+
+import (
+	"context"
+	"payment-gateway/db"
+	"payment-gateway/internal/auth"
+	"payment-gateway/internal/logging"
+	"payment-gateway/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeGateway struct {
+	name string
+}
+
+func (f fakeGateway) Deposit(context.Context, models.DepositRequest) (string, string, error) {
+	return "", "", nil
+}
+func (f fakeGateway) Withdrawal(context.Context, models.CustomWithdrawalRequest, *db.DB) (string, error) {
+	return "", nil
+}
+func (f fakeGateway) GetName() string                              { return f.name }
+func (f fakeGateway) PublishWebhook(context.Context, []byte) error { return nil }
+
+func TestPSP_Registry(t *testing.T) {
+	registry := Init([]IPSP{fakeGateway{"STRIPE"}})
+	require.NoError(t, registry.Register(fakeGateway{"RAZORPAY"}))
+	assert.Error(t, registry.Register(fakeGateway{"STRIPE"}), "duplicate registration")
+
+	registry.SetState(models.GatewayState{Name: "STRIPE", Status: models.GatewayPaused, Reason: "elevated declines"})
+
+	tests := []struct {
+		name      string
+		available error
+		get       error
+	}{
+		{name: "RAZORPAY"},
+		{name: "STRIPE", available: ErrPaused},
+		{name: "ADYEN", available: ErrNotFound, get: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := registry.Available(tt.name)
+			assert.ErrorIs(t, err, tt.available)
+			if tt.available == nil {
+				assert.NoError(t, err)
+			}
+			// Webhooks of a paused gateway are still handled
+			_, err = registry.Get(tt.name)
+			assert.ErrorIs(t, err, tt.get)
+			if tt.get == nil {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	assert.Equal(t, []models.GatewayState{
+		{Name: "RAZORPAY", Status: models.GatewayEnabled},
+		{Name: "STRIPE", Status: models.GatewayPaused, Reason: "elevated declines"},
+	}, registry.List())
+
+	// A reload without a state resumes the gateway
+	registry.Load(nil)
+	_, err := registry.Available("STRIPE")
+	assert.NoError(t, err)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	caller := func(subject, requestID string) context.Context {
+		ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: auth.MethodAPIKey})
+		return logging.WithRequestID(ctx, requestID)
+	}
+	key := IdempotencyKey(caller("billing", "req-1"), "withdrawal", "1", "usd", "100")
+	assert.Regexp(t, `^withdrawal:[0-9a-f]{64}$`, key)
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		operation string
+		userID    string
+		order     []string
+		same      bool
+	}{
+		{name: "retry of the same order", ctx: caller("billing", "req-1"), operation: "withdrawal", userID: "1", order: []string{"usd", "100"}, same: true},
+		{name: "another caller", ctx: caller("ops", "req-1"), operation: "withdrawal", userID: "1", order: []string{"usd", "100"}},
+		{name: "another user", ctx: caller("billing", "req-1"), operation: "withdrawal", userID: "2", order: []string{"usd", "100"}},
+		{name: "another order", ctx: caller("billing", "req-1"), operation: "withdrawal", userID: "1", order: []string{"usd", "200"}},
+		{name: "another operation", ctx: caller("billing", "req-1"), operation: "deposit", userID: "1", order: []string{"usd", "100"}},
+		{name: "another request ID", ctx: caller("billing", "req-2"), operation: "withdrawal", userID: "1", order: []string{"usd", "100"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IdempotencyKey(tt.ctx, tt.operation, tt.userID, tt.order...)
+			assert.Equal(t, tt.same, got == key)
+		})
+	}
+
+	assert.Empty(t, IdempotencyKey(context.Background(), "withdrawal", "1"), "no request ID")
+}
+
+func TestPayoutMetadata(t *testing.T) {
+	req := models.CustomWithdrawalRequest{UserID: "1", GatewayID: "7", CountryID: "3",
+		Metadata: map[string]string{"reference": "inv-1", "user_id": "2", "hold_id": "9"}}
+	assert.Equal(t, map[string]string{"reference": "inv-1", "user_id": "1", "gateway_id": "7", "country_id": "3"}, PayoutMetadata(req))
+
+	req.HoldID = 4
+	assert.Equal(t, "4", PayoutMetadata(req)["hold_id"])
+}
@@ -1,22 +1,30 @@
 package razorpay
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"os"
+	"log/slog"
+	"net/http"
 	"payment-gateway/db"
+	"payment-gateway/internal/config"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/tracing"
 
 	"github.com/razorpay/razorpay-go"
 )
 
 type RazoryPay struct {
 	client *razorpay.Client
+	keyID  string
 }
 
-func Init() *RazoryPay {
+func Init(cfg config.Razorpay) *RazoryPay {
+	client := razorpay.NewClient(cfg.KeyID, cfg.KeySecret)
+	// The SDK takes no context, its HTTP client timeout is the only bound on a call
+	razorpay.Request.HTTPClient = &http.Client{Timeout: cfg.Timeout, Transport: tracing.Transport(http.DefaultTransport)}
 	return &RazoryPay{
-		client: razorpay.NewClient(os.Getenv("RAZORPAY_KEY_ID"), os.Getenv("RAZORPAY_KEY_SECRET")),
+		client: client,
+		keyID:  cfg.KeyID,
 	}
 }
 
@@ -24,7 +32,10 @@ func (p *RazoryPay) GetName() string {
 	return "RAZORPAY"
 }
 
-func (p *RazoryPay) Deposit(req models.DepositRequest) (string, string, error) {
+func (p *RazoryPay) Deposit(ctx context.Context, req models.DepositRequest) (string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
 	data := map[string]interface{}{
 		"amount":          req.Amount,
 		"currency":        req.Currency,
@@ -36,13 +47,16 @@ func (p *RazoryPay) Deposit(req models.DepositRequest) (string, string, error) {
 	if err != nil {
 		return "", "", err
 	}
-	fmt.Println(body)
 	razorId, _ := body["id"].(string)
+	slog.Debug("razorpay order created", "order_id", razorId)
 	return razorId, "", nil
 
 }
 
-func (p *RazoryPay) Withdrawal(req models.CustomWithdrawalRequest, db *db.DB) (string, error) {
+func (p *RazoryPay) Withdrawal(ctx context.Context, req models.CustomWithdrawalRequest, db *db.DB) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	data := map[string]interface{}{
 		"amount":          req.Amount,
 		"currency":        req.Currency,
@@ -54,15 +68,15 @@ func (p *RazoryPay) Withdrawal(req models.CustomWithdrawalRequest, db *db.DB) (s
 	if err != nil {
 		return "", err
 	}
-	fmt.Println(body)
 	razorId, _ := body["id"].(string)
+	slog.Debug("razorpay order created", "order_id", razorId)
 	return razorId, nil
 
 }
 
 func (r *RazoryPay) GetPayment(paymentID string) error {
 	// TODO: get payment details
-	fmt.Println("get payment called", paymentID)
+	slog.Debug("razorpay get payment called", "payment_id", paymentID)
 	return nil
 }
 
@@ -70,19 +84,15 @@ func (r *RazoryPay) GetPaymentInfo(orderID, amountInPaisa, currency string) inte
 	// Prepare data for the template
 	return PaymentPageData{ //Use Razorpay struct here.
 		OrderID:      orderID,
-		RazorpayKey:  os.Getenv("RAZORPAY_KEY_ID"), // Load from environment variable
-		Amount:       amountInPaisa,                // convert to paise
+		RazorpayKey:  r.keyID,
+		Amount:       amountInPaisa, // convert to paise
 		Currency:     currency,
 		MerchantName: "Hiring At Exinity",                                                                                                                                                                                 // Replace with your merchant name
 		ImageURL:     "https://media.licdn.com/dms/image/v2/C4D0BAQHKsboC6kuHfA/company-logo_200_200/company-logo_200_200/0/1630580504879/exinity_logo?e=2147483647&v=beta&t=Zc72r_d8x3O6u_ywVVT--7aE_K0wh0prA9cS2gAJCRs", // Replace with your logo URL
 	}
 }
 
-func (r *RazoryPay) HandleWebhook(ev any, db *db.DB) error {
-	return nil
-}
-
-func (r *RazoryPay) PublishWebhookToKafka(ev any) error {
+func (r *RazoryPay) PublishWebhook(ctx context.Context, payload []byte) error {
 	return nil
 }
 
@@ -97,10 +107,7 @@ func (r *RazoryPay) ExtractWebhookData(payload map[string]interface{}) (string,
 					id, ok3 := entityMap["id"].(string)
 
 					if ok1 && ok2 && ok3 {
-						fmt.Println("Extracted Data:")
-						fmt.Println("Status:", status)
-						fmt.Println("Updated At:", int64(updatedAt))
-						fmt.Println("ID:", id)
+						slog.Debug("razorpay webhook data extracted", "id", id, "status", status, "updated_at", int64(updatedAt))
 						return status, int64(updatedAt), id, nil
 					} else {
 						return "", 0, "", errors.New("error extracting data from payload: Type assertion failed")
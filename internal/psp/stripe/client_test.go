@@ -0,0 +1,158 @@
+package stripe
+
+import (
+	"context"
+	"net/http"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/logging"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp/stripe/stripetest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	stripe "github.com/stripe/stripe-go/v81"
+)
+
+func newTestClient(t *testing.T) (*StripeClient, *stripetest.Server) {
+	server := stripetest.NewServer()
+	t.Cleanup(server.Close)
+	return New(config.Stripe{SecretKey: "sk_test_1", AccountID: "acct_1", Timeout: time.Second}, server.Backend()), server
+}
+
+func TestStripeClient_Deposit(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount string
+		code   stripe.ErrorCode
+		fail   bool
+	}{
+		{name: "created", amount: "5000"},
+		{name: "below the minimum", amount: "10", code: stripe.ErrorCodeAmountTooSmall},
+		{name: "stripe error", amount: "5000", code: "api_error", fail: true},
+		{name: "invalid amount", amount: "ten"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newTestClient(t)
+			if tt.fail {
+				server.FailNext(http.StatusInternalServerError, string(tt.code))
+			}
+
+			id, secret, err := client.Deposit(context.Background(), models.DepositRequest{
+				Amount: tt.amount, Currency: "USD", UserID: "1", GatewayID: "7", CountryID: "3",
+			})
+			if tt.code != "" {
+				var stripeErr *stripe.Error
+				require.ErrorAs(t, err, &stripeErr)
+				assert.Equal(t, tt.code, stripeErr.Code)
+				return
+			}
+			if tt.amount == "ten" {
+				assert.EqualError(t, err, "invalid amount: ten")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "pi_test_1", id)
+			assert.Equal(t, "pi_test_1_secret_test", secret)
+			intent := server.Object(id)
+			assert.Equal(t, "usd", intent["currency"])
+			assert.Equal(t, map[string]string{"user_id": "1", "gateway_id": "7", "gateway_name": "STRIPE", "country_id": "3"}, intent["metadata"])
+
+			status, err := client.GetDepositStatus(id)
+			require.NoError(t, err)
+			assert.Equal(t, "requires_payment_method", status)
+		})
+	}
+}
+
+func TestStripeClient_Idempotency(t *testing.T) {
+	client, _ := newTestClient(t)
+	req := models.DepositRequest{Amount: "5000", Currency: "USD", UserID: "1", GatewayID: "7", CountryID: "3"}
+
+	// A retry with the same request ID gets the first payment intent
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	first, _, err := client.Deposit(ctx, req)
+	require.NoError(t, err)
+	retried, _, err := client.Deposit(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, first, retried)
+
+	other, _, err := client.Deposit(logging.WithRequestID(context.Background(), "req-2"), req)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+
+	// The key is scoped to the operation
+	payout, err := client.Withdrawal(ctx, models.CustomWithdrawalRequest{Amount: 5000, Currency: "USD", UserID: "1"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "po_test_1", payout)
+}
+
+func TestStripeClient_Withdrawal(t *testing.T) {
+	client, server := newTestClient(t)
+
+	id, err := client.Withdrawal(context.Background(), models.CustomWithdrawalRequest{
+		Amount:              2500,
+		Currency:            "USD",
+		UserID:              "1",
+		GatewayID:           "7",
+		CountryID:           "3",
+		Description:         "Withdrawal",
+		StatementDescriptor: "PAYMENT GATEWAY WITHDRAWAL",
+		Metadata:            map[string]string{"source": "test"},
+		BankDetails:         &models.BankAccountDetails{AccountNumber: "000123456789"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "po_test_1", id)
+
+	payout := server.Object(id)
+	assert.Equal(t, int64(2500), payout["amount"])
+	assert.Equal(t, "PAYMENT GATEWAY WITHDR", payout["statement_descriptor"])
+	assert.Equal(t, map[string]string{"source": "test", "user_id": "1", "gateway_id": "7", "country_id": "3", "bank_account": "********6789"}, payout["metadata"])
+
+	bankAccount, err := client.createExternalBankAccount(models.BankAccountDetails{
+		Country: "US", Currency: "usd", AccountNumber: "000123456789", RoutingNumber: "110000000",
+		AccountHolderName: "Jane Doe", AccountHolderType: "individual",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ba_test_1", bankAccount)
+	assert.Equal(t, "cus_test_1", server.Object(bankAccount)["customer"])
+	assert.Equal(t, "6789", server.Object(bankAccount)["last4"])
+}
+
+func TestStripeClient_Refund(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	id, _, err := client.Deposit(ctx, models.DepositRequest{Amount: "5000", Currency: "USD", UserID: "1", GatewayID: "7", CountryID: "3"})
+	require.NoError(t, err)
+
+	refund, err := client.Refund(ctx, id, 2000)
+	require.NoError(t, err)
+	assert.Equal(t, "re_test_1", refund)
+
+	// Zero refunds the rest, after which nothing is left
+	refund, err = client.Refund(ctx, id, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "re_test_2", refund)
+	_, err = client.Refund(ctx, id, 1)
+	var stripeErr *stripe.Error
+	require.ErrorAs(t, err, &stripeErr)
+	assert.Equal(t, stripe.ErrorCodeAmountTooLarge, stripeErr.Code)
+
+	_, err = client.Refund(ctx, "pi_missing", 0)
+	require.ErrorAs(t, err, &stripeErr)
+	assert.Equal(t, stripe.ErrorCodeResourceMissing, stripeErr.Code)
+}
+
+func TestStripeClient_Timeout(t *testing.T) {
+	server := stripetest.NewServer()
+	defer server.Close()
+	server.SetLatency(200 * time.Millisecond)
+	client := New(config.Stripe{SecretKey: "sk_test_1", Timeout: 20 * time.Millisecond}, server.Backend())
+
+	_, _, err := client.Deposit(context.Background(), models.DepositRequest{Amount: "5000", Currency: "USD"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = client.Withdrawal(context.Background(), models.CustomWithdrawalRequest{Amount: 5000, Currency: "USD"}, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
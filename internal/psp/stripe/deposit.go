@@ -1,17 +1,18 @@
 package stripe
 
 import (
+	"context"
 	"fmt"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp"
 	"strconv"
 
 	"github.com/sony/gobreaker"
 	stripe "github.com/stripe/stripe-go/v81"
-	"github.com/stripe/stripe-go/v81/paymentintent"
 )
 
 // Deposit creates a payment intent in Stripe for accepting money with circuit breaking
-func (s *StripeClient) Deposit(req models.DepositRequest) (string, string, error) {
+func (s *StripeClient) Deposit(ctx context.Context, req models.DepositRequest) (string, string, error) {
 	amount, err := strconv.ParseInt(req.Amount, 10, 64)
 	if err != nil {
 		return "", "", fmt.Errorf("invalid amount: %s", req.Amount)
@@ -21,7 +22,10 @@ func (s *StripeClient) Deposit(req models.DepositRequest) (string, string, error
 		Amount:   stripe.Int64(amount),
 		Currency: stripe.String(req.Currency),
 	}
-	stripe.Key = s.secretKey
+	ctx, cancel := psp.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	params.Context = ctx
+	params.IdempotencyKey = idempotencyKey(ctx, "deposit", req.UserID, req.GatewayID, req.CountryID, req.Currency, req.Amount)
 	params.Metadata = map[string]string{
 		"user_id":      req.UserID,
 		"gateway_id":   req.GatewayID,
@@ -31,7 +35,7 @@ func (s *StripeClient) Deposit(req models.DepositRequest) (string, string, error
 
 	// Wrap the Stripe API call in the circuit breaker
 	result, err := s.cb.Execute(func() (interface{}, error) {
-		intent, err := paymentintent.New(params)
+		intent, err := s.api.PaymentIntents.New(params)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create payment intent: %w", err)
 		}
@@ -55,7 +59,7 @@ func (s *StripeClient) Deposit(req models.DepositRequest) (string, string, error
 
 // GetDepositStatus retrieves the status of a deposit (payment intent)
 func (s *StripeClient) GetDepositStatus(depositID string) (string, error) {
-	intent, err := paymentintent.Get(depositID, nil)
+	intent, err := s.api.PaymentIntents.Get(depositID, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to get payment intent: %w", err)
 	}
@@ -0,0 +1,28 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"payment-gateway/internal/psp"
+	"strconv"
+
+	stripe "github.com/stripe/stripe-go/v81"
+)
+
+// Refund refunds amount of a deposit (payment intent), zero refunds what is left of it
+func (s *StripeClient) Refund(ctx context.Context, depositID string, amount int64) (string, error) {
+	params := &stripe.RefundParams{PaymentIntent: stripe.String(depositID)}
+	if amount > 0 {
+		params.Amount = stripe.Int64(amount)
+	}
+	ctx, cancel := psp.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	params.Context = ctx
+	params.IdempotencyKey = idempotencyKey(ctx, "refund", "", depositID, strconv.FormatInt(amount, 10))
+
+	refund, err := s.api.Refunds.New(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create refund: %w", err)
+	}
+	return refund.ID, nil
+}
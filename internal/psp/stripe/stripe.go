@@ -1,33 +1,42 @@
 package stripe
 
 import (
-	"fmt"
-	"os"
-	"payment-gateway/db"
-	"payment-gateway/internal/kafka"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/metrics"
+	"payment-gateway/internal/psp"
+	"payment-gateway/internal/tracing"
 	"time"
 
 	"github.com/sony/gobreaker"
 	stripe "github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/client"
 )
 
 // StripeClient represents a Stripe payment processor
 type StripeClient struct {
 	secretKey string
 	accountID string
-	kafka     *kafka.Kafka
+	api       *client.API
+	publisher *events.Publisher
 	cb        *gobreaker.CircuitBreaker
+	timeout   time.Duration // Bounds each deposit or withdrawal call
 }
 
-// Init initializes the Stripe client with API key from environment
-func Init(k *kafka.Kafka, db *db.DB) *StripeClient {
-	secretKey := os.Getenv("STRIPE_SECRET_KEY")
-	accountID := os.Getenv("STRIPE_ACCOUNT_ID")
-
-	if secretKey == "" || accountID == "" {
-		panic(fmt.Sprintf("missing Stripe secret key: %s or account ID: %s", secretKey, accountID))
-	}
+// Init initializes the Stripe client calling the Stripe API and publishing its webhooks
+func Init(cfg config.Stripe, publisher *events.Publisher) *StripeClient {
+	client := New(cfg, nil)
+	client.publisher = publisher
+	return client
+}
 
+// New returns a Stripe client calling the API through backend, nil is the Stripe API.
+// Tests pass the backend of a stripetest.Server.
+func New(cfg config.Stripe, backend stripe.Backend) *StripeClient {
 	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
 		Name:        "StripePaymentIntent", // Name of the circuit breaker
 		MaxRequests: 2,                     // Number of requests allowed in half-open state
@@ -36,27 +45,46 @@ func Init(k *kafka.Kafka, db *db.DB) *StripeClient {
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
 			return counts.ConsecutiveFailures > 5 // Open circuit after 5 consecutive failures
 		},
+		// A caller that went away says nothing about Stripe's health, a timeout does
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, context.Canceled)
+		},
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			fmt.Printf("Circuit Breaker '%s' changed from %s to %s\n", name, from, to)
+			slog.Warn("circuit breaker state changed", "breaker", name, "from", from.String(), "to", to.String())
+			metrics.SetBreakerState(name, to)
 		},
 	})
 
-	stripe.Key = secretKey
-	client := &StripeClient{
-		secretKey: secretKey,
-		accountID: accountID,
-		kafka:     k,
+	metrics.SetBreakerState(cb.Name(), cb.State())
+
+	if backend == nil {
+		// Stripe API calls get client spans, 80s is the SDK's default timeout
+		backend = stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+			HTTPClient: &http.Client{Timeout: 80 * time.Second, Transport: tracing.Transport(http.DefaultTransport)},
+		})
+	}
+	return &StripeClient{
+		secretKey: cfg.SecretKey,
+		accountID: cfg.AccountID,
+		api:       client.New(cfg.SecretKey, &stripe.Backends{API: backend, Connect: backend, Uploads: backend}),
 		cb:        cb,
+		timeout:   cfg.Timeout,
 	}
-
-	go client.kafka.ConsumeStripeWebhook(client.GetTopic(), db, client.HandleWebhook)
-	return client
 }
 
-func (s *StripeClient) GetTopic() string {
-	return "gateway.stripe"
+// BreakerState returns the state of the circuit breaker guarding the Stripe API
+func (s *StripeClient) BreakerState() gobreaker.State {
+	return s.cb.State()
 }
 
 func (s *StripeClient) GetName() string {
 	return "STRIPE"
 }
+
+// idempotencyKey returns psp.IdempotencyKey, nil without a request ID
+func idempotencyKey(ctx context.Context, operation, userID string, order ...string) *string {
+	if key := psp.IdempotencyKey(ctx, operation, userID, order...); key != "" {
+		return stripe.String(key)
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+// Package stripetest is a local stand-in for the Stripe API. It covers payment
+// intents, payouts, tokens, customers, bank accounts and refunds, keeps them in
+// memory and numbers their IDs per type (pi_test_1, po_test_1, ...) so tests can
+// assert on them.
+package stripetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	stripe "github.com/stripe/stripe-go/v81"
+)
+
+// Server is a fake Stripe API listening on a local port
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	sequences  map[string]int
+	objects    map[string]map[string]any
+	idempotent map[string]response // By path and Idempotency-Key
+	failures   []response
+	latency    time.Duration
+}
+
+type response struct {
+	status int
+	body   any
+}
+
+// NewServer starts a server, Close stops it
+func NewServer() *Server {
+	s := &Server{
+		sequences:  make(map[string]int),
+		objects:    make(map[string]map[string]any),
+		idempotent: make(map[string]response),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/payment_intents", s.createPaymentIntent)
+	mux.HandleFunc("GET /v1/payment_intents/{id}", s.get("payment_intent"))
+	mux.HandleFunc("POST /v1/payouts", s.createPayout)
+	mux.HandleFunc("GET /v1/payouts/{id}", s.get("payout"))
+	mux.HandleFunc("POST /v1/tokens", s.createToken)
+	mux.HandleFunc("POST /v1/customers", s.createCustomer)
+	mux.HandleFunc("POST /v1/customers/{id}/sources", s.createBankAccount)
+	mux.HandleFunc("POST /v1/refunds", s.createRefund)
+	mux.HandleFunc("GET /v1/refunds/{id}", s.get("refund"))
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// Backend returns a backend calling the server, without retries or logs
+func (s *Server) Backend() stripe.Backend {
+	return stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(s.URL),
+		HTTPClient:        s.Client(),
+		MaxNetworkRetries: stripe.Int64(0),
+		LeveledLogger:     &stripe.LeveledLogger{Level: stripe.LevelNull},
+	})
+}
+
+// FailNext makes the next request answer status with a Stripe error of code
+func (s *Server) FailNext(status int, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, errorResponse(status, code, "injected failure"))
+}
+
+// SetLatency delays every answer by d
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Object returns a copy of the stored object with id, nil when there is none
+func (s *Server) Object(id string) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[id]
+	if !ok {
+		return nil
+	}
+	copied := make(map[string]any, len(object))
+	for k, v := range object {
+		copied[k] = v
+	}
+	return copied
+}
+
+// middleware checks the API key, applies the latency and the injected failures and
+// replays the answer of a repeated Idempotency-Key
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		latency := s.latency
+		s.mu.Unlock()
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			write(w, errorResponse(http.StatusUnauthorized, "", "You did not provide an API key."))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			write(w, errorResponse(http.StatusBadRequest, "parameter_invalid", err.Error()))
+			return
+		}
+
+		s.mu.Lock()
+		if len(s.failures) > 0 {
+			failure := s.failures[0]
+			s.failures = s.failures[1:]
+			s.mu.Unlock()
+			write(w, failure)
+			return
+		}
+		key := r.Header.Get("Idempotency-Key")
+		if replay, ok := s.idempotent[r.URL.Path+":"+key]; ok && key != "" && r.Method == http.MethodPost {
+			s.mu.Unlock()
+			w.Header().Set("Idempotent-Replayed", "true")
+			write(w, replay)
+			return
+		}
+		s.mu.Unlock()
+
+		recorder := &recorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if key != "" && r.Method == http.MethodPost && recorder.written != nil {
+			s.mu.Lock()
+			s.idempotent[r.URL.Path+":"+key] = *recorder.written
+			s.mu.Unlock()
+		}
+	})
+}
+
+func (s *Server) createPaymentIntent(w http.ResponseWriter, r *http.Request) {
+	amount, ok := positive(w, r, "amount")
+	if !ok || !required(w, r, "currency") {
+		return
+	}
+	if amount < 50 {
+		write(w, errorResponse(http.StatusBadRequest, "amount_too_small", "Amount must be at least 50 cents"))
+		return
+	}
+	s.create(w, "pi", map[string]any{
+		"object":   "payment_intent",
+		"amount":   amount,
+		"currency": strings.ToLower(r.PostForm.Get("currency")),
+		"status":   "requires_payment_method",
+		"metadata": nested(r.PostForm, "metadata"),
+	}, func(object map[string]any) {
+		object["client_secret"] = object["id"].(string) + "_secret_test"
+	})
+}
+
+func (s *Server) createPayout(w http.ResponseWriter, r *http.Request) {
+	amount, ok := positive(w, r, "amount")
+	if !ok || !required(w, r, "currency") {
+		return
+	}
+	if len(r.PostForm.Get("statement_descriptor")) > 22 {
+		write(w, errorResponse(http.StatusBadRequest, "parameter_invalid_string_too_long", "statement_descriptor is longer than 22 characters"))
+		return
+	}
+	method := r.PostForm.Get("method")
+	if method == "" {
+		method = "standard"
+	}
+	s.create(w, "po", map[string]any{
+		"object":               "payout",
+		"amount":               amount,
+		"currency":             strings.ToLower(r.PostForm.Get("currency")),
+		"status":               "pending",
+		"method":               method,
+		"description":          r.PostForm.Get("description"),
+		"statement_descriptor": r.PostForm.Get("statement_descriptor"),
+		"metadata":             nested(r.PostForm, "metadata"),
+	}, nil)
+}
+
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request) {
+	account := nested(r.PostForm, "bank_account")
+	if account["account_number"] == "" {
+		write(w, errorResponse(http.StatusBadRequest, "parameter_missing", "Missing required param: bank_account[account_number]."))
+		return
+	}
+	s.create(w, "tok", map[string]any{
+		"object": "token",
+		"type":   "bank_account",
+		"used":   false,
+		"bank_account": map[string]any{
+			"object":              "bank_account",
+			"country":             account["country"],
+			"currency":            strings.ToLower(account["currency"]),
+			"account_holder_name": account["account_holder_name"],
+			"account_holder_type": account["account_holder_type"],
+			"routing_number":      account["routing_number"],
+			"last4":               last4(account["account_number"]),
+		},
+	}, nil)
+}
+
+func (s *Server) createCustomer(w http.ResponseWriter, r *http.Request) {
+	s.create(w, "cus", map[string]any{
+		"object":   "customer",
+		"email":    r.PostForm.Get("email"),
+		"name":     r.PostForm.Get("name"),
+		"metadata": nested(r.PostForm, "metadata"),
+	}, nil)
+}
+
+// createBankAccount attaches the bank account of an unused token to a customer
+func (s *Server) createBankAccount(w http.ResponseWriter, r *http.Request) {
+	customerID := r.PathValue("id")
+	tokenID := r.PostForm.Get("source")
+	s.mu.Lock()
+	customer, token := s.objects[customerID], s.objects[tokenID]
+	found := customer != nil && customer["object"] == "customer"
+	usable := token != nil && token["object"] == "token" && token["used"] == false
+	if found && usable {
+		token["used"] = true
+	}
+	s.mu.Unlock()
+	if !found {
+		write(w, missing(customerID))
+		return
+	}
+	if !usable {
+		write(w, errorResponse(http.StatusBadRequest, "token_already_used", "No such unused token: "+tokenID))
+		return
+	}
+
+	account := map[string]any{"customer": customerID}
+	for k, v := range token["bank_account"].(map[string]any) {
+		account[k] = v
+	}
+	s.create(w, "ba", account, nil)
+}
+
+// createRefund refunds a payment intent, never more than its amount in total
+func (s *Server) createRefund(w http.ResponseWriter, r *http.Request) {
+	intentID := r.PostForm.Get("payment_intent")
+	s.mu.Lock()
+	intent := s.objects[intentID]
+	var amount, left int64
+	if intent != nil {
+		refunded, _ := intent["amount_refunded"].(int64)
+		left = intent["amount"].(int64) - refunded
+		amount = left
+		if v := r.PostForm.Get("amount"); v != "" {
+			amount, _ = strconv.ParseInt(v, 10, 64)
+		}
+		if amount > 0 && amount <= left {
+			intent["amount_refunded"] = refunded + amount
+		}
+	}
+	s.mu.Unlock()
+	if intent == nil || intent["object"] != "payment_intent" {
+		write(w, missing(intentID))
+		return
+	}
+	if amount <= 0 || amount > left {
+		write(w, errorResponse(http.StatusBadRequest, "amount_too_large",
+			fmt.Sprintf("Refund amount (%d) is greater than unrefunded amount on charge (%d)", amount, left)))
+		return
+	}
+	s.create(w, "re", map[string]any{
+		"object":         "refund",
+		"amount":         amount,
+		"currency":       intent["currency"],
+		"payment_intent": intentID,
+		"status":         "succeeded",
+		"metadata":       nested(r.PostForm, "metadata"),
+	}, nil)
+}
+
+func (s *Server) get(object string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		found := s.Object(id)
+		if found == nil || found["object"] != object {
+			write(w, missing(id))
+			return
+		}
+		write(w, response{http.StatusOK, found})
+	}
+}
+
+// create stores object under the next ID of prefix and answers with it
+func (s *Server) create(w http.ResponseWriter, prefix string, object map[string]any, complete func(map[string]any)) {
+	s.mu.Lock()
+	s.sequences[prefix]++
+	object["id"] = fmt.Sprintf("%s_test_%d", prefix, s.sequences[prefix])
+	object["created"] = time.Now().Unix()
+	object["livemode"] = false
+	if complete != nil {
+		complete(object)
+	}
+	s.objects[object["id"].(string)] = object
+	s.mu.Unlock()
+	write(w, response{http.StatusOK, object})
+}
+
+// recorder keeps the answer for idempotent replays
+type recorder struct {
+	http.ResponseWriter
+	written *response
+}
+
+func write(w http.ResponseWriter, res response) {
+	if r, ok := w.(*recorder); ok {
+		r.written = &res
+	}
+	body, _ := json.Marshal(res.body)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Request-Id", "req_test")
+	w.WriteHeader(res.status)
+	w.Write(body)
+}
+
+func errorResponse(status int, code, message string) response {
+	errorType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errorType = "api_error"
+	}
+	return response{status, map[string]any{"error": map[string]any{"type": errorType, "code": code, "message": message}}}
+}
+
+func missing(id string) response {
+	return errorResponse(http.StatusNotFound, "resource_missing", "No such object: '"+id+"'")
+}
+
+func positive(w http.ResponseWriter, r *http.Request, param string) (int64, bool) {
+	v, err := strconv.ParseInt(r.PostForm.Get(param), 10, 64)
+	if err != nil || v <= 0 {
+		write(w, errorResponse(http.StatusBadRequest, "parameter_invalid_integer", "Invalid positive integer: "+param))
+		return 0, false
+	}
+	return v, true
+}
+
+func required(w http.ResponseWriter, r *http.Request, param string) bool {
+	if r.PostForm.Get(param) == "" {
+		write(w, errorResponse(http.StatusBadRequest, "parameter_missing", "Missing required param: "+param+"."))
+		return false
+	}
+	return true
+}
+
+// nested returns the params encoded as name[key]
+func nested(form url.Values, name string) map[string]string {
+	values := make(map[string]string)
+	for key := range form {
+		if strings.HasPrefix(key, name+"[") && strings.HasSuffix(key, "]") {
+			values[key[len(name)+1:len(key)-1]] = form.Get(key)
+		}
+	}
+	return values
+}
+
+func last4(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return number[len(number)-4:]
+}
@@ -1,25 +1,28 @@
 package stripe
 
 import (
+	"context"
 	"fmt"
 	"payment-gateway/db"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/psp"
+	"strconv"
 
-	str "github.com/stripe/stripe-go"
-	"github.com/stripe/stripe-go/customer"
 	stripe "github.com/stripe/stripe-go/v81"
-	"github.com/stripe/stripe-go/v81/bankaccount"
-	"github.com/stripe/stripe-go/v81/payout"
-	"github.com/stripe/stripe-go/v81/token"
 )
 
 // Withdrawal handles the full process of creating a bank account and making a payout
-func (s *StripeClient) Withdrawal(req models.CustomWithdrawalRequest, db *db.DB) (string, error) {
+func (s *StripeClient) Withdrawal(ctx context.Context, req models.CustomWithdrawalRequest, db *db.DB) (string, error) {
 	// Step 2: Create the payout to this bank account
 	params := &stripe.PayoutParams{
 		Amount:   stripe.Int64(req.Amount),
 		Currency: stripe.String(req.Currency),
 	}
+	ctx, cancel := psp.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	params.Context = ctx
+	params.IdempotencyKey = idempotencyKey(ctx, "withdrawal", req.UserID, req.GatewayID, req.CountryID, req.Currency,
+		strconv.FormatInt(req.Amount, 10), strconv.FormatInt(req.HoldID, 10))
 
 	// Add optional parameters
 	if req.Description != "" {
@@ -38,22 +41,10 @@ func (s *StripeClient) Withdrawal(req models.CustomWithdrawalRequest, db *db.DB)
 		params.StatementDescriptor = stripe.String(req.StatementDescriptor)
 	}
 
-	// Add metadata
-	metadata := make(map[string]string)
-	if req.Metadata != nil {
-		for k, v := range req.Metadata {
-			metadata[k] = v
-		}
-	}
-
-	// Add user ID to metadata
-	metadata["user_id"] = req.UserID
-	metadata["bank_account_holder"] = req.BankDetails.AccountHolderName
-
-	params.Metadata = metadata
+	params.Metadata = psp.PayoutMetadata(req)
 
 	// Create the payout
-	p, err := payout.New(params)
+	p, err := s.api.Payouts.New(params)
 	if err != nil {
 		return "", fmt.Errorf("failed to create payout: %w", err)
 	}
@@ -76,16 +67,16 @@ func (s *StripeClient) createExternalBankAccount(details models.BankAccountDetai
 	}
 
 	// Create a token for the bank account
-	token, err := token.New(tokenParams)
+	token, err := s.api.Tokens.New(tokenParams)
 	if err != nil {
 		return "", fmt.Errorf("failed to create token for bank account: %w", err)
 	}
 
-	customerParams := &str.CustomerParams{
+	customerParams := &stripe.CustomerParams{
 		Email: stripe.String("user@example.com"),
 		Name:  &details.AccountHolderName,
 	}
-	customer, err := customer.New(customerParams)
+	customer, err := s.api.Customers.New(customerParams)
 	if err != nil {
 		return "", fmt.Errorf("failed to create customer: %w", err)
 	}
@@ -97,7 +88,7 @@ func (s *StripeClient) createExternalBankAccount(details models.BankAccountDetai
 	}
 
 	// Create the bank account
-	bankAccount, err := bankaccount.New(bankParams)
+	bankAccount, err := s.api.BankAccounts.New(bankParams)
 	if err != nil {
 		return "", fmt.Errorf("failed to create bank account: %w", err)
 	}
@@ -0,0 +1,201 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"payment-gateway/internal/auth"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/httpstatus"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Policies keyed by route group
+const (
+	PolicyIP         = "ip"         // Every authenticated route, per client IP, before authentication
+	PolicyRead       = "read"       // Gateway lookups and other reads, per caller
+	PolicyDeposit    = "deposit"    // Deposits, per caller
+	PolicyWithdrawal = "withdrawal" // Withdrawals, per caller
+	PolicyAdmin      = "admin"      // Admin API, per caller
+	PolicyWebhook    = "webhook"    // Gateway webhooks, per client IP
+)
+
+// Store holds the limiter state, shared by every instance of the service
+type Store interface {
+	TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, time.Duration, error)
+	ReserveSlot(ctx context.Context, key, member string, max int64, window time.Duration) (bool, time.Duration, error)
+	ReleaseSlot(ctx context.Context, key, member string) error
+}
+
+// Policy is a token bucket: Limit requests per Period, with bursts of up to Burst.
+type Policy struct {
+	Limit  int64
+	Period time.Duration
+	Burst  int64
+	ByIP   bool // Key by client IP even when the caller is authenticated
+}
+
+// rate returns the refill rate in tokens per second
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// DefaultPolicies returns the built-in policies. Webhook limits are generous because
+// gateways retry and burst deliveries from a handful of IPs.
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		PolicyIP:         {Limit: 300, Period: time.Minute, Burst: 300, ByIP: true},
+		PolicyRead:       {Limit: 60, Period: time.Minute, Burst: 30},
+		PolicyDeposit:    {Limit: 10, Period: time.Minute, Burst: 10},
+		PolicyWithdrawal: {Limit: 5, Period: time.Minute, Burst: 5},
+		PolicyAdmin:      {Limit: 60, Period: time.Minute, Burst: 60},
+		PolicyWebhook:    {Limit: 1000, Period: time.Minute, Burst: 500, ByIP: true},
+	}
+}
+
+// Velocity caps how many requests a caller may complete in a sliding window
+type Velocity struct {
+	Max    int64
+	Window time.Duration
+}
+
+// Limiter applies rate limit policies and velocity checks to HTTP handlers
+type Limiter struct {
+	store      Store
+	policies   map[string]Policy
+	trustProxy bool
+}
+
+// New creates a limiter with the given policies
+func New(store Store, policies map[string]Policy, trustProxy bool) *Limiter {
+	return &Limiter{store: store, policies: policies, trustProxy: trustProxy}
+}
+
+// NewFromConfig creates a limiter with the default policies overridden by the
+// configured ones. Overrides keep the keying of the policy they replace.
+func NewFromConfig(store Store, cfg config.RateLimit) *Limiter {
+	policies := DefaultPolicies()
+	for name, override := range cfg.Policies {
+		if policy, ok := policies[name]; ok {
+			policies[name] = Policy{Limit: override.Limit, Period: override.Period, Burst: override.Burst, ByIP: policy.ByIP}
+		}
+	}
+	return New(store, policies, cfg.TrustProxy)
+}
+
+// Limit returns a middleware enforcing the named policy. Callers are keyed by
+// user, then API key or token subject, then client IP. Requests are let through
+// when Redis is unavailable so an outage does not take payments down with it.
+func (l *Limiter) Limit(name string) func(http.Handler) http.Handler {
+	policy, ok := l.policies[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown rate limit policy %s", name))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := fmt.Sprintf("ratelimit:%s:%s", name, l.callerKey(r, policy.ByIP))
+			allowed, wait, err := l.store.TakeToken(r.Context(), key, policy.rate(), policy.Burst)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error checking rate limit", "error", err)
+			} else if !allowed {
+				tooManyRequests(w, wait)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// VelocityLimit caps how many requests each user may complete in a sliding window.
+// Handlers reserve a slot once they know the user the request acts for, so every
+// caller acting for a user, end user, service or API key, shares the user's window.
+type VelocityLimit struct {
+	limiter  *Limiter
+	name     string
+	velocity Velocity
+}
+
+// VelocityLimit returns the named velocity limit
+func (l *Limiter) VelocityLimit(name string, v Velocity) *VelocityLimit {
+	return &VelocityLimit{limiter: l, name: name, velocity: v}
+}
+
+// Reserve takes a slot of the user's window. It returns the writer the handler
+// answers on and a func to call once it has answered, which gives the slot back
+// unless the answer was 2xx, so rejected and failed requests do not count. When
+// the window is full Reserve answers 429 and returns false. Requests are let
+// through when Redis is unavailable.
+func (v *VelocityLimit) Reserve(w http.ResponseWriter, r *http.Request, userID string) (http.ResponseWriter, func(), bool) {
+	key := fmt.Sprintf("velocity:%s:user:%s", v.name, userID)
+	member := uuid.New().String()
+	allowed, wait, err := v.limiter.store.ReserveSlot(r.Context(), key, member, v.velocity.Max, v.velocity.Window)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error checking velocity", "error", err)
+		return w, func() {}, true
+	}
+	if !allowed {
+		tooManyRequests(w, wait)
+		return w, nil, false
+	}
+
+	recorder := httpstatus.NewRecorder(w)
+	done := func() {
+		if recorder.Success() {
+			return
+		}
+		if err := v.limiter.store.ReleaseSlot(context.WithoutCancel(r.Context()), key, member); err != nil {
+			slog.ErrorContext(r.Context(), "error releasing velocity slot", "error", err)
+		}
+	}
+	return recorder, done, true
+}
+
+// callerKey identifies the caller of a request
+func (l *Limiter) callerKey(r *http.Request, byIP bool) string {
+	if !byIP {
+		if identity, ok := auth.FromContext(r.Context()); ok {
+			if identity.UserID != "" {
+				return "user:" + identity.UserID
+			}
+			return identity.Method + ":" + identity.Subject
+		}
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP returns the address of the client, from X-Forwarded-For when behind a trusted proxy
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tooManyRequests answers 429 with the number of seconds to wait
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int64(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"payment-gateway/internal/auth"
+	"payment-gateway/internal/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore counts tokens and slots per key without refilling
+type memoryStore struct {
+	taken map[string]int64
+	slots map[string]map[string]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{taken: map[string]int64{}, slots: map[string]map[string]bool{}}
+}
+
+func (m *memoryStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, time.Duration, error) {
+	if m.taken[key] >= burst {
+		return false, 1500 * time.Millisecond, nil
+	}
+	m.taken[key]++
+	return true, 0, nil
+}
+
+func (m *memoryStore) ReserveSlot(ctx context.Context, key, member string, max int64, window time.Duration) (bool, time.Duration, error) {
+	if m.slots[key] == nil {
+		m.slots[key] = map[string]bool{}
+	}
+	if int64(len(m.slots[key])) >= max {
+		return false, 30 * time.Minute, nil
+	}
+	m.slots[key][member] = true
+	return true, 0, nil
+}
+
+func (m *memoryStore) ReleaseSlot(ctx context.Context, key, member string) error {
+	delete(m.slots[key], member)
+	return nil
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	store := newMemoryStore()
+	limiter := New(store, map[string]Policy{"deposit": {Limit: 2, Period: time.Minute, Burst: 2}}, false)
+	handler := limiter.Limit("deposit")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	call := func(userID, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/deposit", nil)
+		r.RemoteAddr = ip + ":1234"
+		if userID != "" {
+			r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{Subject: userID, UserID: userID}))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, call("1", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, call("1", "10.0.0.2").Code)
+	limited := call("1", "10.0.0.3")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "2", limited.Header().Get("Retry-After"))
+
+	// Other users and anonymous callers have their own buckets
+	assert.Equal(t, http.StatusOK, call("2", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, call("", "10.0.0.1").Code)
+	assert.Equal(t, int64(1), store.taken["ratelimit:deposit:ip:10.0.0.1"])
+}
+
+func TestVelocityLimit_Reserve(t *testing.T) {
+	store := newMemoryStore()
+	velocity := New(store, nil, false).VelocityLimit("withdrawal", Velocity{Max: 2, Window: time.Hour})
+
+	status := http.StatusOK
+	call := func(identity auth.Identity, userID string) int {
+		r := httptest.NewRequest(http.MethodPost, "/withdrawal", nil)
+		r = r.WithContext(auth.WithIdentity(r.Context(), identity))
+		w := httptest.NewRecorder()
+		rw, done, ok := velocity.Reserve(w, r, userID)
+		if ok {
+			rw.WriteHeader(status)
+			done()
+		}
+		return w.Code
+	}
+	user := auth.Identity{Subject: "1", UserID: "1", Method: auth.MethodJWT}
+	service := auth.Identity{Subject: "billing", Method: auth.MethodAPIKey}
+
+	// Failed withdrawals give their slot back
+	status = http.StatusBadRequest
+	assert.Equal(t, http.StatusBadRequest, call(user, "1"))
+	assert.Len(t, store.slots["velocity:withdrawal:user:1"], 0)
+
+	// The user and services acting for the user share one window
+	status = http.StatusOK
+	assert.Equal(t, http.StatusOK, call(user, "1"))
+	assert.Equal(t, http.StatusOK, call(service, "1"))
+	assert.Equal(t, http.StatusTooManyRequests, call(user, "1"))
+	assert.Equal(t, http.StatusTooManyRequests, call(service, "1"))
+
+	// A service acting for another user is not held back by them
+	assert.Equal(t, http.StatusOK, call(service, "2"))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	limiter := NewFromConfig(newMemoryStore(), config.RateLimit{
+		Policies: map[string]config.RatePolicy{
+			PolicyDeposit: {Limit: 20, Period: time.Minute, Burst: 5},
+			PolicyWebhook: {Limit: 100, Period: time.Hour, Burst: 100},
+			"unknown":     {Limit: 1, Period: time.Second, Burst: 1},
+		},
+		TrustProxy: true,
+	})
+
+	assert.Equal(t, Policy{Limit: 20, Period: time.Minute, Burst: 5}, limiter.policies[PolicyDeposit])
+	assert.Equal(t, Policy{Limit: 100, Period: time.Hour, Burst: 100, ByIP: true}, limiter.policies[PolicyWebhook])
+	assert.Equal(t, DefaultPolicies()[PolicyRead], limiter.policies[PolicyRead])
+	assert.NotContains(t, limiter.policies, "unknown")
+	assert.True(t, limiter.trustProxy)
+}
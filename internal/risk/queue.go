@@ -0,0 +1,185 @@
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/services"
+	"strings"
+)
+
+var (
+	// ErrReviewNotFound is returned for unknown reviews and reviews of another user
+	ErrReviewNotFound = errors.New("review not found")
+	// ErrReviewDecided is returned when a review is no longer pending
+	ErrReviewDecided = errors.New("review has already been decided")
+	// ErrReasonRequired is returned when a review is rejected without a reason
+	ErrReasonRequired = errors.New("a reason is required to reject a review")
+	// ErrSelfApproval is returned when the caller that made a request tries to approve it
+	ErrSelfApproval = errors.New("a review cannot be approved by its requester")
+	// ErrInsufficientFunds is returned when a withdrawal cannot be held
+	ErrInsufficientFunds = errors.New("insufficient balance")
+	// ErrHoldNotFound is returned when released funds were not held for the user in the currency
+	ErrHoldNotFound = errors.New("hold not found")
+)
+
+// ReviewStore persists the manual approval queue
+type ReviewStore interface {
+	CreateRiskReview(ctx context.Context, review models.Review) (models.Review, error)
+	GetRiskReview(ctx context.Context, id int64) (models.Review, error) // Returns ErrReviewNotFound when missing
+	GetRiskReviews(ctx context.Context, status string, limit int) ([]models.Review, error)
+	// UpdateRiskReviewStatus moves a review from one status to another and returns ErrReviewDecided
+	// when it is no longer in the expected status
+	UpdateRiskReviewStatus(ctx context.Context, id int64, from, to, decidedBy, reason string, result []byte) error
+	// HoldFunds reserves an amount in the smallest currency unit against the user's balance
+	// and returns ErrInsufficientFunds when the available balance does not cover it
+	HoldFunds(ctx context.Context, userID, currency string, amount int64) (int64, error)
+	// ReleaseHold releases funds held for the user in the currency and returns
+	// ErrHoldNotFound for a hold that is not theirs
+	ReleaseHold(ctx context.Context, holdID int64, userID, currency string) error
+}
+
+// Queue parks transactions the risk engine sent to review until an admin decides on them.
+// Parked withdrawals hold their funds so they cannot be spent twice while pending.
+type Queue struct {
+	store     ReviewStore
+	encryptor *services.Encryptor
+}
+
+// NewQueue creates a review queue backed by the store
+func NewQueue(store ReviewStore, encryptor *services.Encryptor) *Queue {
+	return &Queue{store: store, encryptor: encryptor}
+}
+
+// Park stores the request with the signals that sent it to review. requestedBy is the
+// caller that made the request and may not approve it.
+func (q *Queue) Park(ctx context.Context, req models.RiskRequest, assessment models.RiskAssessment, payload interface{}, requestedBy string) (models.Review, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return models.Review{}, fmt.Errorf("failed to encode review payload: %v", err)
+	}
+	sealed, err := q.encryptor.Encrypt(plaintext, []byte(req.UserID))
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	var holdID int64
+	if req.Type == "withdrawal" {
+		if holdID, err = q.store.HoldFunds(ctx, req.UserID, req.Currency, req.Amount); err != nil {
+			return models.Review{}, err
+		}
+	}
+
+	review, err := q.store.CreateRiskReview(ctx, models.Review{
+		Type:        req.Type,
+		UserID:      req.UserID,
+		CountryID:   req.CountryID,
+		Currency:    strings.ToUpper(req.Currency),
+		Amount:      req.Amount,
+		GatewayID:   req.GatewayID,
+		Signals:     assessment.Signals,
+		Payload:     sealed,
+		Status:      models.ReviewPending,
+		RequestedBy: requestedBy,
+		HoldID:      holdID,
+	})
+	if err != nil {
+		q.release(ctx, models.Review{UserID: req.UserID, Currency: req.Currency, HoldID: holdID})
+		return models.Review{}, err
+	}
+	return review, nil
+}
+
+// List returns the reviews in a status, oldest first
+func (q *Queue) List(ctx context.Context, status string, limit int) ([]models.Review, error) {
+	return q.store.GetRiskReviews(ctx, status, limit)
+}
+
+// Get returns a review. An empty userID skips the ownership check.
+func (q *Queue) Get(ctx context.Context, userID string, id int64) (models.Review, error) {
+	review, err := q.store.GetRiskReview(ctx, id)
+	if err != nil {
+		return models.Review{}, err
+	}
+	if userID != "" && review.UserID != userID {
+		return models.Review{}, ErrReviewNotFound
+	}
+	return review, nil
+}
+
+// Claim marks a pending review as approved by the reviewer and returns it with the
+// parked request. Only one reviewer can claim a review, and never its requester. The
+// funds stay held through the gateway call, which must not count them against the
+// withdrawal; the caller must then record the gateway outcome with Complete.
+func (q *Queue) Claim(ctx context.Context, id int64, reviewer, reason string) (models.Review, json.RawMessage, error) {
+	review, err := q.store.GetRiskReview(ctx, id)
+	if err != nil {
+		return models.Review{}, nil, err
+	}
+	if review.Status != models.ReviewPending {
+		return models.Review{}, nil, ErrReviewDecided
+	}
+	if reviewer == "" || reviewer == review.RequestedBy {
+		return models.Review{}, nil, ErrSelfApproval
+	}
+
+	payload, err := q.encryptor.Decrypt(review.Payload, []byte(review.UserID))
+	if err != nil {
+		return models.Review{}, nil, err
+	}
+	if err := q.store.UpdateRiskReviewStatus(ctx, id, models.ReviewPending, models.ReviewProcessing, reviewer, reason, nil); err != nil {
+		return models.Review{}, nil, err
+	}
+	review.Status = models.ReviewProcessing
+	review.DecidedBy = reviewer
+	review.Reason = reason
+	return review, payload, nil
+}
+
+// Complete records the gateway outcome of a claimed review. The held funds are
+// released when the gateway call failed; otherwise they stay held until the payout
+// is debited, fails or is canceled.
+func (q *Queue) Complete(ctx context.Context, review models.Review, result interface{}, gatewayErr error) error {
+	status := models.ReviewApproved
+	if gatewayErr != nil {
+		status = models.ReviewFailed
+		result = map[string]string{"error": gatewayErr.Error()}
+		q.release(ctx, review)
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode review result: %v", err)
+	}
+	return q.store.UpdateRiskReviewStatus(ctx, review.ID, models.ReviewProcessing, status, review.DecidedBy, review.Reason, encoded)
+}
+
+// Reject closes a pending review without sending the transaction to a gateway and
+// releases its held funds
+func (q *Queue) Reject(ctx context.Context, id int64, reviewer, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return ErrReasonRequired
+	}
+	review, err := q.store.GetRiskReview(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := q.store.UpdateRiskReviewStatus(ctx, id, models.ReviewPending, models.ReviewRejected, reviewer, reason, nil); err != nil {
+		return err
+	}
+	q.release(ctx, review)
+	return nil
+}
+
+// release frees the funds held for a review, a failure leaves them held until
+// released by hand
+func (q *Queue) release(ctx context.Context, review models.Review) {
+	if review.HoldID == 0 {
+		return
+	}
+	if err := q.store.ReleaseHold(ctx, review.HoldID, review.UserID, review.Currency); err != nil {
+		slog.ErrorContext(ctx, "error releasing hold", "hold_id", review.HoldID, "error", err)
+	}
+}
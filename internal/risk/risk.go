@@ -0,0 +1,67 @@
+package risk
+
+import (
+	"context"
+	"log/slog"
+	"payment-gateway/internal/models"
+	"time"
+)
+
+// Rule scores one aspect of a transaction. Rules return an allow signal when
+// they have nothing to report.
+type Rule interface {
+	Name() string
+	Evaluate(ctx context.Context, req models.RiskRequest) (models.RiskSignal, error)
+}
+
+// Engine runs the risk rules before a transaction reaches a gateway
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine creates an engine running the rules in order
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Use adds a rule to the engine
+func (e *Engine) Use(rule Rule) {
+	e.rules = append(e.rules, rule)
+}
+
+// Assess runs every rule and returns the most severe decision. A rule that fails
+// to evaluate sends the transaction to review rather than letting it through.
+func (e *Engine) Assess(ctx context.Context, req models.RiskRequest) models.RiskAssessment {
+	if req.At.IsZero() {
+		req.At = time.Now().UTC()
+	}
+
+	assessment := models.RiskAssessment{Decision: models.RiskAllow, Signals: []models.RiskSignal{}}
+	for _, rule := range e.rules {
+		signal, err := rule.Evaluate(ctx, req)
+		if err != nil {
+			slog.ErrorContext(ctx, "error evaluating risk rule", "rule", rule.Name(), "error", err)
+			signal = models.RiskSignal{Decision: models.RiskReview, Reason: "rule could not be evaluated"}
+		}
+		if signal.Decision == models.RiskAllow || signal.Decision == "" {
+			continue
+		}
+		signal.Rule = rule.Name()
+		assessment.Signals = append(assessment.Signals, signal)
+		if severity(signal.Decision) > severity(assessment.Decision) {
+			assessment.Decision = signal.Decision
+		}
+	}
+	return assessment
+}
+
+func severity(decision string) int {
+	switch decision {
+	case models.RiskDeny:
+		return 2
+	case models.RiskReview:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,250 @@
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource serves fixed history
+type fakeSource struct {
+	counts    map[string]int64
+	stats     map[string]models.TransactionStats
+	countries map[string]string
+}
+
+func (f *fakeSource) CountTransactions(ctx context.Context, userID, txType string, since time.Time) (int64, error) {
+	return f.counts[userID+":"+txType], nil
+}
+
+func (f *fakeSource) GetTransactionStats(ctx context.Context, userID, txType, currency string, since time.Time) (models.TransactionStats, error) {
+	return f.stats[userID+":"+txType], nil
+}
+
+func (f *fakeSource) GetUserCountry(ctx context.Context, userID string) (string, error) {
+	return f.countries[userID], nil
+}
+
+func TestEngine_Assess(t *testing.T) {
+	source := &fakeSource{
+		counts: map[string]int64{"1:deposit": 2, "2:withdrawal": 12, "3:withdrawal": 30},
+		stats: map[string]models.TransactionStats{
+			"1:deposit": {Count: 10, Average: 10000, Max: 40000},
+		},
+		countries: map[string]string{"1": "2", "2": "2", "3": "2", "4": "1"},
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-48 * time.Hour)
+	anHourAgo := now.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		req      models.RiskRequest
+		decision string
+		rules    []string
+	}{
+		{
+			name:     "usual deposit is allowed",
+			req:      models.RiskRequest{Type: "deposit", UserID: "1", CountryID: "2", Amount: 20000},
+			decision: models.RiskAllow,
+		},
+		{
+			name:     "deposit far above the user's history is reviewed",
+			req:      models.RiskRequest{Type: "deposit", UserID: "1", CountryID: "2", Amount: 100000},
+			decision: models.RiskReview,
+			rules:    []string{"amount_anomaly"},
+		},
+		{
+			name:     "large first withdrawal is reviewed",
+			req:      models.RiskRequest{Type: "withdrawal", UserID: "4", CountryID: "1", Amount: 600000},
+			decision: models.RiskReview,
+			rules:    []string{"amount_anomaly"},
+		},
+		{
+			name:     "frequent withdrawals are reviewed",
+			req:      models.RiskRequest{Type: "withdrawal", UserID: "2", CountryID: "2", Amount: 1000},
+			decision: models.RiskReview,
+			rules:    []string{"velocity"},
+		},
+		{
+			name:     "too many withdrawals are denied",
+			req:      models.RiskRequest{Type: "withdrawal", UserID: "3", CountryID: "2", Amount: 1000},
+			decision: models.RiskDeny,
+			rules:    []string{"velocity"},
+		},
+		{
+			name:     "deposit in another country is reviewed",
+			req:      models.RiskRequest{Type: "deposit", UserID: "4", CountryID: "2", Amount: 1000},
+			decision: models.RiskReview,
+			rules:    []string{"country_mismatch"},
+		},
+		{
+			name:     "payout to a bank account saved an hour ago is reviewed",
+			req:      models.RiskRequest{Type: "withdrawal", UserID: "4", CountryID: "1", Amount: 1000, PayeeAddedAt: &anHourAgo},
+			decision: models.RiskReview,
+			rules:    []string{"new_beneficiary"},
+		},
+		{
+			name:     "withdrawal at the approval threshold needs approval",
+			req:      models.RiskRequest{Type: "withdrawal", UserID: "2", CountryID: "2", Currency: "aed", Amount: 5000000},
+			decision: models.RiskReview,
+			rules:    []string{"velocity", "amount_anomaly", "approval_threshold"},
+		},
+		{
+			name:     "payout to an older bank account is allowed",
+			req:      models.RiskRequest{Type: "withdrawal", UserID: "4", CountryID: "1", Amount: 1000, PayeeAddedAt: &yesterday},
+			decision: models.RiskAllow,
+		},
+	}
+
+	engine := NewEngine(DefaultRules(source, config.DefaultRisk())...)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.At = now
+			assessment := engine.Assess(context.Background(), tt.req)
+			assert.Equal(t, tt.decision, assessment.Decision)
+
+			var rules []string
+			for _, signal := range assessment.Signals {
+				rules = append(rules, signal.Rule)
+			}
+			assert.Equal(t, tt.rules, rules)
+		})
+	}
+}
+
+// memoryReviews keeps reviews and holds in maps
+type memoryReviews struct {
+	reviews map[int64]models.Review
+	balance int64
+	holds   map[int64]int64
+}
+
+func (m *memoryReviews) CreateRiskReview(ctx context.Context, review models.Review) (models.Review, error) {
+	review.ID = int64(len(m.reviews) + 1)
+	m.reviews[review.ID] = review
+	return review, nil
+}
+
+func (m *memoryReviews) GetRiskReview(ctx context.Context, id int64) (models.Review, error) {
+	review, ok := m.reviews[id]
+	if !ok {
+		return models.Review{}, ErrReviewNotFound
+	}
+	return review, nil
+}
+
+func (m *memoryReviews) GetRiskReviews(ctx context.Context, status string, limit int) ([]models.Review, error) {
+	var reviews []models.Review
+	for _, review := range m.reviews {
+		if review.Status == status {
+			reviews = append(reviews, review)
+		}
+	}
+	return reviews, nil
+}
+
+func (m *memoryReviews) UpdateRiskReviewStatus(ctx context.Context, id int64, from, to, decidedBy, reason string, result []byte) error {
+	review, ok := m.reviews[id]
+	if !ok {
+		return ErrReviewNotFound
+	}
+	if review.Status != from {
+		return ErrReviewDecided
+	}
+	review.Status, review.DecidedBy, review.Reason = to, decidedBy, reason
+	if result != nil {
+		review.Result = result
+	}
+	m.reviews[id] = review
+	return nil
+}
+
+func (m *memoryReviews) HoldFunds(ctx context.Context, userID, currency string, amount int64) (int64, error) {
+	held := int64(0)
+	for _, hold := range m.holds {
+		held += hold
+	}
+	if m.balance-held < amount {
+		return 0, ErrInsufficientFunds
+	}
+	id := int64(len(m.holds) + 1)
+	m.holds[id] = amount
+	return id, nil
+}
+
+func (m *memoryReviews) ReleaseHold(ctx context.Context, holdID int64, userID, currency string) error {
+	delete(m.holds, holdID)
+	return nil
+}
+
+func TestQueue_Lifecycle(t *testing.T) {
+	encryptor, err := services.NewEncryptor(map[string][]byte{"k1": make([]byte, 32)}, "k1")
+	assert.NoError(t, err)
+	store := &memoryReviews{reviews: map[int64]models.Review{}, balance: 800000, holds: map[int64]int64{}}
+	queue := NewQueue(store, encryptor)
+
+	req := models.RiskRequest{Type: "withdrawal", UserID: "1", CountryID: "2", Currency: "aed", Amount: 500000, GatewayID: "3"}
+	payload := models.CustomWithdrawalRequest{UserID: "1", Amount: 500000, Currency: "aed"}
+	review, err := queue.Park(context.Background(), req, models.RiskAssessment{Decision: models.RiskReview}, payload, "billing")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReviewPending, review.Status)
+	assert.NotContains(t, review.Payload, "500000")
+	assert.Len(t, store.holds, 1)
+
+	// The held funds cannot back a second withdrawal
+	_, err = queue.Park(context.Background(), req, models.RiskAssessment{Decision: models.RiskReview}, payload, "billing")
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// Four-eyes: the requester cannot approve
+	_, _, err = queue.Claim(context.Background(), review.ID, "billing", "")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+
+	_, err = queue.Get(context.Background(), "2", review.ID)
+	assert.ErrorIs(t, err, ErrReviewNotFound)
+
+	claimed, parked, err := queue.Claim(context.Background(), review.ID, "ops", "")
+	assert.NoError(t, err)
+	var decoded models.CustomWithdrawalRequest
+	assert.NoError(t, json.Unmarshal(parked, &decoded))
+	assert.Equal(t, payload.Amount, decoded.Amount)
+	// The funds stay held through the gateway call
+	assert.Len(t, store.holds, 1)
+
+	_, _, err = queue.Claim(context.Background(), review.ID, "other", "")
+	assert.ErrorIs(t, err, ErrReviewDecided)
+	assert.ErrorIs(t, queue.Reject(context.Background(), review.ID, "other", "duplicate"), ErrReviewDecided)
+
+	assert.NoError(t, queue.Complete(context.Background(), claimed, map[string]string{"orderid": "po_1"}, nil))
+	decided, err := queue.Get(context.Background(), "1", review.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReviewApproved, decided.Status)
+	assert.JSONEq(t, `{"orderid":"po_1"}`, string(decided.Result))
+	// Until the payout settles
+	assert.Len(t, store.holds, 1)
+	store.holds = map[int64]int64{}
+
+	// A failed gateway call releases the held funds
+	failed, err := queue.Park(context.Background(), req, models.RiskAssessment{Decision: models.RiskReview}, payload, "billing")
+	assert.NoError(t, err)
+	claimed, _, err = queue.Claim(context.Background(), failed.ID, "ops", "")
+	assert.NoError(t, err)
+	assert.NoError(t, queue.Complete(context.Background(), claimed, nil, errors.New("gateway timeout")))
+	assert.Empty(t, store.holds)
+
+	assert.ErrorIs(t, queue.Reject(context.Background(), 99, "ops", ""), ErrReasonRequired)
+
+	// Rejecting releases the held funds
+	rejected, err := queue.Park(context.Background(), req, models.RiskAssessment{Decision: models.RiskReview}, payload, "billing")
+	assert.NoError(t, err)
+	assert.Len(t, store.holds, 1)
+	assert.NoError(t, queue.Reject(context.Background(), rejected.ID, "ops", "not requested by the user"))
+	assert.Empty(t, store.holds)
+}
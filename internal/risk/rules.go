@@ -0,0 +1,146 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"payment-gateway/internal/config"
+	"payment-gateway/internal/models"
+	"strings"
+	"time"
+)
+
+// Source loads the history the built-in rules score against, implemented by the database
+type Source interface {
+	CountTransactions(ctx context.Context, userID, txType string, since time.Time) (int64, error)
+	GetTransactionStats(ctx context.Context, userID, txType, currency string, since time.Time) (models.TransactionStats, error)
+	GetUserCountry(ctx context.Context, userID string) (string, error) // Returns an empty string for unknown users
+}
+
+// DefaultRules returns the built-in rules
+func DefaultRules(source Source, config config.Risk) []Rule {
+	return []Rule{
+		&VelocityRule{Source: source, Window: config.VelocityWindow, Review: config.VelocityReview, Deny: config.VelocityDeny},
+		&AmountAnomalyRule{Source: source, Lookback: config.AmountLookback, Factor: config.AmountFactor,
+			MinCount: config.AmountMinCount, NewUserLimit: config.AmountNewUser},
+		&CountryMismatchRule{Source: source, Decision: config.CountryMismatch},
+		&PayeeCoolingRule{Period: config.PayeeCooling},
+		&ApprovalThresholdRule{Thresholds: config.ApprovalThresholds},
+	}
+}
+
+func allow() (models.RiskSignal, error) {
+	return models.RiskSignal{Decision: models.RiskAllow}, nil
+}
+
+// VelocityRule counts the user's transactions of the same type in a window
+type VelocityRule struct {
+	Source Source
+	Window time.Duration
+	Review int64 // 0 disables the review threshold
+	Deny   int64 // 0 disables the deny threshold
+}
+
+func (v *VelocityRule) Name() string { return "velocity" }
+
+func (v *VelocityRule) Evaluate(ctx context.Context, req models.RiskRequest) (models.RiskSignal, error) {
+	count, err := v.Source.CountTransactions(ctx, req.UserID, req.Type, req.At.Add(-v.Window))
+	if err != nil {
+		return models.RiskSignal{}, err
+	}
+	reason := fmt.Sprintf("%d %ss in the last %s", count, req.Type, v.Window)
+	switch {
+	case v.Deny > 0 && count >= v.Deny:
+		return models.RiskSignal{Decision: models.RiskDeny, Reason: reason}, nil
+	case v.Review > 0 && count >= v.Review:
+		return models.RiskSignal{Decision: models.RiskReview, Reason: reason}, nil
+	}
+	return allow()
+}
+
+// AmountAnomalyRule compares the amount with the user's past transactions of the
+// same type and currency. Users without enough history are held to a fixed limit.
+type AmountAnomalyRule struct {
+	Source       Source
+	Lookback     time.Duration
+	Factor       float64
+	MinCount     int64
+	NewUserLimit int64 // 0 disables the limit
+}
+
+func (a *AmountAnomalyRule) Name() string { return "amount_anomaly" }
+
+func (a *AmountAnomalyRule) Evaluate(ctx context.Context, req models.RiskRequest) (models.RiskSignal, error) {
+	stats, err := a.Source.GetTransactionStats(ctx, req.UserID, req.Type, req.Currency, req.At.Add(-a.Lookback))
+	if err != nil {
+		return models.RiskSignal{}, err
+	}
+
+	if stats.Count < a.MinCount {
+		if a.NewUserLimit > 0 && req.Amount > a.NewUserLimit {
+			return models.RiskSignal{Decision: models.RiskReview,
+				Reason: fmt.Sprintf("amount %d is above %d with only %d past %ss", req.Amount, a.NewUserLimit, stats.Count, req.Type)}, nil
+		}
+		return allow()
+	}
+	if float64(req.Amount) > a.Factor*stats.Average && float64(req.Amount) > stats.Max {
+		return models.RiskSignal{Decision: models.RiskReview,
+			Reason: fmt.Sprintf("amount %d is more than %gx the average of %d past %ss", req.Amount, a.Factor, stats.Count, req.Type)}, nil
+	}
+	return allow()
+}
+
+// CountryMismatchRule flags transactions made in another country than the user's
+type CountryMismatchRule struct {
+	Source   Source
+	Decision string
+}
+
+func (c *CountryMismatchRule) Name() string { return "country_mismatch" }
+
+func (c *CountryMismatchRule) Evaluate(ctx context.Context, req models.RiskRequest) (models.RiskSignal, error) {
+	country, err := c.Source.GetUserCountry(ctx, req.UserID)
+	if err != nil {
+		return models.RiskSignal{}, err
+	}
+	if country == "" || country == req.CountryID {
+		return allow()
+	}
+	return models.RiskSignal{Decision: c.Decision,
+		Reason: fmt.Sprintf("user is registered in country %s, transaction is for country %s", country, req.CountryID)}, nil
+}
+
+// PayeeCoolingRule reviews payouts to bank accounts saved within the cooling period
+type PayeeCoolingRule struct {
+	Period time.Duration
+}
+
+func (p *PayeeCoolingRule) Name() string { return "new_beneficiary" }
+
+func (p *PayeeCoolingRule) Evaluate(ctx context.Context, req models.RiskRequest) (models.RiskSignal, error) {
+	if req.Type != "withdrawal" || req.PayeeAddedAt == nil {
+		return allow()
+	}
+	if age := req.At.Sub(*req.PayeeAddedAt); age < p.Period {
+		return models.RiskSignal{Decision: models.RiskReview,
+			Reason: fmt.Sprintf("bank account was saved %s ago, cooling period is %s", age.Round(time.Minute), p.Period)}, nil
+	}
+	return allow()
+}
+
+// ApprovalThresholdRule sends withdrawals at or above the threshold of their currency
+// to manual approval
+type ApprovalThresholdRule struct {
+	Thresholds map[string]int64
+}
+
+func (a *ApprovalThresholdRule) Name() string { return "approval_threshold" }
+
+func (a *ApprovalThresholdRule) Evaluate(ctx context.Context, req models.RiskRequest) (models.RiskSignal, error) {
+	currency := strings.ToUpper(req.Currency)
+	threshold, ok := a.Thresholds[currency]
+	if req.Type != "withdrawal" || !ok || req.Amount < threshold {
+		return allow()
+	}
+	return models.RiskSignal{Decision: models.RiskReview,
+		Reason: fmt.Sprintf("amount %d is at or above the approval threshold of %d %s", req.Amount, threshold, currency)}, nil
+}
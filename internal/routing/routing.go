@@ -0,0 +1,155 @@
+package routing
+
+import (
+	"context"
+	"payment-gateway/internal/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RuleSource loads routing rules and user segments, implemented by the database
+type RuleSource interface {
+	GetRoutingRules(ctx context.Context) ([]models.RoutingRule, error)
+	GetUserSegment(ctx context.Context, userID string) (string, error)
+}
+
+// Engine evaluates routing rules in priority order before score ranking is applied
+type Engine struct {
+	source   RuleSource
+	ttl      time.Duration
+	mu       sync.RWMutex
+	rules    []models.RoutingRule
+	loadedAt time.Time
+}
+
+// NewEngine creates a routing engine that caches rules from the source for 30 seconds
+func NewEngine(source RuleSource) *Engine {
+	return &Engine{source: source, ttl: 30 * time.Second}
+}
+
+// Invalidate drops the cached rules so the next evaluation reloads them
+func (e *Engine) Invalidate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.loadedAt = time.Time{}
+}
+
+// Evaluate applies the first matching rule to the score-ranked candidate gateways
+// and returns the resulting candidates together with a trace of every rule evaluated.
+func (e *Engine) Evaluate(ctx context.Context, req models.RoutingRequest, ranked []models.Gateway) (models.RoutingDecision, error) {
+	decision := models.RoutingDecision{Gateways: ranked, Trace: []models.RuleEvaluation{}}
+
+	req, err := e.prepare(ctx, req)
+	if err != nil {
+		return decision, err
+	}
+	rules, err := e.loadRules(ctx)
+	if err != nil {
+		return decision, err
+	}
+
+	for i := range rules {
+		rule := rules[i]
+		matched, reason := match(rule, req)
+		decision.Trace = append(decision.Trace, models.RuleEvaluation{
+			RuleID:  rule.ID,
+			Name:    rule.Name,
+			Matched: matched,
+			Reason:  reason,
+		})
+		if matched {
+			decision.MatchedRule = &rule
+			decision.Gateways = apply(rule, ranked)
+			break
+		}
+	}
+	return decision, nil
+}
+
+// Allows reports whether the gateway may be used for the request. When it may not,
+// the rule that forbids it is returned.
+func (e *Engine) Allows(ctx context.Context, req models.RoutingRequest, gatewayID string) (bool, *models.RoutingRule, error) {
+	decision, err := e.Evaluate(ctx, req, []models.Gateway{{ID: gatewayID}})
+	if err != nil {
+		return false, nil, err
+	}
+	if len(decision.Gateways) == 0 {
+		return false, decision.MatchedRule, nil
+	}
+	return true, nil, nil
+}
+
+// prepare fills in the evaluation time and the stored user segment
+func (e *Engine) prepare(ctx context.Context, req models.RoutingRequest) (models.RoutingRequest, error) {
+	if req.At.IsZero() {
+		req.At = time.Now()
+	}
+	req.At = req.At.UTC()
+
+	if req.UserSegment == "" && req.UserID != "" {
+		segment, err := e.source.GetUserSegment(ctx, req.UserID)
+		if err != nil {
+			return req, err
+		}
+		req.UserSegment = segment
+	}
+	return req, nil
+}
+
+// loadRules returns the cached rules, reloading them from the source once the TTL expires
+func (e *Engine) loadRules(ctx context.Context) ([]models.RoutingRule, error) {
+	e.mu.RLock()
+	if time.Since(e.loadedAt) < e.ttl {
+		rules := e.rules
+		e.mu.RUnlock()
+		return rules, nil
+	}
+	e.mu.RUnlock()
+
+	rules, err := e.source.GetRoutingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+	e.loadedAt = time.Now()
+	return rules, nil
+}
+
+// apply narrows or reorders the ranked gateways according to the rule's action
+func apply(rule models.RoutingRule, ranked []models.Gateway) []models.Gateway {
+	gatewayID := strconv.Itoa(rule.GatewayID)
+	result := make([]models.Gateway, 0, len(ranked))
+
+	switch rule.Action {
+	case models.RoutingActionRoute:
+		for _, gateway := range ranked {
+			if gateway.ID == gatewayID {
+				result = append(result, gateway)
+			}
+		}
+	case models.RoutingActionExclude:
+		for _, gateway := range ranked {
+			if gateway.ID != gatewayID {
+				result = append(result, gateway)
+			}
+		}
+	case models.RoutingActionPrefer:
+		for _, gateway := range ranked {
+			if gateway.ID == gatewayID {
+				result = append(result, gateway)
+			}
+		}
+		for _, gateway := range ranked {
+			if gateway.ID != gatewayID {
+				result = append(result, gateway)
+			}
+		}
+	default:
+		return ranked
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package routing
+
+import (
+	"context"
+	"payment-gateway/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource serves fixed rules and segments
+type fakeSource struct {
+	rules    []models.RoutingRule
+	segments map[string]string
+}
+
+func (f *fakeSource) GetRoutingRules(ctx context.Context) ([]models.RoutingRule, error) {
+	return f.rules, nil
+}
+
+func (f *fakeSource) GetUserSegment(ctx context.Context, userID string) (string, error) {
+	return f.segments[userID], nil
+}
+
+func int64Ptr(v int64) *int64 { return &v }
+func intPtr(v int) *int       { return &v }
+
+func TestEngine_Evaluate(t *testing.T) {
+	source := &fakeSource{
+		rules: []models.RoutingRule{
+			{ID: 1, Name: "large INR deposits", Priority: 10, Action: models.RoutingActionRoute, GatewayID: 2,
+				TransactionType: "deposit", Currency: "INR", MinAmount: int64Ptr(1000001)},
+			{ID: 2, Name: "AED instant payouts", Priority: 20, Action: models.RoutingActionRoute, GatewayID: 3,
+				TransactionType: "withdrawal", Currency: "AED", Method: "instant"},
+			{ID: 3, Name: "no stripe at night", Priority: 50, Action: models.RoutingActionExclude, GatewayID: 1,
+				StartHour: intPtr(22), EndHour: intPtr(6)},
+			{ID: 4, Name: "VIP prefer stripe", Priority: 100, Action: models.RoutingActionPrefer, GatewayID: 1,
+				UserSegment: "vip"},
+		},
+		segments: map[string]string{"42": "vip"},
+	}
+	ranked := []models.Gateway{
+		{ID: "3", Name: "DEFAULT_GATEWAY", Score: 0.9},
+		{ID: "2", Name: "RAZORPAY", Score: 0.8},
+		{ID: "1", Name: "STRIPE", Score: 0.7},
+	}
+	noon := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	midnight := time.Date(2025, 1, 1, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		req         models.RoutingRequest
+		expectedIDs []string
+		matchedRule int
+	}{
+		{
+			name:        "large INR deposit is routed to razorpay",
+			req:         models.RoutingRequest{Type: "deposit", CountryID: "1", Currency: "inr", Amount: 1500000, At: noon},
+			expectedIDs: []string{"2"},
+			matchedRule: 1,
+		},
+		{
+			name:        "small INR deposit falls back to score ranking",
+			req:         models.RoutingRequest{Type: "deposit", CountryID: "1", Currency: "INR", Amount: 5000, At: noon},
+			expectedIDs: []string{"3", "2", "1"},
+		},
+		{
+			name:        "AED instant payout only via default gateway",
+			req:         models.RoutingRequest{Type: "withdrawal", CountryID: "2", Currency: "AED", Method: "instant", At: noon},
+			expectedIDs: []string{"3"},
+			matchedRule: 2,
+		},
+		{
+			name:        "time window excludes stripe overnight",
+			req:         models.RoutingRequest{Type: "deposit", CountryID: "2", Currency: "AED", UserID: "42", At: midnight},
+			expectedIDs: []string{"3", "2"},
+			matchedRule: 3,
+		},
+		{
+			name:        "VIP user prefers stripe",
+			req:         models.RoutingRequest{Type: "deposit", CountryID: "2", Currency: "AED", UserID: "42", At: noon},
+			expectedIDs: []string{"1", "3", "2"},
+			matchedRule: 4,
+		},
+	}
+
+	engine := NewEngine(source)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate(context.Background(), tt.req, ranked)
+			assert.NoError(t, err)
+
+			var ids []string
+			for _, gateway := range decision.Gateways {
+				ids = append(ids, gateway.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+
+			if tt.matchedRule == 0 {
+				assert.Nil(t, decision.MatchedRule)
+				assert.Len(t, decision.Trace, len(source.rules))
+			} else {
+				assert.Equal(t, tt.matchedRule, decision.MatchedRule.ID)
+				assert.True(t, decision.Trace[len(decision.Trace)-1].Matched)
+			}
+		})
+	}
+}
+
+func TestEngine_Allows(t *testing.T) {
+	engine := NewEngine(&fakeSource{rules: []models.RoutingRule{
+		{ID: 1, Name: "AED instant payouts", Action: models.RoutingActionRoute, GatewayID: 3,
+			TransactionType: "withdrawal", Currency: "AED", Method: "instant"},
+	}})
+	req := models.RoutingRequest{Type: "withdrawal", CountryID: "2", Currency: "AED", Method: "instant"}
+
+	allowed, rule, err := engine.Allows(context.Background(), req, "1")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, "AED instant payouts", rule.Name)
+
+	allowed, _, err = engine.Allows(context.Background(), req, "3")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+}
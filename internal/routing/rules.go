@@ -0,0 +1,49 @@
+package routing
+
+import (
+	"fmt"
+	"payment-gateway/internal/models"
+	"strings"
+)
+
+// match reports whether every condition of the rule holds for the request,
+// and explains the first condition that does not.
+func match(rule models.RoutingRule, req models.RoutingRequest) (bool, string) {
+	if rule.TransactionType != "" && rule.TransactionType != req.Type {
+		return false, fmt.Sprintf("transaction type %q does not match %q", req.Type, rule.TransactionType)
+	}
+	if rule.CountryID != "" && rule.CountryID != req.CountryID {
+		return false, fmt.Sprintf("country %q does not match %q", req.CountryID, rule.CountryID)
+	}
+	if rule.Currency != "" && !strings.EqualFold(rule.Currency, req.Currency) {
+		return false, fmt.Sprintf("currency %q does not match %q", req.Currency, rule.Currency)
+	}
+	if rule.MinAmount != nil && req.Amount < *rule.MinAmount {
+		return false, fmt.Sprintf("amount %d below minimum %d", req.Amount, *rule.MinAmount)
+	}
+	if rule.MaxAmount != nil && req.Amount > *rule.MaxAmount {
+		return false, fmt.Sprintf("amount %d above maximum %d", req.Amount, *rule.MaxAmount)
+	}
+	if rule.UserSegment != "" && !strings.EqualFold(rule.UserSegment, req.UserSegment) {
+		return false, fmt.Sprintf("user segment %q does not match %q", req.UserSegment, rule.UserSegment)
+	}
+	if rule.Method != "" && rule.Method != req.Method {
+		return false, fmt.Sprintf("method %q does not match %q", req.Method, rule.Method)
+	}
+	if rule.StartHour != nil && rule.EndHour != nil && !inWindow(req.At.Hour(), *rule.StartHour, *rule.EndHour) {
+		return false, fmt.Sprintf("hour %d outside %02d:00-%02d:00 UTC", req.At.Hour(), *rule.StartHour, *rule.EndHour)
+	}
+	return true, fmt.Sprintf("%s gateway %d", rule.Action, rule.GatewayID)
+}
+
+// inWindow reports whether hour falls in [start, end), wrapping past midnight when start > end.
+// Equal bounds cover the whole day.
+func inWindow(hour, start, end int) bool {
+	if start == end {
+		return true
+	}
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
@@ -1,20 +1,211 @@
 package services
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"strings"
 )
 
-// masks data using base64 (feel free to enhance it and use stronger encryption algorithm)
-func MaskData(data []byte) string {
-	return base64.StdEncoding.EncodeToString(data)
+// envelopeVersion prefixes every sealed value so the format can evolve
+const envelopeVersion = "v1"
+
+// Encryptor performs envelope encryption: every value is sealed with a fresh
+// AES-256-GCM data key, and the data key is wrapped by a key-encryption key (KEK).
+// Several KEKs can be loaded at once so old values stay readable after rotation;
+// new values are always wrapped by the active KEK.
+type Encryptor struct {
+	keys   map[string][]byte
+	active string
+}
+
+// NewEncryptor builds an encryptor from KEKs indexed by key ID. Every KEK must be 32 bytes.
+func NewEncryptor(keys map[string][]byte, active string) (*Encryptor, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key-encryption keys configured")
+	}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ".") {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes, got %d", id, len(key))
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not configured", active)
+	}
+	return &Encryptor{keys: keys, active: active}, nil
+}
+
+// ActiveKeyID returns the ID of the KEK used for new values
+func (e *Encryptor) ActiveKeyID() string {
+	return e.active
+}
+
+// Encrypt seals the plaintext. The associated data is authenticated but not
+// stored, and must be passed again to Decrypt; it binds a value to its owner.
+// The result has the form "v1.<keyID>.<wrapped data key>.<nonce>.<ciphertext>".
+func (e *Encryptor) Encrypt(plaintext, aad []byte) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %v", err)
+	}
+
+	nonce, ciphertext, err := seal(dataKey, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := e.wrap(e.active, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{envelopeVersion, e.active, encode(wrapped), encode(nonce), encode(ciphertext)}, "."), nil
+}
+
+// Decrypt opens a value sealed by Encrypt with any of the loaded KEKs
+func (e *Encryptor) Decrypt(sealed string, aad []byte) ([]byte, error) {
+	keyID, wrapped, nonce, ciphertext, err := parseEnvelope(sealed)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := e.unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, nonce, ciphertext, aad)
+}
+
+// KeyID returns the ID of the KEK that wrapped a sealed value
+func (e *Encryptor) KeyID(sealed string) (string, error) {
+	keyID, _, _, _, err := parseEnvelope(sealed)
+	return keyID, err
+}
+
+// Rewrap re-wraps the data key of a sealed value with the active KEK. The
+// ciphertext itself is untouched, so rotation never needs the associated data.
+func (e *Encryptor) Rewrap(sealed string) (string, error) {
+	keyID, wrapped, nonce, ciphertext, err := parseEnvelope(sealed)
+	if err != nil {
+		return "", err
+	}
+	if keyID == e.active {
+		return sealed, nil
+	}
+	dataKey, err := e.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	rewrapped, err := e.wrap(e.active, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{envelopeVersion, e.active, encode(rewrapped), encode(nonce), encode(ciphertext)}, "."), nil
+}
+
+// wrap encrypts a data key with a KEK, authenticating the key ID
+func (e *Encryptor) wrap(keyID string, dataKey []byte) ([]byte, error) {
+	nonce, ciphertext, err := seal(e.keys[keyID], dataKey, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
 }
 
-// unmasks data using base64
-func UnmaskData(maskedData string) ([]byte, error) {
-	decodedData, err := base64.StdEncoding.DecodeString(maskedData)
+// unwrap decrypts a data key wrapped by wrap
+func (e *Encryptor) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := e.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %s", keyID)
+	}
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped data key is too short")
+	}
+	dataKey, err := gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+	return dataKey, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %v", err)
+	}
+	return gcm, nil
+}
+
+func seal(key, plaintext, aad []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, aad), nil
+}
+
+func open(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode data: %v", err)
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce")
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %v", err)
+	}
+	return plaintext, nil
+}
+
+func parseEnvelope(sealed string) (keyID string, wrapped, nonce, ciphertext []byte, err error) {
+	parts := strings.Split(sealed, ".")
+	if len(parts) != 5 || parts[0] != envelopeVersion {
+		return "", nil, nil, nil, fmt.Errorf("invalid envelope format")
+	}
+	if wrapped, err = decode(parts[2]); err != nil {
+		return "", nil, nil, nil, err
+	}
+	if nonce, err = decode(parts[3]); err != nil {
+		return "", nil, nil, nil, err
+	}
+	if ciphertext, err = decode(parts[4]); err != nil {
+		return "", nil, nil, nil, err
+	}
+	return parts[1], wrapped, nonce, ciphertext, nil
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(data string) ([]byte, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode envelope: %v", err)
+	}
+	return decoded, nil
+}
+
+// MaskAccountNumber hides all but the last four characters, for logs and gateway metadata
+func MaskAccountNumber(value string) string {
+	if len(value) <= 4 {
+		return strings.Repeat("*", len(value))
 	}
-	return decodedData, nil
+	return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
 }
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptor_RoundTripAndRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	before, err := NewEncryptor(map[string][]byte{"k1": oldKey}, "k1")
+	assert.NoError(t, err)
+
+	sealed, err := before.Encrypt([]byte("1234567890"), []byte("user-1"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "v1.k1."))
+	assert.NotContains(t, sealed, "1234567890")
+
+	plaintext, err := before.Decrypt(sealed, []byte("user-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890", string(plaintext))
+
+	// The associated data binds the value to its owner
+	_, err = before.Decrypt(sealed, []byte("user-2"))
+	assert.Error(t, err)
+
+	// After rotation old values stay readable and can be rewrapped under the new key
+	after, err := NewEncryptor(map[string][]byte{"k1": oldKey, "k2": newKey}, "k2")
+	assert.NoError(t, err)
+	rewrapped, err := after.Rewrap(sealed)
+	assert.NoError(t, err)
+	keyID, err := after.KeyID(rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", keyID)
+
+	retired, err := NewEncryptor(map[string][]byte{"k2": newKey}, "k2")
+	assert.NoError(t, err)
+	plaintext, err = retired.Decrypt(rewrapped, []byte("user-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890", string(plaintext))
+	_, err = retired.Decrypt(sealed, []byte("user-1"))
+	assert.Error(t, err)
+}
+
+func TestNewEncryptor_Validation(t *testing.T) {
+	_, err := NewEncryptor(nil, "")
+	assert.Error(t, err)
+	_, err = NewEncryptor(map[string][]byte{"k1": []byte("short")}, "k1")
+	assert.Error(t, err)
+	_, err = NewEncryptor(map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, "k2")
+	assert.Error(t, err)
+}
+
+func TestMaskAccountNumber(t *testing.T) {
+	assert.Equal(t, "******7890", MaskAccountNumber("1234567890"))
+	assert.Equal(t, "***", MaskAccountNumber("123"))
+}
@@ -0,0 +1,142 @@
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/services"
+	"time"
+)
+
+// TokenPrefix marks bank detail tokens
+const TokenPrefix = "btok_"
+
+// ErrTokenNotFound is returned for unknown tokens and tokens owned by another user
+var ErrTokenNotFound = errors.New("token not found")
+
+// Store persists vault entries
+type Store interface {
+	SaveVaultEntry(ctx context.Context, entry models.VaultEntry) error
+	GetVaultEntry(ctx context.Context, token string) (models.VaultEntry, error) // Returns ErrTokenNotFound when missing
+	GetVaultEntriesNotUsingKey(ctx context.Context, keyID string, limit int) ([]models.VaultEntry, error)
+	UpdateVaultEntryKey(ctx context.Context, token, ciphertext, keyID string) error
+}
+
+// Vault stores bank details encrypted and hands out opaque tokens in their place.
+// Plaintext details never leave the vault except to be sent to a gateway.
+type Vault struct {
+	store     Store
+	encryptor *services.Encryptor
+}
+
+// New creates a vault backed by the store
+func New(store Store, encryptor *services.Encryptor) *Vault {
+	return &Vault{store: store, encryptor: encryptor}
+}
+
+// Tokenize encrypts the bank details of a user and returns the stored entry
+func (v *Vault) Tokenize(ctx context.Context, userID string, details models.BankAccountDetails) (models.VaultEntry, error) {
+	plaintext, err := json.Marshal(details)
+	if err != nil {
+		return models.VaultEntry{}, fmt.Errorf("failed to encode bank details: %v", err)
+	}
+	ciphertext, err := v.encryptor.Encrypt(plaintext, []byte(userID))
+	if err != nil {
+		return models.VaultEntry{}, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return models.VaultEntry{}, err
+	}
+
+	entry := models.VaultEntry{
+		Token:      token,
+		UserID:     userID,
+		Ciphertext: ciphertext,
+		KeyID:      v.encryptor.ActiveKeyID(),
+		Last4:      last4(details.AccountNumber),
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := v.store.SaveVaultEntry(ctx, entry); err != nil {
+		return models.VaultEntry{}, err
+	}
+	return entry, nil
+}
+
+// Entry returns the stored entry of a token owned by the user, without decrypting it
+func (v *Vault) Entry(ctx context.Context, userID, token string) (models.VaultEntry, error) {
+	entry, err := v.store.GetVaultEntry(ctx, token)
+	if err != nil {
+		return models.VaultEntry{}, err
+	}
+	if entry.UserID != userID {
+		return models.VaultEntry{}, ErrTokenNotFound
+	}
+	return entry, nil
+}
+
+// Detokenize returns the bank details behind a token owned by the user, and the
+// entry they were read from
+func (v *Vault) Detokenize(ctx context.Context, userID, token string) (models.BankAccountDetails, models.VaultEntry, error) {
+	entry, err := v.Entry(ctx, userID, token)
+	if err != nil {
+		return models.BankAccountDetails{}, models.VaultEntry{}, err
+	}
+
+	// The user ID is authenticated with the ciphertext, so entries cannot be moved between users
+	plaintext, err := v.encryptor.Decrypt(entry.Ciphertext, []byte(userID))
+	if err != nil {
+		return models.BankAccountDetails{}, models.VaultEntry{}, err
+	}
+	var details models.BankAccountDetails
+	if err := json.Unmarshal(plaintext, &details); err != nil {
+		return models.BankAccountDetails{}, models.VaultEntry{}, fmt.Errorf("failed to decode bank details: %v", err)
+	}
+	return details, entry, nil
+}
+
+// RotateKeys re-wraps every entry that is not yet under the active key-encryption
+// key, in batches, and returns the number of entries rewrapped.
+func (v *Vault) RotateKeys(ctx context.Context, batchSize int) (int, error) {
+	active := v.encryptor.ActiveKeyID()
+	rotated := 0
+	for {
+		entries, err := v.store.GetVaultEntriesNotUsingKey(ctx, active, batchSize)
+		if err != nil {
+			return rotated, err
+		}
+		if len(entries) == 0 {
+			return rotated, nil
+		}
+		for _, entry := range entries {
+			ciphertext, err := v.encryptor.Rewrap(entry.Ciphertext)
+			if err != nil {
+				return rotated, fmt.Errorf("failed to rewrap token %s: %v", entry.Token, err)
+			}
+			if err := v.store.UpdateVaultEntryKey(ctx, entry.Token, ciphertext, active); err != nil {
+				return rotated, err
+			}
+			rotated++
+		}
+	}
+}
+
+// newToken returns a random opaque token
+func newToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func last4(value string) string {
+	if len(value) <= 4 {
+		return value
+	}
+	return value[len(value)-4:]
+}
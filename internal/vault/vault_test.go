@@ -0,0 +1,186 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/services"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps the vault entries in a map
+type memoryStore struct {
+	entries map[string]models.VaultEntry
+}
+
+func (m *memoryStore) SaveVaultEntry(ctx context.Context, entry models.VaultEntry) error {
+	m.entries[entry.Token] = entry
+	return nil
+}
+
+func (m *memoryStore) GetVaultEntry(ctx context.Context, token string) (models.VaultEntry, error) {
+	entry, ok := m.entries[token]
+	if !ok {
+		return models.VaultEntry{}, ErrTokenNotFound
+	}
+	return entry, nil
+}
+
+func (m *memoryStore) GetVaultEntriesNotUsingKey(ctx context.Context, keyID string, limit int) ([]models.VaultEntry, error) {
+	var entries []models.VaultEntry
+	for _, entry := range m.entries {
+		if entry.KeyID != keyID {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Token < entries[j].Token })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (m *memoryStore) UpdateVaultEntryKey(ctx context.Context, token, ciphertext, keyID string) error {
+	entry := m.entries[token]
+	entry.Ciphertext, entry.KeyID = ciphertext, keyID
+	m.entries[token] = entry
+	return nil
+}
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, 32)
+	newKey = bytes.Repeat([]byte{2}, 32)
+
+	details = models.BankAccountDetails{
+		AccountHolderName: "John Doe",
+		AccountNumber:     "1234567890",
+		RoutingNumber:     "110000000",
+		Country:           "US",
+		Currency:          "usd",
+		AccountHolderType: "individual",
+	}
+)
+
+func newVault(t *testing.T, store *memoryStore, keys map[string][]byte, active string) *Vault {
+	encryptor, err := services.NewEncryptor(keys, active)
+	require.NoError(t, err)
+	return New(store, encryptor)
+}
+
+func TestVault_Tokenize(t *testing.T) {
+	store := &memoryStore{entries: map[string]models.VaultEntry{}}
+	v := newVault(t, store, map[string][]byte{"k1": oldKey}, "k1")
+
+	tests := []struct {
+		name          string
+		details       models.BankAccountDetails
+		expectedLast4 string
+	}{
+		{name: "account number", details: details, expectedLast4: "7890"},
+		{name: "short account number", details: models.BankAccountDetails{AccountNumber: "123"}, expectedLast4: "123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := v.Tokenize(context.Background(), "1", tt.details)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(entry.Token, TokenPrefix))
+			assert.Equal(t, "1", entry.UserID)
+			assert.Equal(t, "k1", entry.KeyID)
+			assert.Equal(t, tt.expectedLast4, entry.Last4)
+			assert.NotContains(t, entry.Ciphertext, tt.details.AccountNumber)
+			assert.Equal(t, entry, store.entries[entry.Token])
+		})
+	}
+}
+
+func TestVault_Detokenize(t *testing.T) {
+	store := &memoryStore{entries: map[string]models.VaultEntry{}}
+	v := newVault(t, store, map[string][]byte{"k1": oldKey}, "k1")
+	entry, err := v.Tokenize(context.Background(), "1", details)
+	require.NoError(t, err)
+
+	// An entry moved to another user no longer decrypts
+	moved := entry
+	moved.Token, moved.UserID = TokenPrefix+"moved", "2"
+	store.entries[moved.Token] = moved
+
+	tests := []struct {
+		name     string
+		userID   string
+		token    string
+		expected models.BankAccountDetails
+		wantErr  bool
+		errIs    error
+	}{
+		{name: "owner", userID: "1", token: entry.Token, expected: details},
+		{name: "other user", userID: "2", token: entry.Token, wantErr: true, errIs: ErrTokenNotFound},
+		{name: "unknown token", userID: "1", token: TokenPrefix + "unknown", wantErr: true, errIs: ErrTokenNotFound},
+		{name: "moved entry", userID: "2", token: moved.Token, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotEntry, err := v.Detokenize(context.Background(), tt.userID, tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errIs != nil {
+					assert.ErrorIs(t, err, tt.errIs)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, entry, gotEntry)
+		})
+	}
+}
+
+func TestVault_RotateKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   int
+		batchSize int
+	}{
+		{name: "no entries", entries: 0, batchSize: 2},
+		{name: "one batch", entries: 2, batchSize: 2},
+		{name: "several batches", entries: 5, batchSize: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryStore{entries: map[string]models.VaultEntry{}}
+			before := newVault(t, store, map[string][]byte{"k1": oldKey}, "k1")
+			var tokens []string
+			for i := 0; i < tt.entries; i++ {
+				entry, err := before.Tokenize(context.Background(), "1", details)
+				require.NoError(t, err)
+				tokens = append(tokens, entry.Token)
+			}
+
+			after := newVault(t, store, map[string][]byte{"k1": oldKey, "k2": newKey}, "k2")
+			rotated, err := after.RotateKeys(context.Background(), tt.batchSize)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.entries, rotated)
+
+			// Every entry is readable once the old key is retired
+			retired := newVault(t, store, map[string][]byte{"k2": newKey}, "k2")
+			for _, token := range tokens {
+				assert.Equal(t, "k2", store.entries[token].KeyID)
+				got, _, err := retired.Detokenize(context.Background(), "1", token)
+				assert.NoError(t, err)
+				assert.Equal(t, details, got)
+			}
+
+			// A second run has nothing left to rewrap
+			rotated, err = after.RotateKeys(context.Background(), tt.batchSize)
+			assert.NoError(t, err)
+			assert.Zero(t, rotated)
+		})
+	}
+}